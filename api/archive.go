@@ -0,0 +1,173 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/disposition"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ArchiveCollection = "collection"
+	ArchiveBundle     = "bundle"
+
+	formatZip = "zip"
+	formatTar = "tar"
+)
+
+var archiveContentTypes = map[string]string{
+	formatZip: "application/zip",
+	formatTar: "application/x-tar",
+}
+
+// archiveWriter writes files into an archive one after another
+type archiveWriter interface {
+	Create(name string, modified time.Time, size int64) (io.Writer, error)
+	Close() error
+}
+
+type zipArchive struct {
+	*zip.Writer
+}
+
+func newZipArchive(w io.Writer) archiveWriter {
+	return zipArchive{zip.NewWriter(w)}
+}
+
+func (z zipArchive) Create(name string, modified time.Time, _ int64) (io.Writer, error) {
+	return z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+}
+
+// tarArchive writes a tar archive. Unlike a zip archive the size of every file must be known before it is written.
+type tarArchive struct {
+	*tar.Writer
+}
+
+func newTarArchive(w io.Writer) archiveWriter {
+	return tarArchive{tar.NewWriter(w)}
+}
+
+func (t tarArchive) Create(name string, modified time.Time, size int64) (io.Writer, error) {
+	err := t.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: modified, Typeflag: tar.TypeReg})
+	return t.Writer, err
+}
+
+// CreateArchiveHandler handles requests to download every file in a collection or bundle, depending on owner, as a
+// single archive. The files are listed through the files API and each is checked against the user's permissions;
+// files the user cannot read are left out, and files that have not finished uploading are listed as skipped in the
+// manifest. As with bundles, the archive is refused if any file must be watermarked. The archive is a tar if the id ends in .tar or the request accepts application/x-tar, and a zip otherwise.
+func CreateArchiveHandler(owner string, listFiles files.FilesLister, downloadFileFromBucket, downloadMovedFile files.FileDownloader, createFileEvent files.FileEventCreator, authMiddleware auth.Middleware, cfg *config.Config, permissionsChecker auth.PermissionsChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		w.Header().Set("Cache-Control", "no-cache")
+
+		id, format := archiveFormat(mux.Vars(req)["id"], req.Header.Get("Accept"))
+		logData := log.Data{owner: id, "format": format}
+		log.Info(ctx, "Handling archive request", logData)
+
+		accessToken := getAccessTokenFromRequest(req)
+		entityData, authLogData, ok := authenticate(ctx, w, req, authMiddleware, accessToken)
+		if !ok {
+			return
+		}
+		authLogData[owner] = id
+
+		collectionID, bundleID := id, ""
+		if owner == ArchiveBundle {
+			collectionID, bundleID = "", id
+		}
+
+		listed, err := listFiles(ctx, collectionID, bundleID, filesAPISDK.Headers{Authorization: accessToken})
+		if err != nil {
+			handleMetadataError(ctx, w, err)
+			return
+		}
+
+		var paths []string
+		var metadata []*filesAPIModels.StoredRegisteredMetaData
+		var skipped []BundleSkippedFile
+		for i := range listed {
+			m := &listed[i]
+			if !checkUserPermission(ctx, authLogData, "static-files:read", setPermissionsAttributes(m), permissionsChecker, entityData) {
+				continue
+			}
+			if files.UploadIncomplete(m) {
+				skipped = append(skipped, BundleSkippedFile{Path: m.Path, State: m.State})
+				continue
+			}
+			if mustWatermark(m, cfg) {
+				log.Info(ctx, "File in archive must be watermarked", log.Data{"filePath": m.Path})
+				writeError(w, buildErrors(fmt.Errorf("%w: %s", errWatermarkRequired, m.Path), "WatermarkRequired"), http.StatusForbidden)
+				return
+			}
+			paths = append(paths, m.Path)
+			metadata = append(metadata, m)
+		}
+
+		if len(metadata) == 0 && len(skipped) == 0 {
+			if len(listed) > 0 {
+				log.Info(ctx, "authorisation failed: request has no permission", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), authLogData)
+				handleError(ctx, "the request was not authorised - check token and user's permissions", w, files.ErrNotAuthorised)
+				return
+			}
+			writeError(w, buildErrors(fmt.Errorf("no files found for %s %s", owner, id), "FilesNotFound"), http.StatusNotFound)
+			return
+		}
+
+		for i, m := range metadata {
+			if err = recordFileEvent(ctx, *entityData, accessToken, files.EventResource{Path: paths[i]}, m, w, createFileEvent); err != nil {
+				return
+			}
+		}
+		log.Info(ctx, "Successfully created file events for archive", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), authLogData)
+
+		w.Header().Set("Content-Type", archiveContentTypes[format])
+		w.Header().Set("Content-Disposition", disposition.Header(disposition.Attachment, fmt.Sprintf("%s.%s", id, format)))
+
+		aw := newZipArchive(w)
+		if format == formatTar {
+			aw = newTarArchive(w)
+		}
+
+		if err = writeBundle(ctx, aw, paths, metadata, skipped, openBundleFile(downloadFileFromBucket, downloadMovedFile)); err != nil {
+			// The response has already started, so the archive is left incomplete
+			log.Error(ctx, "Failed to stream archive", err, logData)
+		}
+	}
+}
+
+// archiveFormat returns the id without its archive extension and the format of archive requested
+func archiveFormat(id, accept string) (string, string) {
+	for _, format := range []string{formatZip, formatTar} {
+		if trimmed, ok := strings.CutSuffix(id, "."+format); ok {
+			return trimmed, format
+		}
+	}
+
+	if strings.Contains(accept, archiveContentTypes[formatTar]) {
+		return id, formatTar
+	}
+	return id, formatZip
+}
+
+// openBundleFile opens a file from the bucket, or from its public location once it has been moved
+func openBundleFile(downloadFileFromBucket, downloadMovedFile files.FileDownloader) func(string, *filesAPIModels.StoredRegisteredMetaData) (io.ReadCloser, error) {
+	return func(filePath string, m *filesAPIModels.StoredRegisteredMetaData) (io.ReadCloser, error) {
+		if files.Moved(m) {
+			return downloadMovedFile(filePath, "")
+		}
+		return downloadFileFromBucket(filePath, "")
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var collectionFiles = []filesAPIModels.StoredRegisteredMetaData{
+	{Path: "data/a.csv", Title: "A", SizeInBytes: 18, State: files.UPLOADED},
+	{Path: "data/b.csv", Title: "B", SizeInBytes: 17, State: files.MOVED},
+	{Path: "data/c.csv", Title: "C", SizeInBytes: 5, State: files.CREATED},
+	{Path: "secret/d.csv", Title: "D", SizeInBytes: 5, State: files.UPLOADED, ContentItem: &filesAPIModels.StoredContentItem{DatasetID: "secret", Edition: "2024"}},
+}
+
+func newArchiveRouter(owner string, listFiles files.FilesLister, createFileEvent files.FileEventCreator, permitted func(datasetEdition string) bool) *mux.Router {
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+
+	permissionsChecker := &authMock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+			return permitted(attributes["dataset_edition"]), nil
+		},
+	}
+
+	r := mux.NewRouter()
+	r.Path("/downloads/collections/{id}").HandlerFunc(CreateArchiveHandler(owner, listFiles, downloadBundleFile, downloadMovedBundleFile, createFileEvent, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker))
+	return r
+}
+
+func listCollectionFiles(t *testing.T) files.FilesLister {
+	return func(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+		assert.Equal(t, "collection1", collectionID)
+		assert.Empty(t, bundleID)
+		assert.Equal(t, testAccessToken, headers.Authorization)
+		return collectionFiles, nil
+	}
+}
+
+func newArchiveRequest(target string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+	return req
+}
+
+func recordEvents(resources *[]string) files.FileEventCreator {
+	return func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+		*resources = append(*resources, event.Resource)
+		return &event, nil
+	}
+}
+
+func notSecret(datasetEdition string) bool {
+	return datasetEdition != "secret/2024"
+}
+
+func TestArchiveZip(t *testing.T) {
+	var resources []string
+	r := newArchiveRouter(ArchiveCollection, listCollectionFiles(t), recordEvents(&resources), notSecret)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, newArchiveRequest("/downloads/collections/collection1.zip"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=\"collection1.zip\"; filename*=UTF-8''collection1.zip", rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, []string{"data/a.csv", "data/b.csv"}, resources)
+
+	contents := readZip(t, rec.Body.Bytes())
+	assert.Len(t, contents, 3)
+	assert.Equal(t, "private data/a.csv", contents["data/a.csv"])
+	assert.Equal(t, "public data/b.csv", contents["data/b.csv"])
+
+	var manifest BundleManifest
+	require.NoError(t, json.Unmarshal([]byte(contents["manifest.json"]), &manifest))
+	assert.Len(t, manifest.Files, 2)
+	assert.Equal(t, []BundleSkippedFile{{Path: "data/c.csv", State: files.CREATED}}, manifest.Skipped)
+}
+
+func TestArchiveTar(t *testing.T) {
+	for name, req := range map[string]*http.Request{
+		"extension": newArchiveRequest("/downloads/collections/collection1.tar"),
+		"accept": func() *http.Request {
+			req := newArchiveRequest("/downloads/collections/collection1")
+			req.Header.Set("Accept", "application/x-tar")
+			return req
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var resources []string
+			r := newArchiveRouter(ArchiveCollection, listCollectionFiles(t), recordEvents(&resources), notSecret)
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/x-tar", rec.Header().Get("Content-Type"))
+			assert.Equal(t, "attachment; filename=\"collection1.tar\"; filename*=UTF-8''collection1.tar", rec.Header().Get("Content-Disposition"))
+
+			tr := tar.NewReader(bytes.NewReader(rec.Body.Bytes()))
+			contents := map[string]string{}
+			for {
+				h, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				b, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				contents[h.Name] = string(b)
+			}
+			assert.Equal(t, "private data/a.csv", contents["data/a.csv"])
+			assert.Equal(t, "public data/b.csv", contents["data/b.csv"])
+			assert.Contains(t, contents, "manifest.json")
+		})
+	}
+}
+
+func TestArchiveBundle(t *testing.T) {
+	listBundleFiles := func(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+		assert.Empty(t, collectionID)
+		assert.Equal(t, "bundle1", bundleID)
+		return collectionFiles[:1], nil
+	}
+
+	var resources []string
+	r := newArchiveRouter(ArchiveBundle, listBundleFiles, recordEvents(&resources), notSecret)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, newArchiveRequest("/downloads/collections/bundle1"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"data/a.csv"}, resources)
+}
+
+func TestArchiveErrors(t *testing.T) {
+	t.Run("no files in the collection", func(t *testing.T) {
+		listNoFiles := func(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+			return nil, nil
+		}
+		r := newArchiveRouter(ArchiveCollection, listNoFiles, nil, notSecret)
+		rec := httptest.NewRecorder()
+
+		r.ServeHTTP(rec, newArchiveRequest("/downloads/collections/collection1.zip"))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "FilesNotFound")
+	})
+
+	t.Run("user cannot read any of the files", func(t *testing.T) {
+		r := newArchiveRouter(ArchiveCollection, listCollectionFiles(t), nil, func(string) bool { return false })
+		rec := httptest.NewRecorder()
+
+		r.ServeHTTP(rec, newArchiveRequest("/downloads/collections/collection1.zip"))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("files api rejects the request", func(t *testing.T) {
+		listUnauthorised := func(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+			return nil, &filesAPISDK.APIError{StatusCode: http.StatusUnauthorized}
+		}
+		r := newArchiveRouter(ArchiveCollection, listUnauthorised, nil, notSecret)
+		rec := httptest.NewRecorder()
+
+		r.ServeHTTP(rec, newArchiveRequest("/downloads/collections/collection1.zip"))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestArchiveFileSizeMismatch(t *testing.T) {
+	for name, body := range map[string]string{
+		"longer":  "private data/a.csv and more",
+		"shorter": "private",
+	} {
+		t.Run("fails when a file is "+name+" than its metadata", func(t *testing.T) {
+			var buf bytes.Buffer
+			m := &filesAPIModels.StoredRegisteredMetaData{Path: "data/a.csv", SizeInBytes: 18, State: files.UPLOADED}
+			open := func(string, *filesAPIModels.StoredRegisteredMetaData) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(body)), nil
+			}
+
+			err := writeBundle(context.Background(), newTarArchive(&buf), []string{m.Path}, []*filesAPIModels.StoredRegisteredMetaData{m}, nil, open)
+
+			assert.ErrorIs(t, err, errFileSizeMismatch)
+			assert.NotContains(t, buf.String(), "and more")
+		})
+	}
+}
@@ -30,6 +30,29 @@ func getAccessTokenFromRequest(r *http.Request) string {
 	return strings.TrimPrefix(accessToken, dprequest.BearerPrefix)
 }
 
+// authenticate returns the EntityData of the user making the request, writing an error response if the access token
+// is not valid. The returned log data identifies the request and user.
+func authenticate(ctx context.Context, w http.ResponseWriter, req *http.Request, authMiddleware auth.Middleware, accessToken string) (*permissionsAPISDK.EntityData, log.Data, bool) {
+	logData := log.Data{
+		"method": req.Method,
+		"path":   req.URL.Path,
+	}
+	entityData, err := getAuthEntityData(ctx, authMiddleware, accessToken, logData)
+	if err != nil {
+		log.Error(ctx, "the request was not authorised", err, logData)
+		if strings.Contains(err.Error(), "key id unknown or invalid") || strings.Contains(err.Error(), "jwt token is malformed") || strings.Contains(err.Error(), "unable to parse jwt") {
+			handleError(ctx, "Unauthorised", w, files.ErrInvalidAuth)
+			return nil, logData, false
+		}
+		handleError(ctx, "the request was not authorised - check token and user's permissions", w, err)
+		return nil, logData, false
+	}
+
+	logData["entity_data"] = entityData
+
+	return entityData, logData, true
+}
+
 // getAuthEntityData returns the EntityData associated with the provided access token
 func getAuthEntityData(ctx context.Context, authMiddleware auth.Middleware, accessToken string, logData log.Data) (*permissionsAPISDK.EntityData, error) {
 	var entityData *permissionsAPISDK.EntityData
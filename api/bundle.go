@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	auth "github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/disposition"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	defaultBundleName    = "bundle.zip"
+	bundleManifest       = "manifest.json"
+	maxBundleRequestSize = 1 << 20
+)
+
+var (
+	errInvalidBundle    = errors.New("invalid bundle request")
+	errFileNotAvailable = errors.New("file is not available for download")
+	errFileSizeMismatch = errors.New("file size does not match its metadata")
+)
+
+// BundleRequest is the body of a request to download several files as a single ZIP archive
+type BundleRequest struct {
+	Name  string   `json:"name,omitempty"`
+	Files []string `json:"files"`
+}
+
+// BundleManifest lists the files in a bundle. It is added to the archive as manifest.json.
+type BundleManifest struct {
+	Files   []BundleManifestFile `json:"files"`
+	Skipped []BundleSkippedFile  `json:"skipped,omitempty"`
+}
+
+// BundleManifestFile describes a file in a bundle
+type BundleManifestFile struct {
+	Path        string `json:"path"`
+	Title       string `json:"title"`
+	Type        string `json:"type"`
+	Licence     string `json:"licence"`
+	LicenceURL  string `json:"licence_url"`
+	SizeInBytes uint64 `json:"size_in_bytes"`
+}
+
+// BundleSkippedFile describes a file that was left out of a bundle because it cannot be downloaded yet
+type BundleSkippedFile struct {
+	Path  string `json:"path"`
+	State string `json:"state"`
+}
+
+// CreateBundleHandler handles requests to download several files as a ZIP archive that is streamed from the bucket
+// as it is built. Each file is checked in the same way as a single download; if any file cannot be downloaded by the
+// user the request fails before anything is streamed. In the publishing environment a file event is created for
+// every file in the bundle, and bundles are refused if any file must be watermarked, as files in bundles are not. Files
+// that have been moved to the public bucket are read with downloadMovedFile.
+func CreateBundleHandler(fetchMetadata files.MetadataFetcher, downloadFileFromBucket, downloadMovedFile files.FileDownloader, createFileEvent files.FileEventCreator, fetchRelease files.ReleaseFetcher, clock func() time.Time, authMiddleware auth.Middleware, cfg *config.Config, permissionsChecker auth.PermissionsChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if cfg.IsPublishing {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		bundle, err := parseBundleRequest(req, cfg.MaxBundleFiles)
+		if err != nil {
+			writeError(w, buildErrors(err, "InvalidBundle"), http.StatusBadRequest)
+			return
+		}
+
+		log.Info(ctx, "Handling bundle request", log.Data{"files": bundle.Files})
+
+		accessToken := getAccessTokenFromRequest(req)
+		headers := filesAPISDK.Headers{}
+		if cfg.IsPublishing {
+			headers.Authorization = accessToken
+		}
+
+		var metadata []*filesAPIModels.StoredRegisteredMetaData
+		for _, filePath := range bundle.Files {
+			m, err := fetchMetadata(ctx, filePath, headers)
+			if err != nil {
+				handleMetadataError(ctx, w, err)
+				return
+			}
+
+			if unavailable(m, cfg) {
+				log.Info(ctx, "File in bundle is not available for download", log.Data{"filePath": filePath, "state": m.State})
+				writeError(w, buildErrors(fmt.Errorf("%w: %s", errFileNotAvailable, filePath), "FileNotAvailable"), http.StatusNotFound)
+				return
+			}
+
+			if isWebMode(cfg) {
+				release, err := releaseDate(ctx, m, fetchRelease)
+				if err != nil {
+					handleError(ctx, "Failed to get the release date of the file", w, err)
+					return
+				}
+				if files.Embargoed(release, currentTime(clock)) {
+					log.Info(ctx, "File in bundle is embargoed until its release time", log.Data{"filePath": filePath, "release": release})
+					writeError(w, buildErrors(fmt.Errorf("%w: %s", errFileNotAvailable, filePath), "FileNotAvailable"), http.StatusNotFound)
+					return
+				}
+			}
+
+			metadata = append(metadata, m)
+		}
+
+		if cfg.IsPublishing {
+			entityData, logData, ok := authenticate(ctx, w, req, authMiddleware, accessToken)
+			if !ok {
+				return
+			}
+
+			logData["files"] = bundle.Files
+
+			for i, m := range metadata {
+				if mustWatermark(m, cfg) {
+					log.Info(ctx, "File in bundle must be watermarked", log.Data{"filePath": bundle.Files[i]})
+					writeError(w, buildErrors(fmt.Errorf("%w: %s", errWatermarkRequired, bundle.Files[i]), "WatermarkRequired"), http.StatusForbidden)
+					return
+				}
+			}
+
+			for _, m := range metadata {
+				if !checkUserPermission(ctx, logData, "static-files:read", setPermissionsAttributes(m), permissionsChecker, entityData) {
+					log.Info(ctx, "authorisation failed: request has no permission", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), logData)
+					handleError(ctx, "the request was not authorised - check token and user's permissions", w, files.ErrNotAuthorised)
+					return
+				}
+			}
+
+			for i, m := range metadata {
+				if err = recordFileEvent(ctx, *entityData, accessToken, files.EventResource{Path: bundle.Files[i]}, m, w, createFileEvent); err != nil {
+					return
+				}
+			}
+			log.Info(ctx, "Successfully created file events for bundle", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), logData)
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", disposition.Header(disposition.Attachment, bundle.Name))
+
+		err = writeBundle(ctx, newZipArchive(w), bundle.Files, metadata, nil, openBundleFile(downloadFileFromBucket, downloadMovedFile))
+		if err != nil {
+			// The response has already started, so the archive is left without its central directory and
+			// clients will report it as incomplete
+			log.Error(ctx, "Failed to stream bundle", err)
+		}
+	}
+}
+
+func parseBundleRequest(req *http.Request, maxFiles int) (*BundleRequest, error) {
+	var bundle BundleRequest
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxBundleRequestSize)).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidBundle, err)
+	}
+
+	seen := map[string]bool{}
+	paths := []string{}
+	for _, p := range bundle.Files {
+		p = strings.TrimPrefix(p, "/")
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: no files requested", errInvalidBundle)
+	}
+	if maxFiles > 0 && len(paths) > maxFiles {
+		return nil, fmt.Errorf("%w: %d files requested, the maximum is %d", errInvalidBundle, len(paths), maxFiles)
+	}
+
+	bundle.Files = paths
+	bundle.Name = path.Base(bundle.Name)
+	if bundle.Name == "." || bundle.Name == "/" {
+		bundle.Name = defaultBundleName
+	}
+	if !strings.HasSuffix(bundle.Name, ".zip") {
+		bundle.Name += ".zip"
+	}
+
+	return &bundle, nil
+}
+
+// unavailable reports whether a file cannot be downloaded in the current environment
+func unavailable(m *filesAPIModels.StoredRegisteredMetaData, cfg *config.Config) bool {
+	return files.UploadIncomplete(m) || (isWebMode(cfg) && files.Unpublished(m))
+}
+
+// writeBundle writes an archive containing a manifest followed by each file, streaming each file from open
+// without holding it in memory. Files that could not be included are listed in the manifest as skipped.
+func writeBundle(ctx context.Context, aw archiveWriter, paths []string, metadata []*filesAPIModels.StoredRegisteredMetaData, skipped []BundleSkippedFile, open func(string, *filesAPIModels.StoredRegisteredMetaData) (io.ReadCloser, error)) error {
+	manifest := BundleManifest{Files: make([]BundleManifestFile, 0, len(metadata)), Skipped: skipped}
+	for i, m := range metadata {
+		manifest.Files = append(manifest.Files, BundleManifestFile{
+			Path:        paths[i],
+			Title:       m.Title,
+			Type:        m.Type,
+			Licence:     m.Licence,
+			LicenceURL:  m.LicenceURL,
+			SizeInBytes: m.SizeInBytes,
+		})
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	mw, err := aw.Create(bundleManifest, time.Now(), int64(len(b)))
+	if err != nil {
+		return err
+	}
+	if _, err = mw.Write(b); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	for i, m := range metadata {
+		if err = writeBundleFile(ctx, aw, paths[i], m, open); err != nil {
+			return err
+		}
+	}
+
+	return aw.Close()
+}
+
+func writeBundleFile(ctx context.Context, aw archiveWriter, filePath string, m *filesAPIModels.StoredRegisteredMetaData, open func(string, *filesAPIModels.StoredRegisteredMetaData) (io.ReadCloser, error)) error {
+	modified := m.LastModified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	fw, err := aw.Create(filePath, modified, int64(m.SizeInBytes))
+	if err != nil {
+		return err
+	}
+
+	file, err := open(filePath, m)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", filePath, err)
+	}
+	defer closeDownloadedFile(ctx, file)
+
+	// The archive entry was created with the size from the metadata, so a file of any other length would corrupt it
+	size := int64(m.SizeInBytes)
+	n, err := io.Copy(fw, io.LimitReader(file, size))
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	if n < size {
+		return fmt.Errorf("%w: %s is %d bytes, expected %d", errFileSizeMismatch, filePath, n, size)
+	}
+	if extra, _ := io.CopyN(io.Discard, file, 1); extra > 0 {
+		return fmt.Errorf("%w: %s is longer than %d bytes", errFileSizeMismatch, filePath, size)
+	}
+
+	return nil
+}
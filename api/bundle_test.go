@@ -0,0 +1,165 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var bundleFiles = map[string]*filesAPIModels.StoredRegisteredMetaData{
+	"data/a.csv":     {Path: "data/a.csv", Title: "A", Licence: "OGL v3", SizeInBytes: 18, State: files.PUBLISHED},
+	"data/b.csv":     {Path: "data/b.csv", Title: "B", Licence: "OGL v3", SizeInBytes: 17, State: files.MOVED},
+	"data/draft.csv": {Path: "data/draft.csv", Title: "Draft", SizeInBytes: 22, State: files.UPLOADED},
+}
+
+func fetchBundleMetadata(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+	m, ok := bundleFiles[path]
+	if !ok {
+		return nil, files.ErrFileNotRegistered
+	}
+	return m, nil
+}
+
+func downloadBundleFile(path, versionID string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("private " + path)), nil
+}
+
+func downloadMovedBundleFile(path, versionID string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("public " + path)), nil
+}
+
+func newBundleRequest(body string) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, "/downloads/bundles", strings.NewReader(body))
+	req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+	return req
+}
+
+func readZip(t *testing.T, b []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		c, _ := io.ReadAll(r)
+		contents[f.Name] = string(c)
+	}
+	return contents
+}
+
+func TestBundleWebMode(t *testing.T) {
+	h := CreateBundleHandler(fetchBundleMetadata, downloadBundleFile, downloadMovedBundleFile, nil, nil, nil, nil, &config.Config{MaxBundleFiles: 2}, nil)
+
+	t.Run("streams a zip of the files with a manifest", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, newBundleRequest(`{"name": "release", "files": ["data/a.csv", "/data/b.csv", "data/a.csv"]}`))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=\"release.zip\"; filename*=UTF-8''release.zip", rec.Header().Get("Content-Disposition"))
+
+		contents := readZip(t, rec.Body.Bytes())
+		assert.Equal(t, "private data/a.csv", contents["data/a.csv"])
+		assert.Equal(t, "public data/b.csv", contents["data/b.csv"])
+
+		var manifest BundleManifest
+		require.NoError(t, json.Unmarshal([]byte(contents["manifest.json"]), &manifest))
+		assert.Equal(t, []BundleManifestFile{
+			{Path: "data/a.csv", Title: "A", Licence: "OGL v3", SizeInBytes: 18},
+			{Path: "data/b.csv", Title: "B", Licence: "OGL v3", SizeInBytes: 17},
+		}, manifest.Files)
+	})
+
+	t.Run("rejects bundles containing unpublished files", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, newBundleRequest(`{"files": ["data/a.csv", "data/draft.csv"]}`))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "FileNotAvailable")
+	})
+
+	t.Run("rejects bundles containing unregistered files", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, newBundleRequest(`{"files": ["data/missing.csv"]}`))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		for _, body := range []string{`not json`, `{"files": []}`, `{"files": ["a", "b", "c"]}`} {
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, newBundleRequest(body))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+	})
+}
+
+func TestBundlePublishingMode(t *testing.T) {
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+
+	permissionsChecker := func(allowed bool) *authMock.PermissionsCheckerMock {
+		return &authMock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return allowed, nil
+			},
+		}
+	}
+
+	t.Run("creates a file event for every file", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		var resources []string
+		createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			assert.Equal(t, filesAPIModels.ActionRead, event.Action)
+			resources = append(resources, event.Resource)
+			return &event, nil
+		}
+
+		h := CreateBundleHandler(fetchBundleMetadata, downloadBundleFile, downloadMovedBundleFile, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker(true))
+		h.ServeHTTP(rec, newBundleRequest(`{"files": ["data/a.csv", "data/draft.csv"]}`))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"data/a.csv", "data/draft.csv"}, resources)
+		assert.Len(t, readZip(t, rec.Body.Bytes()), 3)
+	})
+
+	t.Run("rejects the bundle if the user cannot read any of the files", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			t.Fatal("createFileEvent should not have been called")
+			return nil, nil
+		}
+
+		h := CreateBundleHandler(fetchBundleMetadata, downloadBundleFile, downloadMovedBundleFile, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker(false))
+		h.ServeHTTP(rec, newBundleRequest(`{"files": ["data/a.csv"]}`))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
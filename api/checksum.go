@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+const checksumSuffix = ".sha256"
+
+// WithChecksumSuffix serves the checksum of a file when ".sha256" is added to its path, so that the checksum of
+// /downloads/files/{path} is also at /downloads/files/{path}.sha256. Paths ending in ".sha256" that are registered with
+// the files API in their own right are downloaded as usual.
+func WithChecksumSuffix(fetchMetadata files.MetadataFetcher, checksum, download http.HandlerFunc, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		filePath, ok := strings.CutSuffix(vars["path"], checksumSuffix)
+		if !ok {
+			download(w, req)
+			return
+		}
+
+		headers := filesAPISDK.Headers{}
+		if cfg.IsPublishing {
+			headers.Authorization = getAccessTokenFromRequest(req)
+		}
+
+		if _, err := fetchMetadata(req.Context(), vars["path"], headers); !errors.Is(err, files.ErrFileNotRegistered) {
+			download(w, req)
+			return
+		}
+
+		checksum(w, mux.SetURLVars(req, map[string]string{"path": filePath}))
+	}
+}
+
+// CreateChecksumHandler handles requests for the SHA-256 checksum of a file, written in the format of sha256sum so
+// that the file can be checked with `sha256sum -c`. A checksum is subject to the same checks as downloading the file.
+// Files that must be watermarked have no checksum, as no download of them matches the object in the bucket.
+func CreateChecksumHandler(fetchMetadata files.MetadataFetcher, fetchChecksum files.ChecksumFetcher, fetchWithdrawal files.WithdrawalFetcher, fetchRelease files.ReleaseFetcher, clock func() time.Time, authMiddleware auth.Middleware, cfg *config.Config, permissionsChecker auth.PermissionsChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if cfg.IsPublishing {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		ctx, requestedFilePath := parseRequest(req)
+		log.Info(ctx, fmt.Sprintf("Handling checksum request for %s", requestedFilePath))
+
+		accessToken := getAccessTokenFromRequest(req)
+		headers := filesAPISDK.Headers{}
+		if cfg.IsPublishing {
+			headers.Authorization = accessToken
+		}
+
+		metadata, err := fetchMetadata(ctx, requestedFilePath, headers)
+		if err != nil {
+			handleMetadataError(ctx, w, err)
+			return
+		}
+
+		if isWebMode(cfg) && handleWithdrawnFile(ctx, w, metadata, requestedFilePath, fetchWithdrawal) {
+			return
+		}
+
+		if unavailable(metadata, cfg) {
+			log.Info(ctx, "File is not available for checksum", log.Data{"state": metadata.State})
+			setStatusNotFound(w)
+			return
+		}
+
+		if isWebMode(cfg) {
+			if _, embargoed := handleEmbargoedFile(ctx, w, metadata, fetchRelease, clock); embargoed {
+				return
+			}
+		}
+
+		if cfg.IsPublishing {
+			entityData, logData, ok := authenticate(ctx, w, req, authMiddleware, accessToken)
+			if !ok {
+				return
+			}
+
+			if !checkUserPermission(ctx, logData, "static-files:read", setPermissionsAttributes(metadata), permissionsChecker, entityData) {
+				log.Info(ctx, "authorisation failed: request has no permission", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), logData)
+				handleError(ctx, "the request was not authorised - check token and user's permissions", w, files.ErrNotAuthorised)
+				return
+			}
+
+			if mustWatermark(metadata, cfg) {
+				log.Info(ctx, "Pre-release file cannot be watermarked in a checksum", log.Data{"filePath": requestedFilePath})
+				writeError(w, buildErrors(errWatermarkRequired, "WatermarkRequired"), http.StatusForbidden)
+				return
+			}
+		}
+
+		sum, err := fetchChecksum(ctx, metadata)
+		if err != nil {
+			handleError(ctx, fmt.Sprintf("Error getting checksum of file %s", requestedFilePath), w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err = fmt.Fprintf(w, "%s  %s\n", sum, files.GetFilename(metadata)); err != nil {
+			log.Error(ctx, "Failed to write checksum", err)
+		}
+	}
+}
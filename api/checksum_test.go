@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const testChecksum = "9c70933aff6b2a6d08c687a6cbb6b765c8bb6a9d5b6a7a0b6c9e3e0a6f2b5c1d"
+
+func fetchTestChecksum(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (string, error) {
+	return testChecksum, nil
+}
+
+func serveChecksum(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.Path("/downloads/checksums/{path:.*}").HandlerFunc(h)
+
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestChecksumWebMode(t *testing.T) {
+	h := CreateChecksumHandler(fetchPreviewMetadata, fetchTestChecksum, nil, nil, nil, nil, &config.Config{}, nil)
+
+	t.Run("returns the checksum of a published file in the format of sha256sum", func(t *testing.T) {
+		rec := serveChecksum(h, "/downloads/checksums/data/published.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, testChecksum+"  published.csv\n", rec.Body.String())
+	})
+
+	t.Run("returns the checksum of a moved file", func(t *testing.T) {
+		var moved bool
+		fetchChecksum := func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (string, error) {
+			moved = files.Moved(m)
+			return testChecksum, nil
+		}
+
+		rec := serveChecksum(CreateChecksumHandler(fetchPreviewMetadata, fetchChecksum, nil, nil, nil, nil, &config.Config{}, nil), "/downloads/checksums/data/moved.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, moved)
+	})
+
+	t.Run("does not return the checksum of unpublished files", func(t *testing.T) {
+		for _, path := range []string{"data/draft.csv", "data/created.csv"} {
+			rec := serveChecksum(h, "/downloads/checksums/"+path)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, path)
+		}
+	})
+
+	t.Run("returns not found for unregistered files", func(t *testing.T) {
+		rec := serveChecksum(h, "/downloads/checksums/data/missing.csv")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("returns an error if the checksum cannot be read", func(t *testing.T) {
+		fetchChecksum := func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (string, error) {
+			return "", errors.New("bucket unavailable")
+		}
+
+		rec := serveChecksum(CreateChecksumHandler(fetchPreviewMetadata, fetchChecksum, nil, nil, nil, nil, &config.Config{}, nil), "/downloads/checksums/data/published.csv")
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestChecksumPublishingMode(t *testing.T) {
+	cfg := &config.Config{IsPublishing: true}
+
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+
+	permissionsChecker := func(allowed bool) *authMock.PermissionsCheckerMock {
+		return &authMock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return allowed, nil
+			},
+		}
+	}
+
+	t.Run("returns the checksum of unpublished files", func(t *testing.T) {
+		h := CreateChecksumHandler(fetchPreviewMetadata, fetchTestChecksum, nil, nil, nil, authorisationMock, cfg, permissionsChecker(true))
+		rec := serveChecksum(h, "/downloads/checksums/data/draft.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, testChecksum+"  draft.csv\n", rec.Body.String())
+	})
+
+	t.Run("does not return the checksum of files that are still being uploaded", func(t *testing.T) {
+		h := CreateChecksumHandler(fetchPreviewMetadata, fetchTestChecksum, nil, nil, nil, authorisationMock, cfg, permissionsChecker(true))
+		rec := serveChecksum(h, "/downloads/checksums/data/created.csv")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("rejects users without permission to read the file", func(t *testing.T) {
+		fetchChecksum := func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (string, error) {
+			t.Fatal("fetchChecksum should not have been called")
+			return "", nil
+		}
+
+		h := CreateChecksumHandler(fetchPreviewMetadata, fetchChecksum, nil, nil, nil, authorisationMock, cfg, permissionsChecker(false))
+		rec := serveChecksum(h, "/downloads/checksums/data/draft.csv")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestWithChecksumSuffix(t *testing.T) {
+	fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		if path == "data/registered.sha256" {
+			return &filesAPIModels.StoredRegisteredMetaData{Path: path, State: files.PUBLISHED}, nil
+		}
+		return fetchPreviewMetadata(ctx, path, headers)
+	}
+	download := func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprint(w, "download of "+mux.Vars(req)["path"])
+	}
+	checksum := CreateChecksumHandler(fetchPreviewMetadata, fetchTestChecksum, nil, nil, nil, nil, &config.Config{}, nil)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		r := mux.NewRouter()
+		r.Path("/downloads/files/{path:.*}").HandlerFunc(WithChecksumSuffix(fetchMetadata, checksum, download, &config.Config{}))
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		return rec
+	}
+
+	t.Run("downloads files without the suffix", func(t *testing.T) {
+		rec := serve("/downloads/files/data/published.csv")
+
+		assert.Equal(t, "download of data/published.csv", rec.Body.String())
+	})
+
+	t.Run("returns the checksum of the file without the suffix", func(t *testing.T) {
+		rec := serve("/downloads/files/data/published.csv.sha256")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, testChecksum+"  published.csv\n", rec.Body.String())
+	})
+
+	t.Run("applies the checksum rules to the file without the suffix", func(t *testing.T) {
+		rec := serve("/downloads/files/data/draft.csv.sha256")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("downloads registered files ending in the suffix", func(t *testing.T) {
+		rec := serve("/downloads/files/data/registered.sha256")
+
+		assert.Equal(t, "download of data/registered.sha256", rec.Body.String())
+	})
+}
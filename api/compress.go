@@ -0,0 +1,231 @@
+package api
+
+import (
+	"cmp"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingZstd   = "zstd"
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+
+	// minCompressSize is the smallest response worth compressing, when its length is known
+	minCompressSize = 1024
+)
+
+// compressionEncodings are the encodings the service compresses with, in order of preference
+var compressionEncodings = []string{encodingZstd, encodingBrotli, encodingGzip}
+
+// compressibleTypes are the media types, other than text, whose content compresses well
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/x-ndjson":   true,
+	"application/ld+json":    true,
+	"application/csvm+json":  true,
+	"application/xml":        true,
+	"application/javascript": true,
+}
+
+// Compress is a middleware that compresses text responses, such as CSV and CSVW files, with an encoding accepted
+// by the client. Responses that are already encoded, partial content or of a type that is already compressed
+// (spreadsheets, archives, images) are sent unchanged. Range requests are not compressed, so the ranges always
+// refer to the bytes of the file.
+//
+// The level is the gzip compression level from 1 to 9, which is mapped to the nearest zstd level and used as the
+// brotli quality.
+// If level is 0, responses are never compressed.
+func Compress(level int) func(http.Handler) http.Handler {
+	level = min(level, gzip.BestCompression)
+
+	encoders := map[string]*sync.Pool{
+		encodingGzip: {New: func() any {
+			gw, _ := gzip.NewWriterLevel(io.Discard, level)
+			return gw
+		}},
+		encodingBrotli: {New: func() any {
+			return brotli.NewWriterLevel(io.Discard, level)
+		}},
+		encodingZstd: {New: func() any {
+			zw, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+			return zw
+		}},
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if level < 1 {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoders: encoders}
+			if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+				cw.encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			}
+			defer cw.close()
+
+			h.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// resettableEncoder is a pooled gzip, brotli or zstd writer
+type resettableEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressWriter decides whether to compress the response once its headers are written
+type compressWriter struct {
+	http.ResponseWriter
+	encoders    map[string]*sync.Pool
+	encoding    string
+	encoder     resettableEncoder
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	if compressible(header.Get("Content-Type")) {
+		addVary(header, "Accept-Encoding")
+
+		if cw.encoding != "" && code == http.StatusOK && header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" && !tooSmallToCompress(header) {
+			header.Set("Content-Encoding", cw.encoding)
+			header.Del("Content-Length")
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+
+			cw.encoder = cw.encoders[cw.encoding].Get().(resettableEncoder)
+			cw.encoder.Reset(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends any compressed data written so far to the client
+func (cw *compressWriter) Flush() {
+	if cw.encoder != nil {
+		cw.encoder.Flush() // nolint
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close ends the compressed stream and returns the encoder to its pool
+func (cw *compressWriter) close() {
+	if cw.encoder == nil {
+		return
+	}
+	cw.encoder.Close() // nolint
+	cw.encoder.Reset(io.Discard)
+	cw.encoders[cw.encoding].Put(cw.encoder)
+	cw.encoder = nil
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || compressibleTypes[mediaType]
+}
+
+func tooSmallToCompress(header http.Header) bool {
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	return err == nil && length < minCompressSize
+}
+
+// negotiateEncoding returns the supported encoding the Accept-Encoding header prefers, or an empty string if the
+// response should not be compressed
+func negotiateEncoding(acceptEncoding string) string {
+	if accepted := acceptedEncodings(acceptEncoding, compressionEncodings); len(accepted) > 0 {
+		return accepted[0]
+	}
+	return ""
+}
+
+// acceptedEncodings returns the supported encodings that the Accept-Encoding header allows, ordered by the client's
+// preference and then by the order they are supported in
+func acceptedEncodings(acceptEncoding string, supported []string) []string {
+	if acceptEncoding == "" {
+		return nil
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+
+	quality := func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+		return qualities["*"]
+	}
+
+	var accepted []string
+	for _, encoding := range supported {
+		if quality(encoding) > 0 {
+			accepted = append(accepted, encoding)
+		}
+	}
+	slices.SortStableFunc(accepted, func(a, b string) int {
+		return cmp.Compare(quality(b), quality(a))
+	})
+
+	return accepted
+}
+
+// addVary adds a field to the Vary header unless it is already listed
+func addVary(header http.Header, field string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var csvContent = strings.Repeat("time,geography,value\n2024,K02000001,123.4\n", 100)
+
+func fileHandler(contentType, body string, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(status)
+		w.Write([]byte(body)) // nolint
+	})
+}
+
+func compressedRequest(t *testing.T, h http.Handler, level int, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/downloads/files/data.csv", http.NoBody)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+
+	Compress(level)(h).ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestCompressGzip(t *testing.T) {
+	rec := compressedRequest(t, fileHandler("text/csv", csvContent, http.StatusOK), 5, map[string]string{"Accept-Encoding": "gzip, deflate"})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Equal(t, `W/"abc"`, rec.Header().Get("ETag"))
+	assert.Less(t, rec.Body.Len(), len(csvContent))
+
+	gr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	b, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, csvContent, string(b))
+}
+
+func TestCompressZstd(t *testing.T) {
+	rec := compressedRequest(t, fileHandler("application/csvm+json", csvContent, http.StatusOK), 5, map[string]string{"Accept-Encoding": "gzip;q=0.8, zstd"})
+
+	assert.Equal(t, "zstd", rec.Header().Get("Content-Encoding"))
+
+	zr, err := zstd.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	defer zr.Close()
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, csvContent, string(b))
+}
+
+func TestCompressBrotli(t *testing.T) {
+	rec := compressedRequest(t, fileHandler("text/csv", csvContent, http.StatusOK), 5, map[string]string{"Accept-Encoding": "gzip, br"})
+
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, `W/"abc"`, rec.Header().Get("ETag"))
+	assert.Less(t, rec.Body.Len(), len(csvContent))
+
+	b, err := io.ReadAll(brotli.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Equal(t, csvContent, string(b))
+}
+
+func TestCompressSkipped(t *testing.T) {
+	tests := map[string]struct {
+		handler http.Handler
+		level   int
+		headers map[string]string
+		vary    bool
+	}{
+		"no accepted encoding": {
+			handler: fileHandler("text/csv", csvContent, http.StatusOK),
+			level:   5,
+			headers: map[string]string{"Accept-Encoding": "deflate, gzip;q=0"},
+			vary:    true,
+		},
+		"already compressed type": {
+			handler: fileHandler("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", csvContent, http.StatusOK),
+			level:   5,
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+		},
+		"range request": {
+			handler: fileHandler("text/csv", csvContent, http.StatusOK),
+			level:   5,
+			headers: map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9"},
+			vary:    true,
+		},
+		"partial content": {
+			handler: fileHandler("text/csv", csvContent, http.StatusPartialContent),
+			level:   5,
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			vary:    true,
+		},
+		"small file": {
+			handler: fileHandler("text/csv", "a,b\n", http.StatusOK),
+			level:   5,
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			vary:    true,
+		},
+		"compression disabled": {
+			handler: fileHandler("text/csv", csvContent, http.StatusOK),
+			level:   0,
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := compressedRequest(t, tc.handler, tc.level, tc.headers)
+
+			assert.Empty(t, rec.Header().Get("Content-Encoding"))
+			assert.NotEmpty(t, rec.Header().Get("Content-Length"))
+			assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+			assert.Equal(t, tc.vary, rec.Header().Get("Vary") == "Accept-Encoding")
+		})
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "", negotiateEncoding(""))
+	assert.Equal(t, "", negotiateEncoding("identity"))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip"))
+	assert.Equal(t, "zstd", negotiateEncoding("gzip, zstd"))
+	assert.Equal(t, "br", negotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, "zstd", negotiateEncoding("gzip, br, zstd"))
+	assert.Equal(t, "gzip", negotiateEncoding("zstd;q=0.5, gzip;q=0.9"))
+	assert.Equal(t, "zstd", negotiateEncoding("*"))
+	assert.Equal(t, "br", negotiateEncoding("*, zstd;q=0"))
+	assert.Equal(t, "gzip", negotiateEncoding("*, zstd;q=0, br;q=0"))
+}
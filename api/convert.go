@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/disposition"
+	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/provenance"
+	"github.com/ONSdigital/dp-download-service/tabular"
+	"github.com/ONSdigital/dp-download-service/watermark"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// csvwSuffix is appended to the path of a CSV file to find its CSVW metadata
+const csvwSuffix = "-metadata.json"
+
+var errConversionNotSupported = errors.New("only CSV files can be converted to another format or filtered")
+
+// convertFile streams a CSV file converted to the format requested with the format query parameter or Accept header,
+// and filtered to the columns and rows requested with the columns and filter query parameters, returning false if the
+// file should be downloaded as it is. Column types are read from the CSVW metadata stored alongside the file, if there
+// is any. Converted files are never redirected, so moved files are read from the public bucket with downloadMovedFile.
+// If a watermark is given, its ID is returned in a header and CSV output has it as a comment row; other formats cannot
+// carry a watermark, so are refused.
+func convertFile(ctx context.Context, w http.ResponseWriter, req *http.Request, metadata *filesAPIModels.StoredRegisteredMetaData, requestedFilePath, versionID string, format tabular.Format, filter *tabular.Filter, prov func() provenance.Headers, mark *watermark.Mark, cfg *config.Config, downloadFileFromBucket, downloadMovedFile files.FileDownloader) bool {
+	if !isCSV(metadata) {
+		if (req.URL.Query().Get("format") != "" && format != tabular.FormatCSV) || filter != nil {
+			writeError(w, buildErrors(errConversionNotSupported, "ConversionNotSupported"), http.StatusBadRequest)
+			return true
+		}
+		return false
+	}
+
+	addVary(w.Header(), "Accept")
+	if format == tabular.FormatCSV && filter == nil {
+		return false
+	}
+
+	if unavailable(metadata, cfg) {
+		log.Info(ctx, "File is not available for conversion", log.Data{"state": metadata.State})
+		setStatusNotFound(w)
+		return true
+	}
+
+	if mark != nil && format != tabular.FormatCSV {
+		log.Info(ctx, "Pre-release file cannot be watermarked in the format requested", log.Data{"filePath": requestedFilePath, "format": format})
+		writeError(w, buildErrors(errWatermarkRequired, "WatermarkRequired"), http.StatusForbidden)
+		return true
+	}
+
+	open := downloadFileFromBucket
+	if files.Moved(metadata) {
+		open, versionID = downloadMovedFile, ""
+	}
+
+	file, err := open(requestedFilePath, versionID)
+	if err != nil {
+		handleError(ctx, fmt.Sprintf("Error downloading file %s", requestedFilePath), w, err)
+		return true
+	}
+
+	defer closeDownloadedFile(ctx, file)
+
+	var source io.Reader = file
+	filename := downloadFilename(ctx, metadata)
+	if filter != nil {
+		source, err = filter.Reader(file)
+		switch {
+		case errors.Is(err, tabular.ErrUnknownColumn):
+			writeError(w, buildErrors(err, "InvalidFilter"), http.StatusBadRequest)
+			return true
+		case err != nil:
+			writeError(w, buildErrors(err, "InvalidCSV"), http.StatusUnprocessableEntity)
+			return true
+		}
+		filename = tabular.FilteredFilename(filename)
+	}
+
+	var schema tabular.Schema
+	if format != tabular.FormatCSV {
+		schema = readSchema(ctx, requestedFilePath+csvwSuffix, open)
+	}
+
+	log.Info(ctx, "Converting file", log.Data{"filePath": requestedFilePath, "format": format, "filter": filter})
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", disposition.Header(disposition.Type(req, format.ContentType(), cfg.InlineDisposition), format.Filename(filename)))
+	prov().Set(w.Header())
+	if versionID != "" {
+		w.Header().Set(VersionIDHeader, versionID)
+	}
+
+	var dst io.Writer = w
+	if mark != nil {
+		w.Header().Set(watermark.IDHeader, mark.ID)
+		if format == tabular.FormatCSV {
+			dst = mark.CSVWriter(w)
+		}
+	}
+
+	out := &startedWriter{w: dst}
+	err = tabular.Convert(out, source, format, schema, cfg.ParquetRowGroupBytes)
+	switch {
+	case err != nil && !out.started && errors.Is(err, tabular.ErrInvalidCSV):
+		writeError(w, buildErrors(err, "InvalidCSV"), http.StatusUnprocessableEntity)
+	case err != nil:
+		// The response has already started, so the client receives incomplete output
+		log.Error(ctx, "Failed to convert file", err, log.Data{"filePath": requestedFilePath, "format": format})
+	}
+
+	return true
+}
+
+// readSchema reads the column types from CSVW metadata. If there is no metadata every column is converted as a string.
+func readSchema(ctx context.Context, csvwPath string, open files.FileDownloader) tabular.Schema {
+	file, err := open(csvwPath, "")
+	if err != nil {
+		log.Info(ctx, "No CSVW metadata found, converting columns as strings", log.Data{"csvwPath": csvwPath})
+		return nil
+	}
+
+	defer closeDownloadedFile(ctx, file)
+
+	schema, err := tabular.ParseCSVW(file)
+	if err != nil {
+		log.Warn(ctx, "Invalid CSVW metadata, converting columns as strings", log.Data{"csvwPath": csvwPath, "error": err.Error()})
+		return nil
+	}
+
+	return schema
+}
+
+// startedWriter records whether any of the response body has been written
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+	return s.w.Write(p)
+}
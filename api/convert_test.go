@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var convertFiles = map[string]*filesAPIModels.StoredRegisteredMetaData{
+	"data/published.csv":  {Path: "data/published.csv", Type: "text/csv", State: files.PUBLISHED},
+	"data/moved.csv":      {Path: "data/moved.csv", Type: "text/csv", State: files.MOVED},
+	"data/draft.csv":      {Path: "data/draft.csv", Type: "text/csv", State: files.UPLOADED},
+	"data/invalid.csv":    {Path: "data/invalid.csv", Type: "text/csv", State: files.PUBLISHED},
+	"data/filterable.csv": {Path: "data/filterable.csv", Type: "text/csv", State: files.PUBLISHED},
+	"data/report.pdf":     {Path: "data/report.pdf", Type: "application/pdf", State: files.PUBLISHED},
+}
+
+var convertObjects = map[string]string{
+	"data/published.csv":               "geography,value\nK02000001,1\n",
+	"data/published.csv-metadata.json": `{"tableSchema": {"columns": [{"titles": "value", "datatype": "integer"}]}}`,
+	"data/invalid.csv":                 "\"unterminated\n",
+	"data/filterable.csv":              "geography,year,value\nK02000001,2024,1\nE92000001,2023,2\nW92000004,2024,3\n",
+	"data/report.pdf":                  "%PDF",
+}
+
+func fetchConvertMetadata(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+	m, ok := convertFiles[path]
+	if !ok {
+		return nil, files.ErrFileNotRegistered
+	}
+	return m, nil
+}
+
+func downloadConvertFile(path, versionID string) (io.ReadCloser, error) {
+	object, ok := convertObjects[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return io.NopCloser(strings.NewReader(object)), nil
+}
+
+func downloadMovedConvertFile(path, versionID string) (io.ReadCloser, error) {
+	if path != "data/moved.csv" {
+		return nil, errors.New("not found")
+	}
+	return io.NopCloser(strings.NewReader("geography,value\nW92000004,3\n")), nil
+}
+
+func serveConvert(target string, headers map[string]string) *httptest.ResponseRecorder {
+	h := CreateDownloadHandlerNoAuth(fetchConvertMetadata, downloadConvertFile, nil, nil, downloadMovedConvertFile, nil, nil, nil, &config.Config{})
+
+	r := mux.NewRouter()
+	r.Path("/downloads/files/{path:.*}").HandlerFunc(h)
+
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestConvertDownload(t *testing.T) {
+	t.Run("converts to the format parameter with types from the CSVW metadata", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/published.csv?format=json", nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=\"published.json\"; filename*=UTF-8''published.json", rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.JSONEq(t, `[{"geography": "K02000001", "value": 1}]`, rec.Body.String())
+	})
+
+	t.Run("converts to the format in the Accept header", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/published.csv", map[string]string{"Accept": "application/vnd.apache.parquet"})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/vnd.apache.parquet", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=\"published.parquet\"; filename*=UTF-8''published.parquet", rec.Header().Get("Content-Disposition"))
+		assert.True(t, bytes.HasPrefix(rec.Body.Bytes(), []byte("PAR1")))
+	})
+
+	t.Run("converts moved files instead of redirecting", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/moved.csv?format=ndjson", nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `{"geography":"W92000004","value":"3"}`+"\n", rec.Body.String())
+	})
+
+	t.Run("downloads the CSV file when no other format is requested", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/published.csv", map[string]string{"Accept": "text/html, */*;q=0.8"})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+		assert.Equal(t, convertObjects["data/published.csv"], rec.Body.String())
+	})
+
+	t.Run("does not convert unpublished files", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/draft.csv?format=json", nil)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("rejects unsupported formats", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/published.csv?format=xml", nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "InvalidFormat")
+	})
+
+	t.Run("rejects conversion of files that are not CSV", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/report.pdf?format=json", nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "ConversionNotSupported")
+	})
+
+	t.Run("ignores an Accept header for files that are not CSV", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/report.pdf", map[string]string{"Accept": "application/json"})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "%PDF", rec.Body.String())
+	})
+
+	t.Run("rejects invalid CSV before any output is written", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/invalid.csv?format=json", nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "InvalidCSV")
+	})
+}
+
+func TestFilterDownload(t *testing.T) {
+	t.Run("streams the selected columns of matching rows", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/filterable.csv?columns=geography,value&filter=year=2024", nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=\"filterable-filtered.csv\"; filename*=UTF-8''filterable-filtered.csv", rec.Header().Get("Content-Disposition"))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.Equal(t, "geography,value\nK02000001,1\nW92000004,3\n", rec.Body.String())
+	})
+
+	t.Run("filters before converting to another format", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/filterable.csv?filter=geography=E92000001&format=ndjson", nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "attachment; filename=\"filterable-filtered.ndjson\"; filename*=UTF-8''filterable-filtered.ndjson", rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, `{"geography":"E92000001","year":"2023","value":"2"}`+"\n", rec.Body.String())
+	})
+
+	t.Run("rejects columns that are not in the file", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/filterable.csv?filter=sex=male", nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "InvalidFilter")
+	})
+
+	t.Run("rejects malformed filters", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/filterable.csv?filter=geography", nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "InvalidFilter")
+	})
+
+	t.Run("rejects filtering files that are not CSV", func(t *testing.T) {
+		rec := serveConvert("/downloads/files/data/report.pdf?columns=a", nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "ConversionNotSupported")
+	})
+}
@@ -2,27 +2,43 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	auth "github.com/ONSdigital/dp-authorisation/v2/authorisation"
 	dprequest "github.com/ONSdigital/dp-net/v3/request"
 	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
 
 	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/content"
+	"github.com/ONSdigital/dp-download-service/disposition"
 	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/provenance"
+	"github.com/ONSdigital/dp-download-service/tabular"
+	"github.com/ONSdigital/dp-download-service/watermark"
 	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
 	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 )
 
+var (
+	errInvalidRange      = errors.New("invalid range")
+	errWatermarkRequired = errors.New("pre-release copies of this file must be downloaded on their own so they can be watermarked")
+)
+
+// VersionIDHeader is the response header naming the object version that was downloaded
+const VersionIDHeader = "X-Object-Version-Id"
+
 // CreateDownloadHandlerWithAuth handles generic download file requests in the publishing environment.
-func CreateDownloadHandlerWithAuth(fetchMetadata files.MetadataFetcher, downloadFileFromBucket files.FileDownloader, createFileEvent files.FileEventCreator, authMiddleware auth.Middleware, cfg *config.Config, permissionsChecker auth.PermissionsChecker) http.HandlerFunc {
+func CreateDownloadHandlerWithAuth(fetchMetadata files.MetadataFetcher, downloadFileFromBucket files.FileDownloader, downloadFileRange files.FileRangeDownloader, downloadVariant files.FileVariantDownloader, downloadMovedFile files.FileDownloader, createFileEvent files.FileEventCreator, fetchRelease files.ReleaseFetcher, clock func() time.Time, authMiddleware auth.Middleware, cfg *config.Config, permissionsChecker auth.PermissionsChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		if cfg.IsPublishing {
 			w.Header().Set("Cache-Control", "no-cache")
@@ -33,6 +49,18 @@ func CreateDownloadHandlerWithAuth(fetchMetadata files.MetadataFetcher, download
 		ctx, requestedFilePath := parseRequest(req)
 		log.Info(ctx, fmt.Sprintf("Handling request for %s", requestedFilePath))
 
+		format, err := tabular.NegotiateFormat(req.URL.Query().Get("format"), req.Header.Get("Accept"))
+		if err != nil {
+			writeError(w, buildErrors(err, "InvalidFormat"), http.StatusBadRequest)
+			return
+		}
+
+		filter, err := tabular.ParseFilter(req.URL.Query())
+		if err != nil {
+			writeError(w, buildErrors(err, "InvalidFilter"), http.StatusBadRequest)
+			return
+		}
+
 		metadata, err := fetchMetadata(ctx, requestedFilePath, filesAPISDK.Headers{Authorization: accessToken})
 		if err != nil {
 			handleMetadataError(ctx, w, err)
@@ -41,36 +69,59 @@ func CreateDownloadHandlerWithAuth(fetchMetadata files.MetadataFetcher, download
 
 		log.Info(ctx, fmt.Sprintf("Found metadata for file %s", requestedFilePath), log.Data{"metadata": metadata})
 
-		if cfg.IsPublishing {
-			logData := log.Data{
-				"method": req.Method,
-				"path":   req.URL.Path,
+		var release *time.Time
+		if isWebMode(cfg) {
+			var embargoed bool
+			if release, embargoed = handleEmbargoedFile(ctx, w, metadata, fetchRelease, clock); embargoed {
+				return
 			}
-			entityData, err := getAuthEntityData(ctx, authMiddleware, accessToken, logData)
-			if err != nil {
-				log.Error(ctx, "the request was not authorised", err, logData)
-				if strings.Contains(err.Error(), "key id unknown or invalid") || strings.Contains(err.Error(), "jwt token is malformed") || strings.Contains(err.Error(), "unable to parse jwt") {
-					handleError(ctx, "Unauthorised", w, files.ErrInvalidAuth)
-					return
-				}
-				handleError(ctx, "the request was not authorised - check token and user's permissions", w, err)
+		} else if cfg.ProvenanceHeaders {
+			if release, err = releaseDate(ctx, metadata, fetchRelease); err != nil {
+				log.Error(ctx, "Failed to get the release date of the file", err)
+			}
+		}
+
+		var versionID string
+		var mark *watermark.Mark
+		var audit *downloadAudit
+		download, downloadRange, downloadPrecompressed, downloadMoved := downloadFileFromBucket, downloadFileRange, downloadVariant, downloadMovedFile
+
+		if cfg.IsPublishing {
+			entityData, logData, ok := authenticate(ctx, w, req, authMiddleware, accessToken)
+			if !ok {
 				return
 			}
 
 			permissionAttrs := setPermissionsAttributes(metadata)
 
-			logData["entity_data"] = entityData
-
 			if checkUserPermission(ctx, logData, "static-files:read", permissionAttrs, permissionsChecker, entityData) {
-				// Passing identifier as both user and email parameters as the identity client only provides a single identifier
-				err = recordFileEvent(ctx, *entityData, accessToken, requestedFilePath, metadata, w, createFileEvent)
-				if err != nil {
-					return
+				// Only authorised users in the publishing environment may pin a download to a specific version of a file
+				versionID = req.URL.Query().Get("version-id")
+				var watermarkID string
+				if mustWatermark(metadata, cfg) {
+					m := watermark.New(entityData.UserID, currentTime(clock))
+					mark, watermarkID = &m, m.ID
 				}
-				// Service auth tokens cannot access this endpoint so will be user tokens only if the request has reached this point
-				logAuth := log.Auth(log.USER, entityData.UserID)
-				logData["filePath"] = requestedFilePath
-				log.Info(ctx, "Successfully created file event for download", log.Classification(log.ProtectiveMonitoring), logAuth, logData)
+				// The file event is recorded once the file is opened, so that it records the version of the object served
+				audit = &downloadAudit{ctx: ctx, record: func(servedVersionID string) error {
+					resource := files.EventResource{Path: requestedFilePath, VersionID: servedVersionID, WatermarkID: watermarkID}
+					if err := createReadEvent(ctx, *entityData, accessToken, resource, metadata, createFileEvent); err != nil {
+						log.Error(ctx, "Failed to create file event", err, log.Data{"filePath": requestedFilePath})
+						return err
+					}
+					// Service auth tokens cannot access this endpoint so will be user tokens only if the request has reached this point
+					logAuth := log.Auth(log.USER, entityData.UserID)
+					logData["filePath"] = requestedFilePath
+					logData["version_id"] = servedVersionID
+					log.Info(ctx, "Successfully created file event for download", log.Classification(log.ProtectiveMonitoring), logAuth, logData)
+					if files.Withdrawn(metadata) {
+						log.Info(ctx, "Downloading file that has been taken down", log.Classification(log.ProtectiveMonitoring), logAuth, log.Data{"filePath": requestedFilePath, "state": metadata.State})
+					}
+					return nil
+				}}
+				download, downloadMoved = audit.file(download, true), audit.file(downloadMoved, false)
+				downloadRange, downloadPrecompressed = audit.fileRange(downloadRange), audit.variant(downloadPrecompressed)
+				defer audit.attempted(versionID)
 			} else {
 				log.Info(ctx, "authorisation failed: request has no permission", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), logData)
 				handleError(ctx, "the request was not authorised - check token and user's permissions", w, files.ErrNotAuthorised)
@@ -78,20 +129,52 @@ func CreateDownloadHandlerWithAuth(fetchMetadata files.MetadataFetcher, download
 			}
 		}
 
+		prov := fileProvenance(ctx, requestedFilePath, metadata, release, fetchMetadata, filesAPISDK.Headers{Authorization: accessToken}, fetchRelease, clock, cfg)
+
+		if convertFile(ctx, w, req, metadata, requestedFilePath, versionID, format, filter, prov, mark, cfg, download, downloadMoved) {
+			return
+		}
+
+		if audit != nil && files.Moved(metadata) {
+			// Moved files are redirected to rather than read, so the version served is not known
+			if err = audit.record(versionID); err != nil {
+				handleError(ctx, "Failed to create file event", w, err)
+				return
+			}
+			audit.recorded = true
+		}
+
 		if handleUnsupportedMetadataStates(ctx, *metadata, cfg, requestedFilePath, w) {
 			return
 		}
 
-		streamFile(ctx, w, metadata, requestedFilePath, downloadFileFromBucket)
+		dispositionType := disposition.Type(req, metadata.Type, cfg.InlineDisposition)
+		if mark != nil {
+			streamWatermarkedFile(ctx, w, metadata, prov, dispositionType, requestedFilePath, versionID, *mark, download)
+			return
+		}
+		streamFile(ctx, w, req, metadata, prov, dispositionType, requestedFilePath, versionID, download, downloadRange, downloadPrecompressed)
 	}
 }
 
 // CreateDownloadHandlerNoAuth handles file requests in the web environment, the handleUnsupportedMetadataStates ensures no unpublished files are returned.
-func CreateDownloadHandlerNoAuth(fetchMetadata files.MetadataFetcher, downloadFileFromBucket files.FileDownloader, cfg *config.Config) http.HandlerFunc {
+func CreateDownloadHandlerNoAuth(fetchMetadata files.MetadataFetcher, downloadFileFromBucket files.FileDownloader, downloadFileRange files.FileRangeDownloader, downloadVariant files.FileVariantDownloader, downloadMovedFile files.FileDownloader, fetchWithdrawal files.WithdrawalFetcher, fetchRelease files.ReleaseFetcher, clock func() time.Time, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		ctx, requestedFilePath := parseRequest(req)
 		log.Info(ctx, fmt.Sprintf("Handling request for %s", requestedFilePath))
 
+		format, err := tabular.NegotiateFormat(req.URL.Query().Get("format"), req.Header.Get("Accept"))
+		if err != nil {
+			writeError(w, buildErrors(err, "InvalidFormat"), http.StatusBadRequest)
+			return
+		}
+
+		filter, err := tabular.ParseFilter(req.URL.Query())
+		if err != nil {
+			writeError(w, buildErrors(err, "InvalidFilter"), http.StatusBadRequest)
+			return
+		}
+
 		metadata, err := fetchMetadata(ctx, requestedFilePath, filesAPISDK.Headers{})
 		if err != nil {
 			handleMetadataError(ctx, w, err)
@@ -100,11 +183,27 @@ func CreateDownloadHandlerNoAuth(fetchMetadata files.MetadataFetcher, downloadFi
 
 		log.Info(ctx, fmt.Sprintf("Found metadata for file %s", requestedFilePath), log.Data{"metadata": metadata})
 
+		if handleWithdrawnFile(ctx, w, metadata, requestedFilePath, fetchWithdrawal) {
+			return
+		}
+
+		release, embargoed := handleEmbargoedFile(ctx, w, metadata, fetchRelease, clock)
+		if embargoed {
+			return
+		}
+
+		prov := fileProvenance(ctx, requestedFilePath, metadata, release, fetchMetadata, filesAPISDK.Headers{}, fetchRelease, clock, cfg)
+
+		if convertFile(ctx, w, req, metadata, requestedFilePath, "", format, filter, prov, nil, cfg, downloadFileFromBucket, downloadMovedFile) {
+			return
+		}
+
 		if handleUnsupportedMetadataStatesWeb(ctx, *metadata, cfg, requestedFilePath, w) {
 			return
 		}
 
-		streamFile(ctx, w, metadata, requestedFilePath, downloadFileFromBucket)
+		dispositionType := disposition.Type(req, metadata.Type, cfg.InlineDisposition)
+		streamFile(ctx, w, req, metadata, prov, dispositionType, requestedFilePath, "", downloadFileFromBucket, downloadFileRange, downloadVariant)
 	}
 }
 
@@ -121,32 +220,189 @@ func setPermissionsAttributes(metadata *filesAPIModels.StoredRegisteredMetaData)
 	return permissionAttrs
 }
 
-func recordFileEvent(ctx context.Context, entityData permissionsAPISDK.EntityData, accessToken, requestedFilePath string, metadata *filesAPIModels.StoredRegisteredMetaData, w http.ResponseWriter, createFileEvent files.FileEventCreator) error {
-	auditEvent, err := files.PopulateFileEvent(entityData.UserID, entityData.UserID, requestedFilePath, filesAPIModels.ActionRead, metadata)
+func recordFileEvent(ctx context.Context, entityData permissionsAPISDK.EntityData, accessToken string, resource files.EventResource, metadata *filesAPIModels.StoredRegisteredMetaData, w http.ResponseWriter, createFileEvent files.FileEventCreator) error {
+	err := createReadEvent(ctx, entityData, accessToken, resource, metadata, createFileEvent)
 	if err != nil {
-		handleError(ctx, "Failed to populate file event", w, err)
+		handleError(ctx, "Failed to create file event", w, err)
 		return err
 	}
 
-	_, err = createFileEvent(ctx, auditEvent, filesAPISDK.Headers{Authorization: accessToken})
+	return nil
+}
+
+func createReadEvent(ctx context.Context, entityData permissionsAPISDK.EntityData, accessToken string, resource files.EventResource, metadata *filesAPIModels.StoredRegisteredMetaData, createFileEvent files.FileEventCreator) error {
+	// Passing identifier as both user and email parameters as the identity client only provides a single identifier
+	auditEvent, err := files.PopulateFileEvent(entityData.UserID, entityData.UserID, resource.String(), filesAPIModels.ActionRead, metadata)
 	if err != nil {
-		handleError(ctx, "Failed to create file event", w, err)
 		return err
 	}
 
-	return nil
+	_, err = createFileEvent(ctx, auditEvent, filesAPISDK.Headers{Authorization: accessToken})
+	var apiErr *filesAPISDK.APIError
+	if _, ok := legacy(ctx); ok && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		// Legacy downloads are not registered with the files API, which may refuse events for them
+		log.Warn(ctx, "files API does not know the legacy download, so no file event was created", log.Data{"filePath": resource.Path, "error": err.Error()})
+		return nil
+	}
+	return err
+}
+
+// downloadAudit records the file event for a download when the file is first opened. The version of the object read
+// is taken from the S3 response, so the event records the version served even when the download is not pinned to one.
+type downloadAudit struct {
+	ctx      context.Context
+	record   func(versionID string) error
+	recorded bool
+}
+
+// opened records the file event for a file that has been opened. If the event cannot be recorded the file is closed
+// and the error returned, so that the file is not served without an audit record.
+func (a *downloadAudit) opened(file io.ReadCloser, err error, versionID string) (io.ReadCloser, error) {
+	if err != nil || a.recorded {
+		return file, err
+	}
+
+	if err = a.record(versionID); err != nil {
+		closeDownloadedFile(a.ctx, file)
+		return nil, err
+	}
+
+	a.recorded = true
+	return file, nil
+}
+
+// attempted records the file event for a request that did not open the file, such as one for a file that has not
+// finished uploading or that failed to download, with the requested version. The response has already been written,
+// so an error is only logged.
+func (a *downloadAudit) attempted(versionID string) {
+	if a.recorded {
+		return
+	}
+	if err := a.record(versionID); err == nil {
+		a.recorded = true
+	}
+}
+
+// file wraps a FileDownloader so that the event is recorded when the file is opened. Files read from the bucket
+// record the version of the object read, while files read from elsewhere record the requested version.
+func (a *downloadAudit) file(download files.FileDownloader, fromBucket bool) files.FileDownloader {
+	return func(path, versionID string) (io.ReadCloser, error) {
+		file, err := download(path, versionID)
+		if fromBucket {
+			versionID = servedVersion(file, versionID)
+		}
+		return a.opened(file, err, versionID)
+	}
+}
+
+func (a *downloadAudit) fileRange(download files.FileRangeDownloader) files.FileRangeDownloader {
+	if download == nil {
+		return nil
+	}
+	return func(path, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+		file, size, err := download(path, versionID, offset, length)
+		file, err = a.opened(file, err, servedVersion(file, versionID))
+		return file, size, err
+	}
+}
+
+// variant wraps a FileVariantDownloader as for file. A precompressed variant is a separate object, so its version is
+// not the version of the file and is not recorded.
+func (a *downloadAudit) variant(download files.FileVariantDownloader) files.FileVariantDownloader {
+	if download == nil {
+		return nil
+	}
+	return func(path string, encodings []string) (io.ReadCloser, string, *int64, error) {
+		file, encoding, size, err := download(path, encodings)
+		versionID := ""
+		if encoding == "" {
+			versionID = servedVersion(file, "")
+		}
+		file, err = a.opened(file, err, versionID)
+		return file, encoding, size, err
+	}
+}
+
+// servedVersion returns the version ID of the object the file was read from, or the requested version if the bucket
+// did not return one
+func servedVersion(file io.ReadCloser, requested string) string {
+	if info, ok := content.ObjectOf(file); ok && info.VersionID != "" {
+		return info.VersionID
+	}
+	return requested
+}
+
+// streamFile writes the file to the response. If a range downloader is provided, a single byte range requested with
+// the Range header is served as partial content; without one the whole file is always returned. If a variant
+// downloader is provided, a precompressed variant of a text file matching the Accept-Encoding header is returned when
+// one is stored alongside the file. The version of the object read from the bucket is returned in VersionIDHeader.
+//
+// If a versionID is given exactly that version of the file is returned, see streamFileVersion.
+func streamFile(ctx context.Context, w http.ResponseWriter, req *http.Request, metadata *filesAPIModels.StoredRegisteredMetaData, prov func() provenance.Headers, dispositionType, requestedFilePath, versionID string, downloadFileFromBucket files.FileDownloader, downloadFileRange files.FileRangeDownloader, downloadVariant files.FileVariantDownloader) {
+	setContentHeaders(ctx, w, *metadata, dispositionType, prov)
+
+	if versionID != "" {
+		// The metadata describes the current version, so the length of an earlier version is only known once it is read
+		w.Header().Del("Content-Length")
+		if downloadFileRange != nil {
+			streamFileVersion(ctx, w, req, requestedFilePath, versionID, downloadFileRange)
+			return
+		}
+	}
+
+	if downloadFileRange != nil {
+		w.Header().Set("Accept-Ranges", "bytes")
+
+		if rangeHeader := req.Header.Get("Range"); rangeHeader != "" {
+			streamFileRange(ctx, w, rangeHeader, int64(metadata.SizeInBytes), requestedFilePath, "", downloadFileRange)
+			return
+		}
+	}
+
+	if downloadVariant != nil && versionID == "" && compressible(metadata.Type) {
+		streamFileVariant(ctx, w, req, requestedFilePath, downloadVariant)
+		return
+	}
+
+	file, err := downloadFileFromBucket(requestedFilePath, versionID)
+	if err != nil {
+		handleError(ctx, fmt.Sprintf("Error downloading file %s", requestedFilePath), w, err)
+		return
+	}
+
+	defer closeDownloadedFile(ctx, file)
+	setVersionIDHeader(w, file, versionID)
+
+	err = writeFileToResponse(w, file)
+	if err != nil {
+		log.Error(ctx, "Failed to stream file content", err)
+		setStatusInternalServerError(w)
+		return
+	}
 }
 
-func streamFile(ctx context.Context, w http.ResponseWriter, metadata *filesAPIModels.StoredRegisteredMetaData, requestedFilePath string, downloadFileFromBucket files.FileDownloader) {
-	setContentHeaders(w, *metadata)
+// streamFileVersion writes a specific version of the file to the response. The size of the version is returned by the
+// bucket when it is opened, so a requested range is read separately once the size is known.
+func streamFileVersion(ctx context.Context, w http.ResponseWriter, req *http.Request, requestedFilePath, versionID string, downloadFileRange files.FileRangeDownloader) {
+	w.Header().Set("Accept-Ranges", "bytes")
 
-	file, err := downloadFileFromBucket(requestedFilePath)
+	file, size, err := downloadFileRange(requestedFilePath, versionID, 0, -1)
 	if err != nil {
 		handleError(ctx, fmt.Sprintf("Error downloading file %s", requestedFilePath), w, err)
 		return
 	}
 
+	if rangeHeader := req.Header.Get("Range"); rangeHeader != "" && size != nil {
+		closeDownloadedFile(ctx, file)
+		streamFileRange(ctx, w, rangeHeader, *size, requestedFilePath, versionID, downloadFileRange)
+		return
+	}
+
 	defer closeDownloadedFile(ctx, file)
+	setVersionIDHeader(w, file, versionID)
+	if size != nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(*size, 10))
+	}
 
 	err = writeFileToResponse(w, file)
 	if err != nil {
@@ -156,6 +412,137 @@ func streamFile(ctx context.Context, w http.ResponseWriter, metadata *filesAPIMo
 	}
 }
 
+// setVersionIDHeader sets VersionIDHeader to the version of the object the file was read from, or the requested
+// version if the bucket did not return one
+func setVersionIDHeader(w http.ResponseWriter, file io.ReadCloser, versionID string) {
+	if served := servedVersion(file, versionID); served != "" {
+		w.Header().Set(VersionIDHeader, served)
+	}
+}
+
+// mustWatermark reports whether a file downloaded in the current environment must be watermarked. Pre-release copies
+// are watermarked so that leaked copies can be traced to the user given them.
+func mustWatermark(m *filesAPIModels.StoredRegisteredMetaData, cfg *config.Config) bool {
+	return cfg.IsPublishing && cfg.WatermarkPreRelease && files.Uploaded(m) && watermark.Supported(m.Type)
+}
+
+// streamWatermarkedFile writes the file to the response with the watermark added. The length of the watermarked file
+// is not known in advance, so range requests and precompressed variants are not supported.
+func streamWatermarkedFile(ctx context.Context, w http.ResponseWriter, metadata *filesAPIModels.StoredRegisteredMetaData, prov func() provenance.Headers, dispositionType, requestedFilePath, versionID string, mark watermark.Mark, downloadFileFromBucket files.FileDownloader) {
+	setContentHeaders(ctx, w, *metadata, dispositionType, prov)
+	w.Header().Del("Content-Length")
+	w.Header().Set(watermark.IDHeader, mark.ID)
+
+	file, err := downloadFileFromBucket(requestedFilePath, versionID)
+	if err != nil {
+		handleError(ctx, fmt.Sprintf("Error downloading file %s", requestedFilePath), w, err)
+		return
+	}
+
+	defer closeDownloadedFile(ctx, file)
+	setVersionIDHeader(w, file, versionID)
+
+	log.Info(ctx, "Watermarking pre-release file", log.Data{"filePath": requestedFilePath, "watermark": mark.ID})
+	if err = mark.Write(w, file, metadata.Type); err != nil {
+		log.Error(ctx, "Failed to write watermarked file", err, log.Data{"filePath": requestedFilePath, "watermark": mark.ID})
+		setStatusInternalServerError(w)
+	}
+}
+
+func streamFileRange(ctx context.Context, w http.ResponseWriter, rangeHeader string, size int64, requestedFilePath, versionID string, downloadFileRange files.FileRangeDownloader) {
+	offset, length, err := parseRange(rangeHeader, size)
+	if err != nil {
+		log.Info(ctx, "Requested range not satisfiable", log.Data{"range": rangeHeader, "size": size})
+		setStatusRangeNotSatisfiable(size, w)
+		return
+	}
+
+	file, _, err := downloadFileRange(requestedFilePath, versionID, offset, length)
+	if err != nil {
+		handleError(ctx, fmt.Sprintf("Error downloading file %s", requestedFilePath), w, err)
+		return
+	}
+
+	defer closeDownloadedFile(ctx, file)
+	setVersionIDHeader(w, file, versionID)
+
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+	w.WriteHeader(http.StatusPartialContent)
+
+	err = writeFileToResponse(w, file)
+	if err != nil {
+		log.Error(ctx, "Failed to stream file content", err)
+		return
+	}
+}
+
+func streamFileVariant(ctx context.Context, w http.ResponseWriter, req *http.Request, requestedFilePath string, downloadVariant files.FileVariantDownloader) {
+	addVary(w.Header(), "Accept-Encoding")
+
+	file, encoding, size, err := downloadVariant(requestedFilePath, acceptedEncodings(req.Header.Get("Accept-Encoding"), files.PrecompressedEncodings))
+	if err != nil {
+		handleError(ctx, fmt.Sprintf("Error downloading file %s", requestedFilePath), w, err)
+		return
+	}
+
+	defer closeDownloadedFile(ctx, file)
+
+	if encoding != "" {
+		log.Info(ctx, "Serving precompressed variant of file", log.Data{"filePath": requestedFilePath, "encoding": encoding})
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Del("Content-Length")
+		if size != nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(*size, 10))
+		}
+	}
+
+	err = writeFileToResponse(w, file)
+	if err != nil {
+		log.Error(ctx, "Failed to stream file content", err)
+		return
+	}
+}
+
+// parseRange parses a single byte range in the form "bytes=start-end", "bytes=start-" or "bytes=-suffix", returning
+// the offset and length of the range within a file of the given size. Multiple ranges are not supported.
+func parseRange(header string, size int64) (offset, length int64, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, errInvalidRange
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, errInvalidRange
+	}
+
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, errInvalidRange
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, nil
+	}
+
+	offset, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 || offset >= size {
+		return 0, 0, errInvalidRange
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < offset {
+			return 0, 0, errInvalidRange
+		}
+		end = min(end, size-1)
+	}
+
+	return offset, end - offset + 1, nil
+}
+
 func handleMetadataError(ctx context.Context, w http.ResponseWriter, err error) {
 	switch {
 	case strings.Contains(err.Error(), files.ErrFileNotRegistered.Error()):
@@ -178,6 +565,9 @@ func handleMetadataError(ctx context.Context, w http.ResponseWriter, err error)
 func parseRequest(req *http.Request) (ctx context.Context, filePath string) {
 	ctx = req.Context()
 	filePath = mux.Vars(req)["path"]
+	if download, ok := legacy(ctx); ok {
+		filePath = download.path
+	}
 
 	authHeaderValue := req.Header.Get(dprequest.AuthHeaderKey)
 	if authHeaderValue != "" {
@@ -191,7 +581,7 @@ func parseRequest(req *http.Request) (ctx context.Context, filePath string) {
 func handleUnsupportedMetadataStates(ctx context.Context, m filesAPIModels.StoredRegisteredMetaData, cfg *config.Config, filePath string, w http.ResponseWriter) bool {
 	if files.Moved(&m) {
 		log.Info(ctx, "File moved, redirecting")
-		setStatusMovedPermanently(RedirectLocation(cfg, filePath), w)
+		setStatusMovedPermanently(movedLocation(ctx, cfg, filePath), w)
 		return true
 	}
 
@@ -213,7 +603,7 @@ func handleUnsupportedMetadataStates(ctx context.Context, m filesAPIModels.Store
 func handleUnsupportedMetadataStatesWeb(ctx context.Context, m filesAPIModels.StoredRegisteredMetaData, cfg *config.Config, filePath string, w http.ResponseWriter) bool {
 	if files.Moved(&m) {
 		log.Info(ctx, "File moved, redirecting")
-		setStatusMovedPermanently(RedirectLocation(cfg, filePath), w)
+		setStatusMovedPermanently(movedLocation(ctx, cfg, filePath), w)
 		return true
 	}
 
@@ -243,6 +633,12 @@ func setStatusNotFound(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNotFound)
 }
 
+func setStatusRangeNotSatisfiable(size int64, w http.ResponseWriter) {
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+}
+
 func setStatusInternalServerError(w http.ResponseWriter) {
 	w.Header().Set("Cache-Control", "no-cache")
 	w.WriteHeader(http.StatusInternalServerError)
@@ -267,8 +663,69 @@ func isWebMode(cfg *config.Config) bool {
 	return !cfg.IsPublishing
 }
 
-func setContentHeaders(w http.ResponseWriter, m filesAPIModels.StoredRegisteredMetaData) {
+// setContentHeaders sets the headers describing the content of a file. The length of legacy downloads is not known.
+func setContentHeaders(ctx context.Context, w http.ResponseWriter, m filesAPIModels.StoredRegisteredMetaData, dispositionType string, prov func() provenance.Headers) {
 	w.Header().Set("Content-Type", m.Type)
-	w.Header().Set("Content-Length", files.GetContentLength(&m))
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", files.GetFilename(&m)))
+	if _, ok := legacy(ctx); !ok {
+		w.Header().Set("Content-Length", files.GetContentLength(&m))
+	}
+	w.Header().Set("Content-Disposition", disposition.Header(dispositionType, downloadFilename(ctx, &m)))
+	prov().Set(w.Header())
+}
+
+// fileProvenance returns a function returning the licence of a file, falling back to the default licence, and the CSVW
+// metadata describing it if it is a CSV file with metadata that can be downloaded. Looking up the CSVW metadata is
+// another request to the files API, so it is only made when CSVW links are enabled, and only when the function is
+// called, so it is not made for requests that fail or are redirected before the headers are set. The title, release
+// date and dataset version of the file are only included if custom provenance headers are enabled.
+func fileProvenance(ctx context.Context, requestedFilePath string, m *filesAPIModels.StoredRegisteredMetaData, release *time.Time, fetchMetadata files.MetadataFetcher, headers filesAPISDK.Headers, fetchRelease files.ReleaseFetcher, clock func() time.Time, cfg *config.Config) func() provenance.Headers {
+	return func() provenance.Headers {
+		return provenanceOf(ctx, requestedFilePath, m, release, fetchMetadata, headers, fetchRelease, clock, cfg)
+	}
+}
+
+func provenanceOf(ctx context.Context, requestedFilePath string, m *filesAPIModels.StoredRegisteredMetaData, release *time.Time, fetchMetadata files.MetadataFetcher, headers filesAPISDK.Headers, fetchRelease files.ReleaseFetcher, clock func() time.Time, cfg *config.Config) provenance.Headers {
+	prov := provenance.Headers{LicenceURL: m.LicenceURL}
+	if prov.LicenceURL == "" {
+		prov.LicenceURL = cfg.DefaultLicenceURL
+	}
+
+	if download, ok := legacy(ctx); ok {
+		prov.DescribedBy = download.describedBy
+	} else if cfg.CSVWLinks && isCSV(m) {
+		csvwPath := requestedFilePath + csvwSuffix
+		if csvw, err := fetchMetadata(ctx, csvwPath, headers); err == nil && downloadable(ctx, csvw, fetchRelease, clock, cfg) {
+			prov.DescribedBy = "/downloads/files/" + csvwPath
+		}
+	}
+
+	if cfg.ProvenanceHeaders {
+		prov.Title = m.Title
+		if release != nil {
+			prov.ReleaseDate = release.UTC().Format(time.RFC3339)
+		}
+		if m.ContentItem != nil {
+			prov.DatasetID = m.ContentItem.DatasetID
+			prov.Edition = m.ContentItem.Edition
+			prov.Version = m.ContentItem.Version
+		}
+	}
+
+	return prov
+}
+
+// downloadable reports whether a file would be served rather than answered with 404 or 410, so that it can be linked
+// to. In the web environment withdrawn files and files that have not been released yet are not served.
+func downloadable(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData, fetchRelease files.ReleaseFetcher, clock func() time.Time, cfg *config.Config) bool {
+	if unavailable(m, cfg) {
+		return false
+	}
+	if !isWebMode(cfg) {
+		return true
+	}
+	if files.Withdrawn(m) {
+		return false
+	}
+	release, err := releaseDate(ctx, m, fetchRelease)
+	return err == nil && !files.Embargoed(release, currentTime(clock))
 }
@@ -10,17 +10,23 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
 	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
 
 	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/content"
 	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/provenance"
 	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
 	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
 	dprequest "github.com/ONSdigital/dp-net/v3/request"
 	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
 	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -101,9 +107,9 @@ func TestHandlingForbiddenErrorFetchingMetadata(t *testing.T) {
 		return nil, nil
 	}
 
-	downloadFile := func(path string) (io.ReadCloser, error) { return nil, nil }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, nil }
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, nil, &config.Config{}, nil)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, nil, &config.Config{}, nil)
 	h.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusForbidden, rec.status)
@@ -123,9 +129,9 @@ func TestHandlingNotAuthorisedErrorFetchingMetadata(t *testing.T) {
 		return nil, nil
 	}
 
-	downloadFile := func(path string) (io.ReadCloser, error) { return nil, nil }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, nil }
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, nil, &config.Config{}, nil)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, nil, &config.Config{}, nil)
 	h.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusUnauthorized, rec.status)
@@ -152,9 +158,9 @@ func TestHandlingGetAuthEntityFails(t *testing.T) {
 		},
 	}
 
-	downloadFile := func(path string) (io.ReadCloser, error) { return nil, nil }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, nil }
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, authorisationMock, &config.Config{IsPublishing: true}, nil)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, nil)
 	h.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusUnauthorized, rec.status)
@@ -191,9 +197,11 @@ func TestHandlingCheckUserPermissionsSuccess(t *testing.T) {
 			return true, nil
 		},
 	}
-	downloadFile := func(path string) (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("testing")), nil }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("testing")), nil
+	}
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
 	h.ServeHTTP(rec, req)
 	assert.Equalf(t, http.StatusOK, rec.Code, "CreateDownloadHandler(%v)", "Test UPLOADED")
 	assert.True(t, createFileEventCalled, "createFileEvent should have been called")
@@ -224,9 +232,11 @@ func TestHandlingCheckUserPermissionsFails(t *testing.T) {
 			return false, nil
 		},
 	}
-	downloadFile := func(path string) (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("testing")), nil }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("testing")), nil
+	}
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
 	h.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusForbidden, rec.status)
 }
@@ -244,9 +254,9 @@ func TestHandlingUnexpectedErrorFetchingMetadata(t *testing.T) {
 		return nil, nil
 	}
 
-	downloadFile := func(path string) (io.ReadCloser, error) { return nil, nil }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, nil }
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, nil, &config.Config{}, nil)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, nil, &config.Config{}, nil)
 	h.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.status)
@@ -265,9 +275,9 @@ func TestHandlingErrorGettingFileContent(t *testing.T) {
 		return nil, nil
 	}
 
-	downloadFile := func(path string) (io.ReadCloser, error) { return nil, errors.New("error downloading file") }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, errors.New("error downloading file") }
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, nil, &config.Config{}, nil)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, nil, &config.Config{}, nil)
 	h.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.status)
@@ -287,9 +297,9 @@ func TestHandlingErrorGettingFileNotAvailable(t *testing.T) {
 		return nil, nil
 	}
 
-	downloadFile := func(path string) (io.ReadCloser, error) { return nil, errors.New("error downloading file") }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, errors.New("error downloading file") }
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, nil, &config.Config{}, nil)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, nil, &config.Config{}, nil)
 	h.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusNotFound, rec.status)
@@ -314,9 +324,9 @@ func TestHandleFileNotPublishedWeb(t *testing.T) {
 				return nil, files.ErrFileNotRegistered
 			}
 
-			downloadFile := func(path string) (io.ReadCloser, error) { return nil, nil }
+			downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, nil }
 
-			h := CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, &config.Config{})
+			h := CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, nil, nil, nil, nil, nil, nil, &config.Config{})
 			h.ServeHTTP(rec, req)
 
 			assert.Equalf(t, tt.expectedStatus, rec.status, "CreateDownloadHandler(%v)", tt.name)
@@ -353,9 +363,9 @@ func TestHandleFileNotPublishedInPublishingMode(t *testing.T) {
 			return nil, nil
 		}
 
-		downloadFile := func(path string) (io.ReadCloser, error) { return FailingReadCloser{}, nil }
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) { return FailingReadCloser{}, nil }
 
-		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
 		h.ServeHTTP(rec, req)
 
 		assert.Equalf(t, http.StatusNotFound, rec.status, "CreateDownloadHandler(%v)", "Test CREATED")
@@ -379,9 +389,11 @@ func TestHandleFileNotPublishedInPublishingMode(t *testing.T) {
 			return nil, nil
 		}
 
-		downloadFile := func(path string) (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("testing")), nil }
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("testing")), nil
+		}
 
-		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
 		h.ServeHTTP(rec, req)
 
 		assert.Equalf(t, http.StatusOK, rec.Code, "CreateDownloadHandler(%v)", "Test UPLOADED")
@@ -404,9 +416,9 @@ func TestHandleFileNotPublishedInPublishingMode(t *testing.T) {
 			return nil, nil
 		}
 
-		downloadFile := func(path string) (io.ReadCloser, error) { return nil, errors.New("error downloading file") }
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, errors.New("error downloading file") }
 
-		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
 		h.ServeHTTP(rec, req)
 
 		assert.Equalf(t, http.StatusInternalServerError, rec.status, "CreateDownloadHandler(%v)", "Test UPLOADED but download fails")
@@ -430,9 +442,9 @@ func TestContentTypeHeader(t *testing.T) {
 		return nil, nil
 	}
 
-	downloadFile := func(path string) (io.ReadCloser, error) { return FailingReadCloser{}, nil }
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) { return FailingReadCloser{}, nil }
 
-	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, createFileEvent, nil, &config.Config{}, nil)
+	h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, nil, &config.Config{}, nil)
 	h.ServeHTTP(rec, req)
 
 	assert.Equal(t, expectedType, rec.Header().Get("Content-Type"))
@@ -473,3 +485,545 @@ func TestRedirectLocation(t *testing.T) {
 		assert.Equal(t, expectedUrl, concatenatedUrl, fmt.Sprintf("testing %s: expected %s, got %s", test.desc, expectedUrl, concatenatedUrl))
 	}
 }
+
+func TestRangeRequests(t *testing.T) {
+	content := "0123456789"
+
+	fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		return &filesAPIModels.StoredRegisteredMetaData{Type: "text/csv", SizeInBytes: uint64(len(content)), State: files.PUBLISHED}, nil
+	}
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(content)), nil
+	}
+	downloadFileRange := func(path, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+		size := int64(len(content))
+		return io.NopCloser(strings.NewReader(content[offset : offset+length])), &size, nil
+	}
+
+	tests := []struct {
+		name          string
+		rangeHeader   string
+		expectedCode  int
+		expectedBody  string
+		expectedRange string
+	}{
+		{"bounded range", "bytes=2-4", http.StatusPartialContent, "234", "bytes 2-4/10"},
+		{"open ended range", "bytes=7-", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"suffix range", "bytes=-2", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"range past the end is truncated", "bytes=8-20", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"range starting past the end", "bytes=10-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"multiple ranges", "bytes=0-1,4-5", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"no range", "", http.StatusOK, content, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.csv", http.NoBody)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			rec := httptest.NewRecorder()
+
+			h := CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, downloadFileRange, nil, nil, nil, nil, nil, &config.Config{})
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+			assert.Equal(t, tt.expectedRange, rec.Header().Get("Content-Range"))
+			assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+		})
+	}
+
+	t.Run("range is ignored without a range downloader", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.csv", http.NoBody)
+		req.Header.Set("Range", "bytes=2-4")
+		rec := httptest.NewRecorder()
+
+		h := CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, nil, nil, nil, nil, nil, nil, &config.Config{})
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, content, rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Accept-Ranges"))
+	})
+}
+
+func TestVersionedDownloads(t *testing.T) {
+	permissionsChecker := &authMock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+
+	fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		return &filesAPIModels.StoredRegisteredMetaData{Path: path, SizeInBytes: 100, State: files.PUBLISHED}, nil
+	}
+
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.csv?version-id=v1", http.NoBody)
+		req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+		req.Header.Set("Range", "bytes=0-1")
+		return req
+	}
+
+	t.Run("publishing users can download a specific version", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		var event filesAPIModels.FileEvent
+		createFileEvent := func(ctx context.Context, e filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			event = e
+			return &e, nil
+		}
+
+		var requestedVersion string
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+			requestedVersion = versionID
+			return io.NopCloser(strings.NewReader("old version")), nil
+		}
+
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+		h.ServeHTTP(rec, newRequest())
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "v1", requestedVersion)
+		assert.Equal(t, "v1", rec.Header().Get(VersionIDHeader))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.Equal(t, "old version", rec.Body.String())
+		resource, err := files.ParseEventResource(event.Resource)
+		assert.NoError(t, err)
+		assert.Equal(t, files.EventResource{Path: event.File.Path, VersionID: "v1"}, resource)
+	})
+
+	t.Run("ranges of a specific version use the size of that version", func(t *testing.T) {
+		old := "old version"
+		var events int
+		createFileEvent := func(ctx context.Context, e filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			events++
+			return &e, nil
+		}
+		downloadFileRange := func(path, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+			assert.Equal(t, "v1", versionID)
+			size := int64(len(old))
+			if length < 0 {
+				length = size - offset
+			}
+			return servedBody{ReadCloser: io.NopCloser(strings.NewReader(old[offset : offset+length])), versionID: "v1"}, &size, nil
+		}
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, nil, downloadFileRange, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+
+		rec := httptest.NewRecorder()
+		req := newRequest()
+		req.Header.Set("Range", "bytes=-7")
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+		assert.Equal(t, "bytes 4-10/11", rec.Header().Get("Content-Range"))
+		assert.Equal(t, "v1", rec.Header().Get(VersionIDHeader))
+		assert.Equal(t, "version", rec.Body.String())
+		assert.Equal(t, 1, events)
+
+		rec = httptest.NewRecorder()
+		req = newRequest()
+		req.Header.Del("Range")
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "11", rec.Header().Get("Content-Length"))
+		assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+		assert.Equal(t, old, rec.Body.String())
+	})
+
+	t.Run("the event records the version served when no version is requested", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.csv", http.NoBody)
+		req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+
+		var events []filesAPIModels.FileEvent
+		createFileEvent := func(ctx context.Context, e filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			events = append(events, e)
+			return &e, nil
+		}
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+			return servedBody{ReadCloser: io.NopCloser(strings.NewReader("current version")), versionID: "v2"}, nil
+		}
+
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "current version", rec.Body.String())
+		assert.Equal(t, "v2", rec.Header().Get(VersionIDHeader))
+		require.Len(t, events, 1)
+		resource, err := files.ParseEventResource(events[0].Resource)
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", resource.VersionID)
+	})
+
+	t.Run("the file is not served if the event cannot be recorded", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		createFileEvent := func(ctx context.Context, e filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			return nil, errors.New("files api unavailable")
+		}
+		closed := false
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+			return closeRecorder{Reader: strings.NewReader("old version"), closed: &closed}, nil
+		}
+
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+		h.ServeHTTP(rec, newRequest())
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "old version")
+		assert.True(t, closed)
+	})
+
+	t.Run("unknown versions are not found", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		createFileEvent := func(ctx context.Context, e filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			return &e, nil
+		}
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) { return nil, files.ErrVersionNotFound }
+
+		h := CreateDownloadHandlerWithAuth(fetchMetadata, downloadFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+		h.ServeHTTP(rec, newRequest())
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("the version is ignored in the web environment", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+			assert.Empty(t, versionID)
+			return io.NopCloser(strings.NewReader("current version")), nil
+		}
+
+		h := CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, nil, nil, nil, nil, nil, nil, &config.Config{})
+		h.ServeHTTP(rec, newRequest())
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(VersionIDHeader))
+	})
+}
+
+// servedBody is a file body that reports the version of the object it was read from, as bodies read from S3 do
+type servedBody struct {
+	io.ReadCloser
+	versionID string
+}
+
+func (b servedBody) Object() content.ObjectInfo {
+	return content.ObjectInfo{VersionID: b.versionID}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed *bool
+}
+
+func (c closeRecorder) Close() error {
+	*c.closed = true
+	return nil
+}
+
+func TestPrecompressedVariants(t *testing.T) {
+	content := "0123456789"
+	fileType := "text/csv"
+
+	fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		return &filesAPIModels.StoredRegisteredMetaData{Path: "data/file.csv", Type: fileType, SizeInBytes: uint64(len(content)), State: files.PUBLISHED}, nil
+	}
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(content)), nil
+	}
+	downloadFileRange := func(path, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+		size := int64(len(content))
+		return io.NopCloser(strings.NewReader(content[offset : offset+length])), &size, nil
+	}
+
+	var requestedEncodings []string
+	downloadVariant := func(path string, encodings []string) (io.ReadCloser, string, *int64, error) {
+		requestedEncodings = encodings
+		for _, encoding := range encodings {
+			if encoding == "gzip" {
+				size := int64(4)
+				return io.NopCloser(strings.NewReader("gzip")), encoding, &size, nil
+			}
+		}
+		return io.NopCloser(strings.NewReader(content)), "", nil, nil
+	}
+
+	h := CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, downloadFileRange, downloadVariant, nil, nil, nil, nil, &config.Config{})
+
+	t.Run("serves the variant matching Accept-Encoding", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.csv", http.NoBody)
+		req.Header.Set("Accept-Encoding", "gzip;q=0.5, br")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"br", "gzip"}, requestedEncodings)
+		assert.Equal(t, "gzip", rec.Body.String())
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "4", rec.Header().Get("Content-Length"))
+		assert.Equal(t, []string{"Accept", "Accept-Encoding"}, rec.Header().Values("Vary"))
+		assert.Equal(t, "attachment; filename=\"file.csv\"; filename*=UTF-8''file.csv", rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("falls back to the plain file", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.csv", http.NoBody)
+		req.Header.Set("Accept-Encoding", "zstd")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, content, rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "10", rec.Header().Get("Content-Length"))
+	})
+
+	t.Run("range requests are served from the plain file", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.csv", http.NoBody)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Range", "bytes=2-4")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+		assert.Equal(t, "234", rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	})
+
+	t.Run("variants are not looked for when the type is already compressed", func(t *testing.T) {
+		fileType = "application/zip"
+		defer func() { fileType = "text/csv" }()
+		requestedEncodings = nil
+
+		req, _ := http.NewRequest(http.MethodGet, "/downloads-new/data/file.zip", http.NoBody)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Nil(t, requestedEncodings)
+		assert.Equal(t, content, rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	})
+}
+
+func TestProvenanceHeaders(t *testing.T) {
+	var fetched []string
+	fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		fetched = append(fetched, path)
+		switch path {
+		case "data/file.csv", "data/file.csv-metadata.json", "data/other.csv", "data/withdrawn.csv", "data/embargoed.csv":
+			return &filesAPIModels.StoredRegisteredMetaData{
+				Path:        path,
+				Title:       "Consumer price inflation",
+				Type:        "text/csv",
+				LicenceURL:  "https://example.com/licence",
+				ContentItem: &filesAPIModels.StoredContentItem{DatasetID: "cpih01", Edition: "time-series", Version: "2"},
+				State:       files.PUBLISHED,
+			}, nil
+		case "data/withdrawn.csv-metadata.json":
+			return &filesAPIModels.StoredRegisteredMetaData{Path: path, Type: "application/csvm+json", State: files.WITHDRAWN}, nil
+		case "data/embargoed.csv-metadata.json":
+			return &filesAPIModels.StoredRegisteredMetaData{
+				Path:        path,
+				Type:        "application/csvm+json",
+				ContentItem: &filesAPIModels.StoredContentItem{DatasetID: "embargoed", Edition: "time-series", Version: "1"},
+				State:       files.PUBLISHED,
+			}, nil
+		case "data/unlicensed.pdf":
+			return &filesAPIModels.StoredRegisteredMetaData{Path: path, Type: "application/pdf", State: files.PUBLISHED}, nil
+		default:
+			return nil, files.ErrFileNotRegistered
+		}
+	}
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("a,b\n")), nil
+	}
+
+	fetchRelease := func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (*time.Time, error) {
+		if m.ContentItem == nil {
+			return nil, nil
+		}
+		release := time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC)
+		if m.ContentItem.DatasetID == "embargoed" {
+			release = time.Date(2026, 6, 1, 9, 30, 0, 0, time.UTC)
+		}
+		return &release, nil
+	}
+
+	serve := func(cfg *config.Config, target string) *httptest.ResponseRecorder {
+		r := mux.NewRouter()
+		h := CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, nil, nil, nil, nil, fetchRelease, clockAt(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)), cfg)
+		r.Path("/downloads/files/{path:.*}").HandlerFunc(h)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		return rec
+	}
+
+	t.Run("does not look up the CSVW metadata for responses without the headers", func(t *testing.T) {
+		fetched = nil
+		rec := serve(&config.Config{CSVWLinks: true}, "/downloads/files/data/file.csv?columns=missing")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, []string{"data/file.csv"}, fetched)
+	})
+
+	t.Run("does not look up the CSVW metadata unless CSVW links are enabled", func(t *testing.T) {
+		fetched = nil
+		rec := serve(&config.Config{}, "/downloads/files/data/file.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"data/file.csv"}, fetched)
+		assert.Equal(t, []string{`<https://example.com/licence>; rel="license"`}, rec.Header().Values("Link"))
+	})
+
+	t.Run("links the licence and CSVW metadata of a CSV file", func(t *testing.T) {
+		rec := serve(&config.Config{CSVWLinks: true}, "/downloads/files/data/file.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{
+			`<https://example.com/licence>; rel="license"`,
+			`</downloads/files/data/file.csv-metadata.json>; rel="describedby"; type="application/csvm+json"`,
+		}, rec.Header().Values("Link"))
+		assert.Empty(t, rec.Header().Get(provenance.TitleHeader))
+	})
+
+	t.Run("does not link CSVW metadata that does not exist", func(t *testing.T) {
+		rec := serve(&config.Config{CSVWLinks: true}, "/downloads/files/data/other.csv")
+
+		assert.Equal(t, []string{`<https://example.com/licence>; rel="license"`}, rec.Header().Values("Link"))
+	})
+
+	t.Run("does not link CSVW metadata that has been withdrawn", func(t *testing.T) {
+		rec := serve(&config.Config{CSVWLinks: true}, "/downloads/files/data/withdrawn.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{`<https://example.com/licence>; rel="license"`}, rec.Header().Values("Link"))
+	})
+
+	t.Run("does not link CSVW metadata that has not been released", func(t *testing.T) {
+		rec := serve(&config.Config{CSVWLinks: true}, "/downloads/files/data/embargoed.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{`<https://example.com/licence>; rel="license"`}, rec.Header().Values("Link"))
+	})
+
+	t.Run("links the default licence when the file has none", func(t *testing.T) {
+		rec := serve(&config.Config{DefaultLicenceURL: "https://example.com/default"}, "/downloads/files/data/unlicensed.pdf")
+
+		assert.Equal(t, []string{`<https://example.com/default>; rel="license"`}, rec.Header().Values("Link"))
+	})
+
+	t.Run("sets custom headers when they are enabled", func(t *testing.T) {
+		rec := serve(&config.Config{ProvenanceHeaders: true}, "/downloads/files/data/file.csv")
+
+		assert.Equal(t, "Consumer price inflation", rec.Header().Get(provenance.TitleHeader))
+		assert.Equal(t, "2026-03-04T09:30:00Z", rec.Header().Get(provenance.ReleaseDateHeader))
+		assert.Equal(t, "cpih01", rec.Header().Get(provenance.DatasetIDHeader))
+		assert.Equal(t, "time-series", rec.Header().Get(provenance.DatasetEditionHeader))
+		assert.Equal(t, "2", rec.Header().Get(provenance.DatasetVersionHeader))
+	})
+
+	t.Run("sets the release date of the dataset version of a file decoded from the files and dataset APIs", func(t *testing.T) {
+		datasetAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/datasets/cpih01/editions/time-series/versions/2" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"id": "v2", "state": "published", "release_date": "2026-03-04T09:30:00.000Z"}`)) // nolint
+		}))
+		defer datasetAPI.Close()
+
+		h := CreateDownloadHandlerNoAuth(fetchFilesAPIMetadata(t, filesAPIFile), downloadFile, nil, nil, nil, nil, files.FetchReleaseDate(dataset.NewAPIClient(datasetAPI.URL), "", ""), nil, &config.Config{ProvenanceHeaders: true})
+		r := mux.NewRouter()
+		r.Path("/downloads/files/{path:.*}").HandlerFunc(h)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/downloads/files/data/cpih.csv", http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Consumer price inflation", rec.Header().Get(provenance.TitleHeader))
+		assert.Equal(t, "2026-03-04T09:30:00Z", rec.Header().Get(provenance.ReleaseDateHeader))
+		assert.Equal(t, "cpih01", rec.Header().Get(provenance.DatasetIDHeader))
+	})
+
+	t.Run("adds the headers to converted files", func(t *testing.T) {
+		rec := serve(&config.Config{CSVWLinks: true}, "/downloads/files/data/file.csv?format=json")
+
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Len(t, rec.Header().Values("Link"), 2)
+	})
+}
+
+func TestContentDisposition(t *testing.T) {
+	fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		types := map[string]string{
+			"data/chwyddiant prisiau, cŵn.csv": "text/csv",
+			"data/report.pdf":                  "application/pdf",
+			"data/page.html":                   "text/html",
+		}
+		if contentType, ok := types[path]; ok {
+			return &filesAPIModels.StoredRegisteredMetaData{Path: path, Type: contentType, State: files.PUBLISHED}, nil
+		}
+		return nil, files.ErrFileNotRegistered
+	}
+	downloadFile := func(path, versionID string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("content")), nil
+	}
+
+	fetchRelease := func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (*time.Time, error) {
+		if m.ContentItem == nil {
+			return nil, nil
+		}
+		release := time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC)
+		return &release, nil
+	}
+
+	serve := func(cfg *config.Config, target string) *httptest.ResponseRecorder {
+		r := mux.NewRouter()
+		r.Path("/downloads/files/{path:.*}").HandlerFunc(CreateDownloadHandlerNoAuth(fetchMetadata, downloadFile, nil, nil, nil, nil, fetchRelease, nil, cfg))
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		return rec
+	}
+
+	t.Run("gives UTF-8 filenames with an ASCII fallback", func(t *testing.T) {
+		rec := serve(&config.Config{}, "/downloads/files/data/chwyddiant%20prisiau,%20c%C5%B5n.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename="chwyddiant prisiau, c_n.csv"; filename*=UTF-8''chwyddiant%20prisiau%2C%20c%C5%B5n.csv`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("displays files inline when requested", func(t *testing.T) {
+		rec := serve(&config.Config{}, "/downloads/files/data/report.pdf?disposition=inline")
+
+		assert.Equal(t, `inline; filename="report.pdf"; filename*=UTF-8''report.pdf`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("displays files inline by default when configured", func(t *testing.T) {
+		rec := serve(&config.Config{InlineDisposition: true}, "/downloads/files/data/report.pdf")
+
+		assert.Equal(t, `inline; filename="report.pdf"; filename*=UTF-8''report.pdf`, rec.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("does not display HTML inline", func(t *testing.T) {
+		rec := serve(&config.Config{InlineDisposition: true}, "/downloads/files/data/page.html?disposition=inline")
+
+		assert.Equal(t, `attachment; filename="page.html"; filename*=UTF-8''page.html`, rec.Header().Get("Content-Disposition"))
+	})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// currentTime returns the time from clock, or the current time if clock is nil
+func currentTime(clock func() time.Time) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return clock()
+}
+
+// releaseDate returns when a file is released, or nil if it has no release date. Legacy downloads are released at the
+// release date of the download and other files at the release date of the dataset version they belong to.
+func releaseDate(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData, fetchRelease files.ReleaseFetcher) (*time.Time, error) {
+	if download, ok := legacy(ctx); ok {
+		if download.releaseDate == "" {
+			return nil, nil
+		}
+		release, err := time.Parse(time.RFC3339, download.releaseDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release date %q: %w", download.releaseDate, err)
+		}
+		return &release, nil
+	}
+
+	if fetchRelease == nil {
+		return nil, nil
+	}
+	return fetchRelease(ctx, m)
+}
+
+// handleEmbargoedFile answers 404 for files that have not been released yet, returning false and the release date for
+// any other file. The response may be cached until the release time, but no longer, so the file is available as soon
+// as it is released. Files whose release date cannot be found are not served, as they may not have been released.
+// Whether the file has been released is decided using clock, see currentTime.
+func handleEmbargoedFile(ctx context.Context, w http.ResponseWriter, m *filesAPIModels.StoredRegisteredMetaData, fetchRelease files.ReleaseFetcher, clock func() time.Time) (*time.Time, bool) {
+	release, err := releaseDate(ctx, m, fetchRelease)
+	if err != nil {
+		handleError(ctx, "Failed to get the release date of the file", w, err)
+		return nil, true
+	}
+
+	t := currentTime(clock)
+	if !files.Embargoed(release, t) {
+		return release, false
+	}
+
+	log.Info(ctx, "File is embargoed until its release time", log.Data{"path": m.Path, "release": release})
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int64(release.Sub(t)/time.Second)))
+	w.Header().Set("Expires", release.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNotFound)
+	return release, true
+}
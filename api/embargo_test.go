@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var releaseTime = time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC)
+
+func fetchEmbargoedMetadata(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+	return &filesAPIModels.StoredRegisteredMetaData{
+		Path:        path,
+		Type:        "text/csv",
+		SizeInBytes: 4,
+		State:       files.PUBLISHED,
+	}, nil
+}
+
+func fetchTestRelease(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (*time.Time, error) {
+	return &releaseTime, nil
+}
+
+// clockAt returns a clock that always reads t
+func clockAt(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+func TestEmbargoWebMode(t *testing.T) {
+	newHandler := func(clock func() time.Time) http.HandlerFunc {
+		return CreateDownloadHandlerNoAuth(fetchEmbargoedMetadata, downloadTestFile, nil, nil, nil, nil, fetchTestRelease, clock, &config.Config{})
+	}
+
+	t.Run("published files are not found until their release time", func(t *testing.T) {
+		rec := serveFileRequest(newHandler(clockAt(releaseTime.Add(-90*time.Minute))), "/downloads/files/data/release.csv")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "max-age=5400", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, "Wed, 04 Mar 2026 09:30:00 GMT", rec.Header().Get("Expires"))
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("converted downloads are not found until the release time", func(t *testing.T) {
+		rec := serveFileRequest(newHandler(clockAt(releaseTime.Add(-time.Minute))), "/downloads/files/data/release.csv?format=json")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "max-age=60", rec.Header().Get("Cache-Control"))
+	})
+
+	t.Run("published files are downloaded from their release time", func(t *testing.T) {
+		rec := serveFileRequest(newHandler(clockAt(releaseTime)), "/downloads/files/data/release.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "a,b\n", rec.Body.String())
+	})
+
+	t.Run("the metadata, checksum and preview are not available until the release time", func(t *testing.T) {
+		clock := clockAt(releaseTime.Add(-time.Minute))
+
+		rec := serveMetadata(CreateMetadataHandler(fetchEmbargoedMetadata, nil, fetchTestRelease, clock, nil, &config.Config{}, nil), "/downloads/files/data/release.csv?meta")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "max-age=60", rec.Header().Get("Cache-Control"))
+
+		rec = serveChecksum(CreateChecksumHandler(fetchEmbargoedMetadata, fetchTestChecksum, nil, fetchTestRelease, clock, nil, &config.Config{}, nil), "/downloads/checksums/data/release.csv")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = servePreview(CreatePreviewHandler(fetchEmbargoedMetadata, downloadPreviewRange, downloadMovedPreview, nil, nil, fetchTestRelease, clock, nil, &config.Config{}, nil), "/downloads/preview/data/release.csv")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("bundles of files are not available until the release time", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		h := CreateBundleHandler(fetchEmbargoedMetadata, downloadBundleFile, downloadMovedBundleFile, nil, fetchTestRelease, clockAt(releaseTime.Add(-time.Minute)), nil, &config.Config{MaxBundleFiles: 2}, nil)
+		h.ServeHTTP(rec, newBundleRequest(`{"files": ["data/release.csv"]}`))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "FileNotAvailable")
+	})
+
+	t.Run("files are not served if their release date cannot be found", func(t *testing.T) {
+		fetchRelease := func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (*time.Time, error) {
+			return nil, errors.New("dataset API unavailable")
+		}
+		h := CreateDownloadHandlerNoAuth(fetchEmbargoedMetadata, downloadTestFile, nil, nil, nil, nil, fetchRelease, nil, &config.Config{})
+
+		rec := serveFileRequest(h, "/downloads/files/data/release.csv")
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("files decoded from the files API are embargoed until the release date of their dataset version", func(t *testing.T) {
+		datasetAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/datasets/cpih01/editions/time-series/versions/2" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"id": "v2", "state": "published", "release_date": "2026-03-04T09:30:00.000Z"}`)) // nolint
+		}))
+		defer datasetAPI.Close()
+
+		h := CreateDownloadHandlerNoAuth(fetchFilesAPIMetadata(t, filesAPIFile), downloadTestFile, nil, nil, nil, nil, files.FetchReleaseDate(dataset.NewAPIClient(datasetAPI.URL), "", ""), clockAt(releaseTime.Add(-time.Hour)), &config.Config{})
+		r := mux.NewRouter()
+		r.Path("/downloads/files/{path:.*}").HandlerFunc(h)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/downloads/files/data/cpih.csv", http.NoBody))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "max-age=3600", rec.Header().Get("Cache-Control"))
+	})
+}
+
+func TestEmbargoPublishingMode(t *testing.T) {
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+	permissionsChecker := &authMock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+			return true, nil
+		},
+	}
+	createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+		return &event, nil
+	}
+
+	h := CreateDownloadHandlerWithAuth(fetchEmbargoedMetadata, downloadTestFile, nil, nil, nil, createFileEvent, fetchTestRelease, clockAt(releaseTime.Add(-time.Hour)), authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+	rec := serveFileRequest(h, "/downloads/files/data/release.csv")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+}
@@ -24,6 +24,8 @@ func handleError(ctx context.Context, event string, w http.ResponseWriter, err e
 	switch err {
 	case files.ErrFileNotRegistered:
 		writeError(w, buildErrors(err, "FileNotRegistered"), http.StatusNotFound)
+	case files.ErrVersionNotFound:
+		writeError(w, buildErrors(err, "VersionNotFound"), http.StatusNotFound)
 	case files.ErrNotAuthorised:
 		writeError(w, buildErrors(err, "NotAuthorized"), http.StatusForbidden)
 	case files.ErrInvalidAuth:
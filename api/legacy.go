@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/downloads"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// LegacyResolver resolves a request to a legacy dataset version, filter output or image route to its download
+type LegacyResolver func(req *http.Request) (downloads.Model, error)
+
+// legacyDownloadKey is the context key of the legacy download being handled
+type legacyDownloadKey struct{}
+
+// legacyDownload is where a legacy download differs from the file it is handled as
+type legacyDownload struct {
+	path        string // the private S3 path of the download, used as the path of the file
+	filename    string
+	publicURL   string // where a moved download is redirected to
+	describedBy string // the route of the CSVW download describing a CSV download
+	releaseDate string
+}
+
+// CreateLegacyDownloadHandler handles requests to legacy routes with a download handler for files registered with the
+// files API, so that state handling, authorisation, auditing, headers and streaming are the same for both. The
+// download is resolved to the metadata of an equivalent file, which newHandler is given a fetcher for.
+//
+// The private copy of a legacy download is kept once it has a public link, so newHandler should read moved files
+// from the bucket rather than following the link. Downloads that only have a public link are redirected to it, and
+// unpublished downloads are not found in the web environment.
+func CreateLegacyDownloadHandler(resolve LegacyResolver, newHandler func(fetchMetadata files.MetadataFetcher) http.HandlerFunc, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+
+		m, err := resolve(req)
+		if err != nil {
+			handleLegacyError(ctx, w, err)
+			return
+		}
+
+		if m.PrivateS3Path == "" && m.IsPublicLinkAvailable() {
+			log.Info(ctx, "Legacy download only has a public link, redirecting", log.Data{"public": m.Public})
+			setStatusMovedPermanently(m.Public, w)
+			return
+		}
+
+		if m.PrivateS3Path == "" || (isWebMode(cfg) && !m.IsPublished) {
+			handleError(ctx, "No download found for legacy route", w, files.ErrFileNotRegistered)
+			return
+		}
+
+		metadata := m.Metadata()
+		download := legacyDownload{path: metadata.Path, filename: m.PrivateFilename, publicURL: m.Public, releaseDate: m.ReleaseDate}
+		if m.HasCSVW && strings.HasSuffix(req.URL.Path, ".csv") {
+			download.describedBy = req.URL.Path + csvwSuffix
+		}
+
+		fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+			if path != metadata.Path {
+				return nil, files.ErrFileNotRegistered
+			}
+			return metadata, nil
+		}
+
+		log.Info(ctx, "Handling legacy download as a file", log.Data{"filePath": metadata.Path, "state": metadata.State})
+		newHandler(fetchMetadata)(w, req.WithContext(context.WithValue(ctx, legacyDownloadKey{}, download)))
+	}
+}
+
+// handleLegacyError writes the error response for a legacy download that could not be resolved, using the status
+// code of errors returned by the dataset, filter and image APIs
+func handleLegacyError(ctx context.Context, w http.ResponseWriter, err error) {
+	var status interface{ Code() int }
+	if !errors.As(err, &status) {
+		handleError(ctx, "Error resolving legacy download", w, err)
+		return
+	}
+
+	switch status.Code() {
+	case http.StatusNotFound:
+		handleError(ctx, "Error resolving legacy download", w, files.ErrFileNotRegistered)
+	case http.StatusUnauthorized:
+		log.Error(ctx, files.ErrInvalidAuth.Error(), err, log.Classification(log.ProtectiveMonitoring))
+		handleError(ctx, "Error resolving legacy download", w, files.ErrInvalidAuth)
+	case http.StatusForbidden:
+		log.Error(ctx, files.ErrNotAuthorised.Error(), err, log.Classification(log.ProtectiveMonitoring))
+		handleError(ctx, "Error resolving legacy download", w, files.ErrNotAuthorised)
+	default:
+		handleError(ctx, "Error resolving legacy download", w, err)
+	}
+}
+
+func legacy(ctx context.Context) (legacyDownload, bool) {
+	download, ok := ctx.Value(legacyDownloadKey{}).(legacyDownload)
+	return download, ok
+}
+
+// downloadFilename returns the name a file is downloaded as
+func downloadFilename(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) string {
+	if download, ok := legacy(ctx); ok && download.filename != "" {
+		return download.filename
+	}
+	return files.GetFilename(m)
+}
+
+// movedLocation returns where a moved file is redirected to
+func movedLocation(ctx context.Context, cfg *config.Config, filePath string) string {
+	if download, ok := legacy(ctx); ok && download.publicURL != "" {
+		return download.publicURL
+	}
+	return RedirectLocation(cfg, filePath)
+}
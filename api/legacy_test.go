@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/downloads"
+	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/provenance"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const legacyCSVPath = "datasets/cpih01-time-series-v6.csv"
+
+type legacyClientError struct {
+	code int
+}
+
+func (e legacyClientError) Error() string {
+	return "client error"
+}
+
+func (e legacyClientError) Code() int {
+	return e.code
+}
+
+func legacyDataset(published bool) downloads.Model {
+	return downloads.Model{
+		IsPublished:     published,
+		PrivateS3Path:   legacyCSVPath,
+		PrivateFilename: "cpih01-time-series-v6.csv",
+		HasCSVW:         true,
+		DatasetID:       "cpih01",
+		Edition:         "time-series",
+		Version:         "6",
+	}
+}
+
+func resolveTo(m downloads.Model, err error) LegacyResolver {
+	return func(req *http.Request) (downloads.Model, error) {
+		return m, err
+	}
+}
+
+func downloadLegacyFile(path, versionID string) (io.ReadCloser, error) {
+	if path != legacyCSVPath {
+		return nil, files.ErrFileNotRegistered
+	}
+	return io.NopCloser(strings.NewReader("a,b\n1,2\n")), nil
+}
+
+func serveLegacyRequest(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv").HandlerFunc(h)
+
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+const legacyCSVRoute = "/downloads/datasets/cpih01/editions/time-series/versions/6.csv"
+
+func TestLegacyDownloadWebMode(t *testing.T) {
+	cfg := &config.Config{DefaultLicenceURL: "https://licence"}
+	newHandler := func(fetchMetadata files.MetadataFetcher) http.HandlerFunc {
+		return CreateDownloadHandlerNoAuth(fetchMetadata, downloadLegacyFile, nil, nil, downloadLegacyFile, nil, nil, nil, cfg)
+	}
+	handler := func(resolve LegacyResolver) http.HandlerFunc {
+		return CreateLegacyDownloadHandler(resolve, newHandler, cfg)
+	}
+
+	t.Run("streams published downloads from their private copy", func(t *testing.T) {
+		rec := serveLegacyRequest(handler(resolveTo(legacyDataset(true), nil)), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "a,b\n1,2\n", rec.Body.String())
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.Equal(t, `attachment; filename="cpih01-time-series-v6.csv"; filename*=UTF-8''cpih01-time-series-v6.csv`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, []string{`<https://licence>; rel="license"`, `<` + legacyCSVRoute + `-metadata.json>; rel="describedby"; type="application/csvm+json"`}, rec.Header().Values("Link"))
+	})
+
+	t.Run("sets the release date of the download when provenance headers are enabled", func(t *testing.T) {
+		cfg := &config.Config{ProvenanceHeaders: true}
+		newHandler := func(fetchMetadata files.MetadataFetcher) http.HandlerFunc {
+			return CreateDownloadHandlerNoAuth(fetchMetadata, downloadLegacyFile, nil, nil, downloadLegacyFile, nil, nil, nil, cfg)
+		}
+		m := legacyDataset(true)
+		m.ReleaseDate = "2026-03-04T09:30:00.000Z"
+
+		rec := serveLegacyRequest(CreateLegacyDownloadHandler(resolveTo(m, nil), newHandler, cfg), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2026-03-04T09:30:00Z", rec.Header().Get(provenance.ReleaseDateHeader))
+		assert.Equal(t, "6", rec.Header().Get(provenance.DatasetVersionHeader))
+	})
+
+	t.Run("redirects published downloads to their public link", func(t *testing.T) {
+		m := legacyDataset(true)
+		m.Public = "https://public.example.com/cpih01-time-series-v6.csv"
+
+		rec := serveLegacyRequest(handler(resolveTo(m, nil)), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, m.Public, rec.Header().Get("Location"))
+	})
+
+	t.Run("converts the private copy of downloads with a public link", func(t *testing.T) {
+		m := legacyDataset(true)
+		m.Public = "https://public.example.com/cpih01-time-series-v6.csv"
+
+		rec := serveLegacyRequest(handler(resolveTo(m, nil)), legacyCSVRoute+"?format=json")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"a": "1", "b": "2"}]`, rec.Body.String())
+	})
+
+	t.Run("redirects downloads that only have a public link", func(t *testing.T) {
+		m := downloads.Model{IsPublished: true, Public: "https://public.example.com/cpih01-time-series-v6.csv"}
+
+		rec := serveLegacyRequest(handler(resolveTo(m, nil)), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, m.Public, rec.Header().Get("Location"))
+	})
+
+	t.Run("unpublished downloads are not found", func(t *testing.T) {
+		rec := serveLegacyRequest(handler(resolveTo(legacyDataset(false), nil)), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"errors": [{"code": "FileNotRegistered", "description": "file not registered"}]}`, rec.Body.String())
+	})
+
+	t.Run("downloads the APIs do not find are not found", func(t *testing.T) {
+		rec := serveLegacyRequest(handler(resolveTo(downloads.Model{}, legacyClientError{http.StatusNotFound})), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("downloads that cannot be resolved are internal errors", func(t *testing.T) {
+		rec := serveLegacyRequest(handler(resolveTo(downloads.Model{}, legacyClientError{http.StatusBadGateway})), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("published downloads are embargoed until their release date", func(t *testing.T) {
+		newHandler := func(fetchMetadata files.MetadataFetcher) http.HandlerFunc {
+			return CreateDownloadHandlerNoAuth(fetchMetadata, downloadLegacyFile, nil, nil, downloadLegacyFile, nil, nil, clockAt(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)), cfg)
+		}
+		m := legacyDataset(true)
+		m.ReleaseDate = "2026-03-04T09:30:00.000Z"
+
+		rec := serveLegacyRequest(CreateLegacyDownloadHandler(resolveTo(m, nil), newHandler, cfg), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "max-age=1800", rec.Header().Get("Cache-Control"))
+	})
+}
+
+func TestLegacyDownloadPublishingMode(t *testing.T) {
+	cfg := &config.Config{IsPublishing: true}
+
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+
+	var attributes map[string]string
+	permissionsChecker := &authMock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attrs map[string]string) (bool, error) {
+			attributes = attrs
+			return true, nil
+		},
+	}
+
+	var events []filesAPIModels.FileEvent
+	createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+		events = append(events, event)
+		return &event, nil
+	}
+
+	newHandler := func(fetchMetadata files.MetadataFetcher) http.HandlerFunc {
+		return CreateDownloadHandlerWithAuth(fetchMetadata, downloadLegacyFile, nil, nil, downloadLegacyFile, createFileEvent, nil, nil, authorisationMock, cfg, permissionsChecker)
+	}
+
+	rec := serveLegacyRequest(CreateLegacyDownloadHandler(resolveTo(legacyDataset(false), nil), newHandler, cfg), legacyCSVRoute)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "a,b\n1,2\n", rec.Body.String())
+	assert.Equal(t, map[string]string{"dataset_edition": "cpih01/time-series"}, attributes)
+	require.Len(t, events, 1)
+	assert.Equal(t, legacyCSVPath, events[0].Resource)
+}
+
+func TestLegacyDownloadFileEventNotFound(t *testing.T) {
+	cfg := &config.Config{IsPublishing: true}
+
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+	permissionsChecker := &authMock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attrs map[string]string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	filesAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors": [{"code": "FileNotRegistered", "description": "file not registered"}]}`)) // nolint
+	}))
+	defer filesAPI.Close()
+	createFileEvent := filesAPISDK.New(filesAPI.URL).CreateFileEvent
+
+	newHandler := func(fetchMetadata files.MetadataFetcher) http.HandlerFunc {
+		return CreateDownloadHandlerWithAuth(fetchMetadata, downloadLegacyFile, nil, nil, downloadLegacyFile, createFileEvent, nil, nil, authorisationMock, cfg, permissionsChecker)
+	}
+
+	t.Run("legacy downloads are served when the files API does not know them", func(t *testing.T) {
+		rec := serveLegacyRequest(CreateLegacyDownloadHandler(resolveTo(legacyDataset(false), nil), newHandler, cfg), legacyCSVRoute)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "a,b\n1,2\n", rec.Body.String())
+	})
+
+	t.Run("files the files API does not know are not served", func(t *testing.T) {
+		fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+			return legacyDataset(false).Metadata(), nil
+		}
+
+		rec := serveFileRequest(newHandler(fetchMetadata), "/downloads/files/"+legacyCSVPath)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	auth "github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// FileMetadata is the public view of the metadata of a file. The collection, bundle and state of the file are only
+// included in the publishing environment. The files API does not return the times a file was modified or published,
+// so they are not included.
+type FileMetadata struct {
+	Path         string               `json:"path"`
+	Filename     string               `json:"filename"`
+	Title        string               `json:"title"`
+	Type         string               `json:"type"`
+	SizeInBytes  uint64               `json:"size_in_bytes"`
+	Licence      string               `json:"licence"`
+	LicenceURL   string               `json:"licence_url"`
+	ContentItem  *FileMetadataContent `json:"content_item,omitempty"`
+	CollectionID *string              `json:"collection_id,omitempty"`
+	BundleID     *string              `json:"bundle_id,omitempty"`
+	State        string               `json:"state,omitempty"`
+}
+
+// FileMetadataContent identifies the dataset version a file belongs to
+type FileMetadataContent struct {
+	DatasetID string `json:"dataset_id"`
+	Edition   string `json:"edition"`
+	Version   string `json:"version"`
+}
+
+// CreateMetadataHandler handles requests for the metadata of a file as JSON. Metadata is subject to the same checks
+// as downloading the file.
+func CreateMetadataHandler(fetchMetadata files.MetadataFetcher, fetchWithdrawal files.WithdrawalFetcher, fetchRelease files.ReleaseFetcher, clock func() time.Time, authMiddleware auth.Middleware, cfg *config.Config, permissionsChecker auth.PermissionsChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if cfg.IsPublishing {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		ctx, requestedFilePath := parseRequest(req)
+		log.Info(ctx, fmt.Sprintf("Handling metadata request for %s", requestedFilePath))
+
+		accessToken := getAccessTokenFromRequest(req)
+		headers := filesAPISDK.Headers{}
+		if cfg.IsPublishing {
+			headers.Authorization = accessToken
+		}
+
+		metadata, err := fetchMetadata(ctx, requestedFilePath, headers)
+		if err != nil {
+			handleMetadataError(ctx, w, err)
+			return
+		}
+
+		if isWebMode(cfg) && handleWithdrawnFile(ctx, w, metadata, requestedFilePath, fetchWithdrawal) {
+			return
+		}
+
+		if unavailable(metadata, cfg) {
+			log.Info(ctx, "File metadata is not available", log.Data{"state": metadata.State})
+			setStatusNotFound(w)
+			return
+		}
+
+		if isWebMode(cfg) {
+			if _, embargoed := handleEmbargoedFile(ctx, w, metadata, fetchRelease, clock); embargoed {
+				return
+			}
+		}
+
+		if cfg.IsPublishing {
+			entityData, logData, ok := authenticate(ctx, w, req, authMiddleware, accessToken)
+			if !ok {
+				return
+			}
+
+			if !checkUserPermission(ctx, logData, "static-files:read", setPermissionsAttributes(metadata), permissionsChecker, entityData) {
+				log.Info(ctx, "authorisation failed: request has no permission", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), logData)
+				handleError(ctx, "the request was not authorised - check token and user's permissions", w, files.ErrNotAuthorised)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(newFileMetadata(metadata, cfg.IsPublishing)); err != nil {
+			log.Error(ctx, "Failed to write metadata", err)
+		}
+	}
+}
+
+func newFileMetadata(m *filesAPIModels.StoredRegisteredMetaData, publishing bool) FileMetadata {
+	fm := FileMetadata{
+		Path:        m.Path,
+		Filename:    files.GetFilename(m),
+		Title:       m.Title,
+		Type:        m.Type,
+		SizeInBytes: m.SizeInBytes,
+		Licence:     m.Licence,
+		LicenceURL:  m.LicenceURL,
+	}
+
+	if m.ContentItem != nil {
+		fm.ContentItem = &FileMetadataContent{
+			DatasetID: m.ContentItem.DatasetID,
+			Edition:   m.ContentItem.Edition,
+			Version:   m.ContentItem.Version,
+		}
+	}
+
+	if publishing {
+		fm.CollectionID = m.CollectionID
+		fm.BundleID = m.BundleID
+		fm.State = m.State
+	}
+
+	return fm
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fetchDescribedMetadata(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+	collectionID := "collection-1"
+	m := &filesAPIModels.StoredRegisteredMetaData{
+		Path:         path,
+		Title:        "Consumer price inflation",
+		Type:         "text/csv",
+		SizeInBytes:  1024,
+		Licence:      "OGL v3",
+		LicenceURL:   "https://www.nationalarchives.gov.uk/doc/open-government-licence/version/3/",
+		ContentItem:  &filesAPIModels.StoredContentItem{DatasetID: "cpih01", Edition: "time-series", Version: "2"},
+		CollectionID: &collectionID,
+		State:        files.PUBLISHED,
+	}
+
+	switch path {
+	case "data/draft.csv":
+		m.State = files.UPLOADED
+	case "data/created.csv":
+		m.State = files.CREATED
+	case "data/missing.csv":
+		return nil, files.ErrFileNotRegistered
+	}
+
+	return m, nil
+}
+
+// filesAPIFile is the body the files API responds with for a published file
+const filesAPIFile = `{
+	"path": "data/cpih.csv",
+	"is_publishable": true,
+	"collection_id": "collection-1",
+	"title": "Consumer price inflation",
+	"size_in_bytes": 1024,
+	"type": "text/csv",
+	"licence": "OGL v3",
+	"licence_url": "https://www.nationalarchives.gov.uk/doc/open-government-licence/version/3/",
+	"content_item": {"dataset_id": "cpih01", "edition": "time-series", "version": "2"},
+	"state": "PUBLISHED",
+	"etag": "abc"
+}`
+
+// fetchFilesAPIMetadata returns a metadata fetcher that decodes body with the files API client, as the service does
+func fetchFilesAPIMetadata(t *testing.T, body string) files.MetadataFetcher {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body)) // nolint
+	}))
+	t.Cleanup(server.Close)
+
+	client := filesAPISDK.New(server.URL)
+	return func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		return client.GetFile(ctx, path, headers)
+	}
+}
+
+func serveMetadata(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.Path("/downloads/files/{path:.*}").Queries("meta", "").HandlerFunc(h)
+
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestMetadataWebMode(t *testing.T) {
+	h := CreateMetadataHandler(fetchDescribedMetadata, nil, nil, nil, nil, &config.Config{}, nil)
+
+	t.Run("returns the public metadata of a published file", func(t *testing.T) {
+		rec := serveMetadata(h, "/downloads/files/data/cpih.csv?meta")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"path": "data/cpih.csv",
+			"filename": "cpih.csv",
+			"title": "Consumer price inflation",
+			"type": "text/csv",
+			"size_in_bytes": 1024,
+			"licence": "OGL v3",
+			"licence_url": "https://www.nationalarchives.gov.uk/doc/open-government-licence/version/3/",
+			"content_item": {"dataset_id": "cpih01", "edition": "time-series", "version": "2"}
+		}`, rec.Body.String())
+	})
+
+	t.Run("returns the metadata decoded from the files API", func(t *testing.T) {
+		h := CreateMetadataHandler(fetchFilesAPIMetadata(t, filesAPIFile), nil, nil, nil, nil, &config.Config{}, nil)
+		rec := serveMetadata(h, "/downloads/files/data/cpih.csv?meta")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"path": "data/cpih.csv",
+			"filename": "cpih.csv",
+			"title": "Consumer price inflation",
+			"type": "text/csv",
+			"size_in_bytes": 1024,
+			"licence": "OGL v3",
+			"licence_url": "https://www.nationalarchives.gov.uk/doc/open-government-licence/version/3/",
+			"content_item": {"dataset_id": "cpih01", "edition": "time-series", "version": "2"}
+		}`, rec.Body.String())
+	})
+
+	t.Run("does not return the metadata of unpublished files", func(t *testing.T) {
+		for _, path := range []string{"data/draft.csv", "data/created.csv", "data/missing.csv"} {
+			rec := serveMetadata(h, "/downloads/files/"+path+"?meta")
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, path)
+		}
+	})
+}
+
+func TestMetadataPublishingMode(t *testing.T) {
+	cfg := &config.Config{IsPublishing: true}
+
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+
+	permissionsChecker := func(allowed bool) *authMock.PermissionsCheckerMock {
+		return &authMock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return allowed, nil
+			},
+		}
+	}
+
+	t.Run("includes the publishing fields of unpublished files", func(t *testing.T) {
+		h := CreateMetadataHandler(fetchDescribedMetadata, nil, nil, nil, authorisationMock, cfg, permissionsChecker(true))
+		rec := serveMetadata(h, "/downloads/files/data/draft.csv?meta")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+		var metadata FileMetadata
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &metadata))
+		assert.Equal(t, files.UPLOADED, metadata.State)
+		require.NotNil(t, metadata.CollectionID)
+		assert.Equal(t, "collection-1", *metadata.CollectionID)
+	})
+
+	t.Run("rejects users without permission to read the file", func(t *testing.T) {
+		h := CreateMetadataHandler(fetchDescribedMetadata, nil, nil, nil, authorisationMock, cfg, permissionsChecker(false))
+		rec := serveMetadata(h, "/downloads/files/data/draft.csv?meta")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	auth "github.com/ONSdigital/dp-authorisation/v2/authorisation"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/tabular"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+var errPreviewNotSupported = errors.New("only CSV files can be previewed")
+
+// CreatePreviewHandler handles requests for the first rows of a CSV file as JSON. Only the start of the file is read
+// from the bucket, up to the PreviewMaxBytes limit. A preview is subject to the same checks as downloading the whole
+// file and in the publishing environment a file event is created for it. Files that must be watermarked cannot be
+// previewed, as a preview has nowhere to put the watermark. Files that have been moved to the public bucket are read
+// with downloadMovedFile.
+func CreatePreviewHandler(fetchMetadata files.MetadataFetcher, downloadFileRange files.FileRangeDownloader, downloadMovedFile files.FileDownloader, createFileEvent files.FileEventCreator, fetchWithdrawal files.WithdrawalFetcher, fetchRelease files.ReleaseFetcher, clock func() time.Time, authMiddleware auth.Middleware, cfg *config.Config, permissionsChecker auth.PermissionsChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if cfg.IsPublishing {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		ctx, requestedFilePath := parseRequest(req)
+		log.Info(ctx, fmt.Sprintf("Handling preview request for %s", requestedFilePath))
+
+		rows, err := tabular.ParseRows(req.URL.Query().Get("rows"), cfg.PreviewMaxRows)
+		if err != nil {
+			writeError(w, buildErrors(err, "InvalidRows"), http.StatusBadRequest)
+			return
+		}
+
+		accessToken := getAccessTokenFromRequest(req)
+		headers := filesAPISDK.Headers{}
+		if cfg.IsPublishing {
+			headers.Authorization = accessToken
+		}
+
+		metadata, err := fetchMetadata(ctx, requestedFilePath, headers)
+		if err != nil {
+			handleMetadataError(ctx, w, err)
+			return
+		}
+
+		if isWebMode(cfg) && handleWithdrawnFile(ctx, w, metadata, requestedFilePath, fetchWithdrawal) {
+			return
+		}
+
+		if unavailable(metadata, cfg) {
+			log.Info(ctx, "File is not available for preview", log.Data{"state": metadata.State})
+			setStatusNotFound(w)
+			return
+		}
+
+		if isWebMode(cfg) {
+			if _, embargoed := handleEmbargoedFile(ctx, w, metadata, fetchRelease, clock); embargoed {
+				return
+			}
+		}
+
+		if cfg.IsPublishing {
+			entityData, logData, ok := authenticate(ctx, w, req, authMiddleware, accessToken)
+			if !ok {
+				return
+			}
+
+			if !checkUserPermission(ctx, logData, "static-files:read", setPermissionsAttributes(metadata), permissionsChecker, entityData) {
+				log.Info(ctx, "authorisation failed: request has no permission", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), logData)
+				handleError(ctx, "the request was not authorised - check token and user's permissions", w, files.ErrNotAuthorised)
+				return
+			}
+
+			if mustWatermark(metadata, cfg) {
+				log.Info(ctx, "Pre-release file cannot be watermarked in a preview", log.Data{"filePath": requestedFilePath})
+				writeError(w, buildErrors(errWatermarkRequired, "WatermarkRequired"), http.StatusForbidden)
+				return
+			}
+
+			if err = recordFileEvent(ctx, *entityData, accessToken, files.EventResource{Path: requestedFilePath}, metadata, w, createFileEvent); err != nil {
+				return
+			}
+			logData["filePath"] = requestedFilePath
+			log.Info(ctx, "Successfully created file event for preview", log.Classification(log.ProtectiveMonitoring), log.Auth(log.USER, entityData.UserID), logData)
+		}
+
+		if !isCSV(metadata) {
+			writeError(w, buildErrors(errPreviewNotSupported, "PreviewNotSupported"), http.StatusBadRequest)
+			return
+		}
+
+		file, err := openPreview(requestedFilePath, metadata, cfg.PreviewMaxBytes, downloadFileRange, downloadMovedFile)
+		if err != nil {
+			handleError(ctx, fmt.Sprintf("Error downloading file %s", requestedFilePath), w, err)
+			return
+		}
+
+		defer closeDownloadedFile(ctx, file)
+
+		writePreview(ctx, w, file, int64(metadata.SizeInBytes), rows, cfg.PreviewMaxBytes)
+	}
+}
+
+// openPreview opens the start of a file for a preview
+func openPreview(filePath string, m *filesAPIModels.StoredRegisteredMetaData, maxBytes int64, downloadFileRange files.FileRangeDownloader, downloadMovedFile files.FileDownloader) (io.ReadCloser, error) {
+	switch {
+	case files.Moved(m):
+		return downloadMovedFile(filePath, "")
+	case m.SizeInBytes == 0:
+		return io.NopCloser(strings.NewReader("")), nil
+	default:
+		file, _, err := downloadFileRange(filePath, "", 0, min(int64(m.SizeInBytes), maxBytes))
+		return file, err
+	}
+}
+
+// writePreview reads a preview from the start of a CSV file and writes it to the response as JSON
+func writePreview(ctx context.Context, w http.ResponseWriter, file io.Reader, size int64, rows int, maxBytes int64) {
+	preview, err := tabular.ReadPreview(file, size, rows, maxBytes)
+	switch {
+	case errors.Is(err, tabular.ErrHeaderTooLarge):
+		writeError(w, buildErrors(err, "HeaderTooLarge"), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, tabular.ErrInvalidCSV):
+		writeError(w, buildErrors(err, "InvalidCSV"), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		handleError(ctx, "Failed to read preview", w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(preview); err != nil {
+		log.Error(ctx, "Failed to write preview", err)
+	}
+}
+
+func isCSV(m *filesAPIModels.StoredRegisteredMetaData) bool {
+	mediaType, _, err := mime.ParseMediaType(m.Type)
+	if err == nil && mediaType == "text/csv" {
+		return true
+	}
+	return strings.EqualFold(path.Ext(m.Path), ".csv")
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/tabular"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const previewCSV = "geography,value\nK02000001,1\nE92000001,2\nW92000004,3\n"
+
+var previewFiles = map[string]*filesAPIModels.StoredRegisteredMetaData{
+	"data/published.csv": {Path: "data/published.csv", Type: "text/csv", SizeInBytes: uint64(len(previewCSV)), State: files.PUBLISHED},
+	"data/moved.csv":     {Path: "data/moved.csv", Type: "text/csv", SizeInBytes: uint64(len(previewCSV)), State: files.MOVED},
+	"data/draft.csv":     {Path: "data/draft.csv", Type: "text/csv", SizeInBytes: uint64(len(previewCSV)), State: files.UPLOADED},
+	"data/created.csv":   {Path: "data/created.csv", Type: "text/csv", SizeInBytes: uint64(len(previewCSV)), State: files.CREATED},
+	"data/report.pdf":    {Path: "data/report.pdf", Type: "application/pdf", SizeInBytes: 10, State: files.PUBLISHED},
+	"data/invalid.csv":   {Path: "data/invalid.csv", Type: "text/csv", SizeInBytes: 10, State: files.PUBLISHED},
+}
+
+func fetchPreviewMetadata(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+	m, ok := previewFiles[path]
+	if !ok {
+		return nil, files.ErrFileNotRegistered
+	}
+	return m, nil
+}
+
+func downloadPreviewRange(path, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+	if path == "data/invalid.csv" {
+		return io.NopCloser(strings.NewReader("a,\"b\nc,d\n")), nil, nil
+	}
+	size := int64(len(previewCSV))
+	return io.NopCloser(strings.NewReader(previewCSV[offset : offset+length])), &size, nil
+}
+
+func downloadMovedPreview(path, versionID string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(previewCSV)), nil
+}
+
+func servePreview(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.Path("/downloads/preview/{path:.*}").HandlerFunc(h)
+
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPreviewWebMode(t *testing.T) {
+	cfg := &config.Config{PreviewMaxRows: 2, PreviewMaxBytes: 1024}
+	h := CreatePreviewHandler(fetchPreviewMetadata, downloadPreviewRange, downloadMovedPreview, nil, nil, nil, nil, nil, cfg, nil)
+
+	t.Run("returns the first rows of a published file", func(t *testing.T) {
+		rec := servePreview(h, "/downloads/preview/data/published.csv?rows=1")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var preview tabular.Preview
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &preview))
+		assert.Equal(t, tabular.Preview{
+			Header:      []string{"geography", "value"},
+			Rows:        [][]string{{"K02000001", "1"}},
+			Truncated:   true,
+			SizeInBytes: int64(len(previewCSV)),
+		}, preview)
+	})
+
+	t.Run("caps the number of rows", func(t *testing.T) {
+		rec := servePreview(h, "/downloads/preview/data/published.csv?rows=50")
+
+		var preview tabular.Preview
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &preview))
+		assert.Len(t, preview.Rows, 2)
+	})
+
+	t.Run("reads moved files from the public bucket", func(t *testing.T) {
+		rec := servePreview(h, "/downloads/preview/data/moved.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "E92000001")
+	})
+
+	t.Run("does not preview unpublished files", func(t *testing.T) {
+		for _, path := range []string{"data/draft.csv", "data/created.csv"} {
+			rec := servePreview(h, "/downloads/preview/"+path)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, path)
+		}
+	})
+
+	t.Run("rejects files that are not CSV", func(t *testing.T) {
+		rec := servePreview(h, "/downloads/preview/data/report.pdf")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "PreviewNotSupported")
+	})
+
+	t.Run("rejects an invalid number of rows", func(t *testing.T) {
+		rec := servePreview(h, "/downloads/preview/data/published.csv?rows=none")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "InvalidRows")
+	})
+
+	t.Run("rejects invalid CSV", func(t *testing.T) {
+		rec := servePreview(h, "/downloads/preview/data/invalid.csv")
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "InvalidCSV")
+	})
+
+	t.Run("reads no more than the byte limit", func(t *testing.T) {
+		var requested int64
+		downloadFileRange := func(path, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+			requested = length
+			return downloadPreviewRange(path, versionID, offset, length)
+		}
+		cfg := &config.Config{PreviewMaxRows: 10, PreviewMaxBytes: 30}
+
+		rec := servePreview(CreatePreviewHandler(fetchPreviewMetadata, downloadFileRange, downloadMovedPreview, nil, nil, nil, nil, nil, cfg, nil), "/downloads/preview/data/published.csv")
+
+		var preview tabular.Preview
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &preview))
+		assert.Equal(t, int64(30), requested)
+		assert.Len(t, preview.Rows, 1)
+		assert.True(t, preview.Truncated)
+	})
+}
+
+func TestPreviewPublishingMode(t *testing.T) {
+	cfg := &config.Config{IsPublishing: true, PreviewMaxRows: 10, PreviewMaxBytes: 1024}
+
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+
+	permissionsChecker := func(allowed bool) *authMock.PermissionsCheckerMock {
+		return &authMock.PermissionsCheckerMock{
+			HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+				return allowed, nil
+			},
+		}
+	}
+
+	t.Run("previews unpublished files and creates a file event", func(t *testing.T) {
+		var resources []string
+		createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			resources = append(resources, event.Resource)
+			return &event, nil
+		}
+
+		h := CreatePreviewHandler(fetchPreviewMetadata, downloadPreviewRange, downloadMovedPreview, createFileEvent, nil, nil, nil, authorisationMock, cfg, permissionsChecker(true))
+		rec := servePreview(h, "/downloads/preview/data/draft.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, []string{"data/draft.csv"}, resources)
+	})
+
+	t.Run("rejects users without permission to read the file", func(t *testing.T) {
+		createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			t.Fatal("createFileEvent should not have been called")
+			return nil, nil
+		}
+
+		h := CreatePreviewHandler(fetchPreviewMetadata, downloadPreviewRange, downloadMovedPreview, createFileEvent, nil, nil, nil, authorisationMock, cfg, permissionsChecker(false))
+		rec := servePreview(h, "/downloads/preview/data/draft.csv")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("rejects the preview if the file event cannot be created", func(t *testing.T) {
+		createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+			return nil, errors.New("files api unavailable")
+		}
+
+		h := CreatePreviewHandler(fetchPreviewMetadata, downloadPreviewRange, downloadMovedPreview, createFileEvent, nil, nil, nil, authorisationMock, cfg, permissionsChecker(true))
+		rec := servePreview(h, "/downloads/preview/data/published.csv")
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
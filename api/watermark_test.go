@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/watermark"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermarkPreRelease(t *testing.T) {
+	clock := clockAt(time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC))
+
+	fetchMetadata := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+		m := &filesAPIModels.StoredRegisteredMetaData{Path: path, Type: "text/csv", SizeInBytes: 4, State: files.UPLOADED}
+		switch path {
+		case "data/published.csv":
+			m.State = files.PUBLISHED
+		case "data/report.pdf":
+			m.Type = "application/pdf"
+		}
+		return m, nil
+	}
+
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "previewer@ons.gov.uk"}, nil
+		},
+	}
+	permissionsChecker := &authMock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	var events []filesAPIModels.FileEvent
+	createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+		events = append(events, event)
+		return &event, nil
+	}
+
+	handler := func(cfg *config.Config) http.HandlerFunc {
+		events = nil
+		return CreateDownloadHandlerWithAuth(fetchMetadata, downloadTestFile, nil, nil, nil, createFileEvent, nil, clock, authorisationMock, cfg, permissionsChecker)
+	}
+	cfg := &config.Config{IsPublishing: true, WatermarkPreRelease: true}
+
+	t.Run("watermarks uploaded CSVs and records the watermark in the file event", func(t *testing.T) {
+		rec := serveFileRequest(handler(cfg), "/downloads/files/data/draft.csv")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		id := rec.Header().Get(watermark.IDHeader)
+		require.NotEmpty(t, id)
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.Equal(t, "# PRE-RELEASE – NOT FOR PUBLICATION. Downloaded by previewer@ons.gov.uk at 2026-03-04T09:30:00Z. Watermark "+id+"\na,b\n", rec.Body.String())
+		require.Len(t, events, 1)
+		assert.Equal(t, "data/draft.csv?watermark="+id, events[0].Resource)
+	})
+
+	t.Run("watermarks filtered CSVs", func(t *testing.T) {
+		rec := serveFileRequest(handler(cfg), "/downloads/files/data/draft.csv?columns=a")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		id := rec.Header().Get(watermark.IDHeader)
+		require.NotEmpty(t, id)
+		assert.True(t, strings.HasPrefix(rec.Body.String(), "# PRE-RELEASE – NOT FOR PUBLICATION."), rec.Body.String())
+		assert.True(t, strings.HasSuffix(rec.Body.String(), "Watermark "+id+"\na\n"), rec.Body.String())
+	})
+
+	t.Run("does not watermark published files or types that cannot be watermarked", func(t *testing.T) {
+		for _, path := range []string{"data/published.csv", "data/report.pdf"} {
+			rec := serveFileRequest(handler(cfg), "/downloads/files/"+path)
+
+			assert.Equal(t, http.StatusOK, rec.Code, path)
+			assert.Empty(t, rec.Header().Get(watermark.IDHeader), path)
+			assert.Equal(t, "a,b\n", rec.Body.String(), path)
+			require.Len(t, events, 1, path)
+			assert.Equal(t, path, events[0].Resource, path)
+		}
+	})
+
+	t.Run("does not watermark when watermarking is disabled", func(t *testing.T) {
+		rec := serveFileRequest(handler(&config.Config{IsPublishing: true}), "/downloads/files/data/draft.csv")
+
+		assert.Empty(t, rec.Header().Get(watermark.IDHeader))
+		assert.Equal(t, "a,b\n", rec.Body.String())
+	})
+
+	t.Run("refuses conversions to formats that cannot be watermarked", func(t *testing.T) {
+		for _, format := range []string{"json", "ndjson", "parquet"} {
+			rec := serveFileRequest(handler(cfg), "/downloads/files/data/draft.csv?format="+format)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code, format)
+			assert.Contains(t, rec.Body.String(), "WatermarkRequired", format)
+		}
+
+		rec := serveFileRequest(handler(cfg), "/downloads/files/data/published.csv?format=json")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("refuses bundles of files that must be watermarked", func(t *testing.T) {
+		events = nil
+		h := CreateBundleHandler(fetchMetadata, downloadBundleFile, downloadMovedBundleFile, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true, WatermarkPreRelease: true, MaxBundleFiles: 2}, permissionsChecker)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, newBundleRequest(`{"files": ["data/published.csv", "data/draft.csv"]}`))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "WatermarkRequired")
+		assert.Empty(t, events)
+	})
+
+	t.Run("refuses archives of files that must be watermarked", func(t *testing.T) {
+		events = nil
+		listFiles := func(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+			published, _ := fetchMetadata(ctx, "data/published.csv", headers)
+			draft, _ := fetchMetadata(ctx, "data/draft.csv", headers)
+			return []filesAPIModels.StoredRegisteredMetaData{*published, *draft}, nil
+		}
+		r := mux.NewRouter()
+		r.Path("/downloads/collections/{id}").HandlerFunc(CreateArchiveHandler(ArchiveCollection, listFiles, downloadBundleFile, downloadMovedBundleFile, createFileEvent, authorisationMock, cfg, permissionsChecker))
+		rec := httptest.NewRecorder()
+
+		r.ServeHTTP(rec, newArchiveRequest("/downloads/collections/collection1"))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "WatermarkRequired")
+		assert.Empty(t, events)
+	})
+
+	t.Run("refuses previews of files that must be watermarked", func(t *testing.T) {
+		events = nil
+		h := CreatePreviewHandler(fetchMetadata, downloadPreviewRange, downloadMovedPreview, createFileEvent, nil, nil, nil, authorisationMock, cfg, permissionsChecker)
+
+		rec := servePreview(h, "/downloads/preview/data/draft.csv")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "WatermarkRequired")
+		assert.Empty(t, events)
+
+		rec = servePreview(h, "/downloads/preview/data/published.csv")
+		assert.NotContains(t, rec.Body.String(), "WatermarkRequired")
+		require.Len(t, events, 1)
+	})
+
+	t.Run("refuses checksums of files that must be watermarked", func(t *testing.T) {
+		h := CreateChecksumHandler(fetchMetadata, fetchTestChecksum, nil, nil, nil, authorisationMock, cfg, permissionsChecker)
+
+		rec := serveChecksum(h, "/downloads/checksums/data/draft.csv")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "WatermarkRequired")
+
+		rec = serveChecksum(h, "/downloads/checksums/data/published.csv")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+var (
+	errFileWithdrawn = errors.New("file has been withdrawn")
+	errFileArchived  = errors.New("file has been archived")
+)
+
+// goneResponse is the body of the response for a file that has been withdrawn or archived, linking to the notice
+// explaining why and to the file replacing it when there are any
+type goneResponse struct {
+	Errors      []jsonError `json:"errors"`
+	Notice      string      `json:"notice,omitempty"`
+	Replacement string      `json:"replacement,omitempty"`
+}
+
+// handleWithdrawnFile answers 410 Gone for files that have been withdrawn or archived, returning false for any other
+// file. Withdrawn files are only gone in the web environment; in the publishing environment they can still be
+// downloaded by authorised users.
+func handleWithdrawnFile(ctx context.Context, w http.ResponseWriter, m *filesAPIModels.StoredRegisteredMetaData, requestedFilePath string, fetchWithdrawal files.WithdrawalFetcher) bool {
+	if !files.Withdrawn(m) {
+		return false
+	}
+
+	err, code := errFileWithdrawn, "FileWithdrawn"
+	if m.State == files.ARCHIVED {
+		err, code = errFileArchived, "FileArchived"
+	}
+	log.Info(ctx, "File has been taken down", log.Data{"filePath": requestedFilePath, "state": m.State})
+
+	body := goneResponse{Errors: []jsonError{{Code: code, Description: err.Error()}}}
+	if fetchWithdrawal != nil {
+		withdrawal, fetchErr := fetchWithdrawal(ctx, requestedFilePath, filesAPISDK.Headers{})
+		if fetchErr != nil {
+			// The file is gone either way, so the response is only missing the links
+			log.Warn(ctx, "failed to fetch withdrawal notice", log.Data{"filePath": requestedFilePath, "error": fetchErr.Error()})
+		} else {
+			body.Notice = withdrawal.NoticeURL
+			if withdrawal.ReplacementPath != "" {
+				body.Replacement = "/downloads/files/" + strings.TrimPrefix(withdrawal.ReplacementPath, "/")
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGone)
+	json.NewEncoder(w).Encode(&body) // nolint
+
+	return true
+}
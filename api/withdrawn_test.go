@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authMock "github.com/ONSdigital/dp-authorisation/v2/authorisation/mock"
+	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/files"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	dprequest "github.com/ONSdigital/dp-net/v3/request"
+	permissionsAPISDK "github.com/ONSdigital/dp-permissions-api/sdk"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fetchWithdrawnMetadata(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
+	states := map[string]string{
+		"data/withdrawn.csv": files.WITHDRAWN,
+		"data/archived.csv":  files.ARCHIVED,
+	}
+	return &filesAPIModels.StoredRegisteredMetaData{Path: path, Type: "text/csv", SizeInBytes: 4, State: states[path]}, nil
+}
+
+func downloadTestFile(path, versionID string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("a,b\n")), nil
+}
+
+func serveFileRequest(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.Path("/downloads/files/{path:.*}").HandlerFunc(h)
+
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	req.Header.Set(dprequest.AuthHeaderKey, testAuthorizationHeader)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestWithdrawnFileWebMode(t *testing.T) {
+	fetchWithdrawal := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*files.Withdrawal, error) {
+		switch path {
+		case "data/withdrawn.csv":
+			return &files.Withdrawal{NoticeURL: "https://www.ons.gov.uk/notice", ReplacementPath: "data/corrected.csv"}, nil
+		case "data/archived.csv":
+			return &files.Withdrawal{}, nil
+		}
+		return nil, errors.New("unexpected path")
+	}
+
+	h := CreateDownloadHandlerNoAuth(fetchWithdrawnMetadata, downloadTestFile, nil, nil, nil, fetchWithdrawal, nil, nil, &config.Config{})
+
+	t.Run("links withdrawn files to their notice and replacement", func(t *testing.T) {
+		rec := serveFileRequest(h, "/downloads/files/data/withdrawn.csv")
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Empty(t, rec.Header().Get("Content-Disposition"))
+		assert.JSONEq(t, `{
+			"errors": [{"code": "FileWithdrawn", "description": "file has been withdrawn"}],
+			"notice": "https://www.ons.gov.uk/notice",
+			"replacement": "/downloads/files/data/corrected.csv"
+		}`, rec.Body.String())
+	})
+
+	t.Run("archived files without a notice are gone", func(t *testing.T) {
+		rec := serveFileRequest(h, "/downloads/files/data/archived.csv")
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.JSONEq(t, `{"errors": [{"code": "FileArchived", "description": "file has been archived"}]}`, rec.Body.String())
+	})
+
+	t.Run("withdrawn files are gone when the notice cannot be fetched", func(t *testing.T) {
+		failing := func(ctx context.Context, path string, headers filesAPISDK.Headers) (*files.Withdrawal, error) {
+			return nil, files.ErrUnknown
+		}
+
+		rec := serveFileRequest(CreateDownloadHandlerNoAuth(fetchWithdrawnMetadata, downloadTestFile, nil, nil, nil, failing, nil, nil, &config.Config{}), "/downloads/files/data/withdrawn.csv")
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.JSONEq(t, `{"errors": [{"code": "FileWithdrawn", "description": "file has been withdrawn"}]}`, rec.Body.String())
+	})
+
+	t.Run("converted downloads of withdrawn files are gone", func(t *testing.T) {
+		rec := serveFileRequest(h, "/downloads/files/data/withdrawn.csv?format=json")
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+	})
+
+	t.Run("the metadata, checksum and preview of withdrawn files are gone with the notice", func(t *testing.T) {
+		cfg := &config.Config{}
+		notice := `"notice":"https://www.ons.gov.uk/notice"`
+
+		rec := serveMetadata(CreateMetadataHandler(fetchWithdrawnMetadata, fetchWithdrawal, nil, nil, nil, cfg, nil), "/downloads/files/data/withdrawn.csv?meta")
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.Contains(t, rec.Body.String(), notice)
+
+		rec = serveChecksum(CreateChecksumHandler(fetchWithdrawnMetadata, fetchTestChecksum, fetchWithdrawal, nil, nil, nil, cfg, nil), "/downloads/checksums/data/withdrawn.csv")
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.Contains(t, rec.Body.String(), notice)
+
+		rec = servePreview(CreatePreviewHandler(fetchWithdrawnMetadata, downloadPreviewRange, downloadMovedPreview, nil, fetchWithdrawal, nil, nil, nil, cfg, nil), "/downloads/preview/data/withdrawn.csv")
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.Contains(t, rec.Body.String(), notice)
+	})
+}
+
+func TestWithdrawnFilePublishingMode(t *testing.T) {
+	authorisationMock := &authMock.MiddlewareMock{
+		ParseFunc: func(token string) (*permissionsAPISDK.EntityData, error) {
+			return &permissionsAPISDK.EntityData{UserID: "admin"}, nil
+		},
+	}
+	permissionsChecker := &authMock.PermissionsCheckerMock{
+		HasPermissionFunc: func(ctx context.Context, entityData permissionsAPISDK.EntityData, permission string, attributes map[string]string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	var events []filesAPIModels.FileEvent
+	createFileEvent := func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error) {
+		events = append(events, event)
+		return &event, nil
+	}
+
+	h := CreateDownloadHandlerWithAuth(fetchWithdrawnMetadata, downloadTestFile, nil, nil, nil, createFileEvent, nil, nil, authorisationMock, &config.Config{IsPublishing: true}, permissionsChecker)
+
+	for _, path := range []string{"data/withdrawn.csv", "data/archived.csv"} {
+		events = nil
+		rec := serveFileRequest(h, "/downloads/files/"+path)
+
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Equal(t, "a,b\n", rec.Body.String(), path)
+		require.Len(t, events, 1, path)
+		assert.Equal(t, filesAPIModels.ActionRead, events[0].Action, path)
+		assert.Equal(t, path, events[0].Resource, path)
+	}
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/ONSdigital/dp-authorisation/v2/authorisation"
@@ -17,6 +19,17 @@ type Config struct {
 	BindAddr                   string        `envconfig:"BIND_ADDR"`
 	AwsRegion                  string        `envconfig:"AWS_REGION"`
 	BucketName                 string        `envconfig:"BUCKET_NAME"`
+	SecondaryBucketName        string        `envconfig:"SECONDARY_BUCKET_NAME"`
+	SecondaryAwsRegion         string        `envconfig:"SECONDARY_AWS_REGION"`
+	S3FailoverPolicy           string        `envconfig:"S3_FAILOVER_POLICY"`
+	S3PrimaryTimeout           time.Duration `envconfig:"S3_PRIMARY_TIMEOUT"`
+	S3EncryptionMode           string        `envconfig:"S3_ENCRYPTION_MODE"`
+	S3EncryptionRules          string        `envconfig:"S3_ENCRYPTION_RULES"`
+	KeyIDCacheTTL              time.Duration `envconfig:"KEY_ID_CACHE_TTL"`
+	EnvelopeKeysFile           string        `envconfig:"ENVELOPE_KEYS_FILE"`
+	RetryMaxAttempts           int           `envconfig:"RETRY_MAX_ATTEMPTS"`
+	RetryInitialBackoff        time.Duration `envconfig:"RETRY_INITIAL_BACKOFF"`
+	RetryMaxBackoff            time.Duration `envconfig:"RETRY_MAX_BACKOFF"`
 	DatasetAPIURL              string        `envconfig:"DATASET_API_URL"`
 	DownloadServiceToken       string        `envconfig:"DOWNLOAD_SERVICE_TOKEN"     json:"-"`
 	DatasetAuthToken           string        `envconfig:"DATASET_AUTH_TOKEN"         json:"-"`
@@ -34,11 +47,34 @@ type Config struct {
 	IsPublishing               bool          `envconfig:"IS_PUBLISHING"`
 	PublicBucketURL            URL           `envconfig:"PUBLIC_BUCKET_URL"`
 	MaxConcurrentHandlers      int           `envconfig:"MAX_CONCURRENT_HANDLERS"`
+	MaxBundleFiles             int           `envconfig:"MAX_BUNDLE_FILES"`
+	CompressionLevel           int           `envconfig:"COMPRESSION_LEVEL"`
+	ServePrecompressed         bool          `envconfig:"SERVE_PRECOMPRESSED"`
+	PreviewMaxRows             int           `envconfig:"PREVIEW_MAX_ROWS"`
+	PreviewMaxBytes            int64         `envconfig:"PREVIEW_MAX_BYTES"`
+	ParquetRowGroupBytes       int64         `envconfig:"PARQUET_ROW_GROUP_BYTES"`
+	ImageSizes                 []int         `envconfig:"IMAGE_SIZES"`
+	ImageMaxPixels             int           `envconfig:"IMAGE_MAX_PIXELS"`
+	ImageCacheBucketName       string        `envconfig:"IMAGE_CACHE_BUCKET_NAME"`
+	DefaultLicenceURL          string        `envconfig:"DEFAULT_LICENCE_URL"`
+	ProvenanceHeaders          bool          `envconfig:"PROVENANCE_HEADERS"`
+	CSVWLinks                  bool          `envconfig:"CSVW_LINKS"`
+	InlineDisposition          bool          `envconfig:"INLINE_DISPOSITION"`
+	WatermarkPreRelease        bool          `envconfig:"WATERMARK_PRE_RELEASE"`
+	FilesPipelineRoutes        []string      `envconfig:"FILES_PIPELINE_ROUTES"`
+	XLSVariants                []string      `envconfig:"XLS_VARIANTS"`
+	XLSXVariants               []string      `envconfig:"XLSX_VARIANTS"`
+	LatestMaxAge               time.Duration `envconfig:"LATEST_MAX_AGE"`
+	LatestIncludesUnpublished  bool          `envconfig:"LATEST_INCLUDES_UNPUBLISHED"`
+	ServeS3Metrics             bool          `envconfig:"SERVE_S3_METRICS"`
 	AuthorisationConfig        *authorisation.Config
 }
 
 var cfg *Config
 
+// ErrInvalidFailoverPolicy is returned when S3_FAILOVER_POLICY is not one of the supported policies
+var ErrInvalidFailoverPolicy = errors.New("invalid S3_FAILOVER_POLICY: must be one of never, retryable or any")
+
 type URL struct {
 	url.URL
 }
@@ -55,6 +91,19 @@ func (c *URL) Decode(value string) error {
 	return nil
 }
 
+// UsesFilesPipeline reports whether a legacy route, such as datasets.csv or images, is handled in the same way as files
+// registered with the files API. Routes are switched over one at a time, or by their prefix, e.g. datasets for all
+// dataset version downloads.
+func (c *Config) UsesFilesPipeline(route string) bool {
+	prefix, _, _ := strings.Cut(route, ".")
+	for _, r := range c.FilesPipelineRoutes {
+		if r == route || r == prefix {
+			return true
+		}
+	}
+	return false
+}
+
 // Get retrieves the config from the environment for the dp-download-service
 func Get() (*Config, error) {
 	if cfg != nil {
@@ -65,6 +114,17 @@ func Get() (*Config, error) {
 		BindAddr:                   "localhost:23600",
 		AwsRegion:                  "eu-west-2",
 		BucketName:                 "csv-exported",
+		SecondaryBucketName:        "",
+		SecondaryAwsRegion:         "",
+		S3FailoverPolicy:           "retryable",
+		S3PrimaryTimeout:           10 * time.Second,
+		S3EncryptionMode:           "none",
+		S3EncryptionRules:          "",
+		KeyIDCacheTTL:              5 * time.Minute,
+		EnvelopeKeysFile:           "",
+		RetryMaxAttempts:           3,
+		RetryInitialBackoff:        100 * time.Millisecond,
+		RetryMaxBackoff:            2 * time.Second,
 		DatasetAPIURL:              "http://localhost:22000",
 		FilesAPIURL:                "http://localhost:26900",
 		FilterAPIURL:               "http://localhost:22100",
@@ -81,6 +141,26 @@ func Get() (*Config, error) {
 		IsPublishing:               true,
 		PublicBucketURL:            URL{},
 		MaxConcurrentHandlers:      0, // unlimited
+		MaxBundleFiles:             100,
+		CompressionLevel:           5,
+		ServePrecompressed:         false,
+		PreviewMaxRows:             100,
+		PreviewMaxBytes:            1024 * 1024,
+		ParquetRowGroupBytes:       8 * 1024 * 1024,
+		ImageSizes:                 []int{100, 200, 400, 800, 1600},
+		ImageMaxPixels:             50 * 1000 * 1000,
+		ImageCacheBucketName:       "",
+		DefaultLicenceURL:          "https://www.nationalarchives.gov.uk/doc/open-government-licence/version/3/",
+		ProvenanceHeaders:          false,
+		CSVWLinks:                  false,
+		InlineDisposition:          false,
+		WatermarkPreRelease:        false,
+		FilesPipelineRoutes:        []string{},
+		XLSVariants:                []string{"xls", "xlsx"},
+		XLSXVariants:               []string{"xlsx", "xls"},
+		LatestMaxAge:               time.Minute,
+		LatestIncludesUnpublished:  false,
+		ServeS3Metrics:             false,
 		AuthorisationConfig:        authorisation.NewDefaultConfig(),
 	}
 
@@ -88,5 +168,15 @@ func Get() (*Config, error) {
 		return cfg, err
 	}
 
-	return cfg, nil
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that config values that are not checked when they are parsed are valid
+func (c *Config) Validate() error {
+	switch c.S3FailoverPolicy {
+	case "never", "retryable", "any":
+	default:
+		return fmt.Errorf("%w, not %q", ErrInvalidFailoverPolicy, c.S3FailoverPolicy)
+	}
+	return nil
 }
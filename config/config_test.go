@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"net/url"
 	"os"
 	"testing"
@@ -16,6 +17,17 @@ func getConfigEnv() map[string]string {
 	return map[string]string{
 		"BIND_ADDR":                    os.Getenv("BIND_ADDR"),
 		"BUCKET_NAME":                  os.Getenv("BUCKET_NAME"),
+		"SECONDARY_BUCKET_NAME":        os.Getenv("SECONDARY_BUCKET_NAME"),
+		"SECONDARY_AWS_REGION":         os.Getenv("SECONDARY_AWS_REGION"),
+		"S3_FAILOVER_POLICY":           os.Getenv("S3_FAILOVER_POLICY"),
+		"S3_PRIMARY_TIMEOUT":           os.Getenv("S3_PRIMARY_TIMEOUT"),
+		"S3_ENCRYPTION_MODE":           os.Getenv("S3_ENCRYPTION_MODE"),
+		"S3_ENCRYPTION_RULES":          os.Getenv("S3_ENCRYPTION_RULES"),
+		"KEY_ID_CACHE_TTL":             os.Getenv("KEY_ID_CACHE_TTL"),
+		"ENVELOPE_KEYS_FILE":           os.Getenv("ENVELOPE_KEYS_FILE"),
+		"RETRY_MAX_ATTEMPTS":           os.Getenv("RETRY_MAX_ATTEMPTS"),
+		"RETRY_INITIAL_BACKOFF":        os.Getenv("RETRY_INITIAL_BACKOFF"),
+		"RETRY_MAX_BACKOFF":            os.Getenv("RETRY_MAX_BACKOFF"),
 		"DATASET_API_URL":              os.Getenv("DATASET_API_URL"),
 		"DOWNLOAD_SERVICE_TOKEN":       os.Getenv("DOWNLOAD_SERVICE_TOKEN"),
 		"DATASET_AUTH_TOKEN":           os.Getenv("DATASET_AUTH_TOKEN"),
@@ -37,6 +49,15 @@ func getConfigEnv() map[string]string {
 		"MONGODB_IS_SSL":               os.Getenv("MONGODB_IS_SSL"),
 		"PUBLIC_BUCKET_URL":            os.Getenv("PUBLIC_BUCKET_URL"),
 		"MAX_CONCURRENT_HANDLERS":      os.Getenv("MAX_CONCURRENT_HANDLERS"),
+		"MAX_BUNDLE_FILES":             os.Getenv("MAX_BUNDLE_FILES"),
+		"COMPRESSION_LEVEL":            os.Getenv("COMPRESSION_LEVEL"),
+		"SERVE_PRECOMPRESSED":          os.Getenv("SERVE_PRECOMPRESSED"),
+		"PREVIEW_MAX_ROWS":             os.Getenv("PREVIEW_MAX_ROWS"),
+		"PREVIEW_MAX_BYTES":            os.Getenv("PREVIEW_MAX_BYTES"),
+		"PARQUET_ROW_GROUP_BYTES":      os.Getenv("PARQUET_ROW_GROUP_BYTES"),
+		"IMAGE_SIZES":                  os.Getenv("IMAGE_SIZES"),
+		"IMAGE_MAX_PIXELS":             os.Getenv("IMAGE_MAX_PIXELS"),
+		"IMAGE_CACHE_BUCKET_NAME":      os.Getenv("IMAGE_CACHE_BUCKET_NAME"),
 	}
 }
 
@@ -68,6 +89,17 @@ func TestSpec(t *testing.T) {
 			Convey("the values should be set to the expected defaults", func() {
 				So(config.BindAddr, ShouldEqual, "localhost:23600")
 				So(config.BucketName, ShouldEqual, "csv-exported")
+				So(config.SecondaryBucketName, ShouldEqual, "")
+				So(config.SecondaryAwsRegion, ShouldEqual, "")
+				So(config.S3FailoverPolicy, ShouldEqual, "retryable")
+				So(config.S3PrimaryTimeout, ShouldEqual, 10*time.Second)
+				So(config.S3EncryptionMode, ShouldEqual, "none")
+				So(config.S3EncryptionRules, ShouldEqual, "")
+				So(config.KeyIDCacheTTL, ShouldEqual, 5*time.Minute)
+				So(config.EnvelopeKeysFile, ShouldEqual, "")
+				So(config.RetryMaxAttempts, ShouldEqual, 3)
+				So(config.RetryInitialBackoff, ShouldEqual, 100*time.Millisecond)
+				So(config.RetryMaxBackoff, ShouldEqual, 2*time.Second)
 				So(config.DatasetAPIURL, ShouldEqual, "http://localhost:22000")
 				So(config.DatasetAuthToken, ShouldEqual, "FD0108EA-825D-411C-9B1D-41EF7727F465")
 				So(config.DownloadServiceToken, ShouldEqual, "QB0108EZ-825D-412C-9B1D-41EF7747F462")
@@ -83,6 +115,26 @@ func TestSpec(t *testing.T) {
 				So(config.MinioSecretKey, ShouldEqual, "")
 				So(config.IsPublishing, ShouldBeTrue)
 				So(config.MaxConcurrentHandlers, ShouldEqual, 0)
+				So(config.MaxBundleFiles, ShouldEqual, 100)
+				So(config.CompressionLevel, ShouldEqual, 5)
+				So(config.ServePrecompressed, ShouldBeFalse)
+				So(config.PreviewMaxRows, ShouldEqual, 100)
+				So(config.PreviewMaxBytes, ShouldEqual, 1048576)
+				So(config.ParquetRowGroupBytes, ShouldEqual, 8388608)
+				So(config.ImageSizes, ShouldResemble, []int{100, 200, 400, 800, 1600})
+				So(config.ImageMaxPixels, ShouldEqual, 50000000)
+				So(config.ImageCacheBucketName, ShouldEqual, "")
+				So(config.DefaultLicenceURL, ShouldEqual, "https://www.nationalarchives.gov.uk/doc/open-government-licence/version/3/")
+				So(config.ProvenanceHeaders, ShouldBeFalse)
+				So(config.CSVWLinks, ShouldBeFalse)
+				So(config.InlineDisposition, ShouldBeFalse)
+				So(config.WatermarkPreRelease, ShouldBeFalse)
+				So(config.FilesPipelineRoutes, ShouldBeEmpty)
+				So(config.XLSVariants, ShouldResemble, []string{"xls", "xlsx"})
+				So(config.XLSXVariants, ShouldResemble, []string{"xlsx", "xls"})
+				So(config.LatestMaxAge, ShouldEqual, time.Minute)
+				So(config.LatestIncludesUnpublished, ShouldBeFalse)
+				So(config.ServeS3Metrics, ShouldBeFalse)
 
 				expectedUrl, _ := url.Parse("http://test")
 				So(config.PublicBucketURL, ShouldResemble, URL{*expectedUrl})
@@ -91,6 +143,23 @@ func TestSpec(t *testing.T) {
 	})
 }
 
+func TestUsesFilesPipeline(t *testing.T) {
+	Convey("Given legacy routes switched over to the files pipeline", t, func() {
+		c := &Config{FilesPipelineRoutes: []string{"datasets.csv", "images"}}
+
+		Convey("routes are switched over by name", func() {
+			So(c.UsesFilesPipeline("datasets.csv"), ShouldBeTrue)
+			So(c.UsesFilesPipeline("datasets.xlsx"), ShouldBeFalse)
+			So(c.UsesFilesPipeline("filter-outputs.csv"), ShouldBeFalse)
+		})
+
+		Convey("routes are switched over by prefix", func() {
+			So(c.UsesFilesPipeline("images"), ShouldBeTrue)
+			So(c.UsesFilesPipeline("images.png"), ShouldBeTrue)
+		})
+	})
+}
+
 func TestBadPublicBucketUrl(t *testing.T) {
 	Convey("Given an environment variable with a bad public-bucket url", t, func() {
 		originalConfigEnv := getConfigEnv()
@@ -112,3 +181,17 @@ func TestBadPublicBucketUrl(t *testing.T) {
 		})
 	})
 }
+
+func TestValidate(t *testing.T) {
+	Convey("Given a supported S3 failover policy then the config is valid", t, func() {
+		for _, policy := range []string{"never", "retryable", "any"} {
+			So((&Config{S3FailoverPolicy: policy}).Validate(), ShouldBeNil)
+		}
+	})
+
+	Convey("Given an unknown S3 failover policy then the config is invalid", t, func() {
+		err := (&Config{S3FailoverPolicy: "sometimes"}).Validate()
+
+		So(errors.Is(err, ErrInvalidFailoverPolicy), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,66 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Cache stores objects derived from other objects, such as resized images, so they are only generated once
+type Cache interface {
+	Get(ctx context.Context, key string) (io.ReadCloser, *int64, error)
+	Put(ctx context.Context, key, contentType string, body []byte) error
+}
+
+// ObjectStore is the subset of the AWS SDK S3 client used to read and write cached objects
+type ObjectStore interface {
+	ObjectGetter
+	PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Cache is a Cache in an S3 bucket
+type S3Cache struct {
+	Objects ObjectStore
+	Bucket  string
+}
+
+// NewS3Cache creates a new S3Cache
+func NewS3Cache(objects ObjectStore, bucket string) *S3Cache {
+	return &S3Cache{
+		Objects: objects,
+		Bucket:  bucket,
+	}
+}
+
+// Get returns a cached object and its size
+func (c *S3Cache) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	out, err := c.Objects.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting cached object from s3: %w", err)
+	}
+
+	return out.Body, out.ContentLength, nil
+}
+
+// Put stores an object in the cache
+func (c *S3Cache) Put(ctx context.Context, key, contentType string, body []byte) error {
+	_, err := c.Objects.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(c.Bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(body),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(int64(len(body))),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting cached object to s3: %w", err)
+	}
+
+	return nil
+}
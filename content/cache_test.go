@@ -0,0 +1,76 @@
+package content
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// cacheStorage is a stand-in for an S3 bucket that stores the objects put to it
+type cacheStorage struct {
+	mu           sync.Mutex
+	objects      map[string]string
+	contentTypes map[string]string
+}
+
+func (st *cacheStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+testBucket+"/")
+
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		st.objects[key] = string(b)
+		st.contentTypes[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		obj, ok := st.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj)))
+		w.Write([]byte(obj)) // nolint
+	}
+}
+
+func TestS3Cache(t *testing.T) {
+	ctx := context.Background()
+
+	storage := &cacheStorage{objects: map[string]string{}, contentTypes: map[string]string{}}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	cache := NewS3Cache(newStandInObjectGetter(server.URL), testBucket)
+
+	Convey("should store objects with their content type", t, func() {
+		err := cache.Put(ctx, "derived/images/1/original/100x0-contain.png", "image/png", []byte("image"))
+
+		So(err, ShouldBeNil)
+		So(storage.objects["derived/images/1/original/100x0-contain.png"], ShouldEqual, "image")
+		So(storage.contentTypes["derived/images/1/original/100x0-contain.png"], ShouldEqual, "image/png")
+	})
+
+	Convey("should read cached objects and their size", t, func() {
+		body, size, err := cache.Get(ctx, "derived/images/1/original/100x0-contain.png")
+
+		So(err, ShouldBeNil)
+		So(*size, ShouldEqual, 5)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "image")
+	})
+
+	Convey("should return an error for objects that are not cached", t, func() {
+		_, _, err := cache.Get(ctx, "derived/images/2/original/100x0-contain.png")
+
+		So(err, ShouldNotBeNil)
+	})
+}
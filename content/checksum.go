@@ -0,0 +1,182 @@
+package content
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrChecksumNotAvailable is returned when no SHA-256 checksum of the whole object is stored with it
+var ErrChecksumNotAvailable = errors.New("sha256 checksum not available")
+
+// ChecksumGetter is implemented by S3 clients that can read the SHA-256 checksum stored with an object
+type ChecksumGetter interface {
+	// GetChecksum returns the hex encoded SHA-256 checksum of the whole object, or ErrChecksumNotAvailable if S3
+	// does not have one.
+	GetChecksum(ctx context.Context, key string) (string, error)
+}
+
+// ObjectHeader is the subset of the AWS SDK S3 client used to read the attributes of an object
+type ObjectHeader interface {
+	HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// Checksum returns the hex encoded SHA-256 checksum of an object. The checksum stored with the object is used if s3c
+// can read it. Otherwise the checksum is read from the cache or, failing that, computed by reading the whole object
+// and stored in the cache. The cache may be nil.
+func Checksum(ctx context.Context, s3c S3Client, cache Cache, key string) (string, error) {
+	sum, err := getChecksum(ctx, s3c, key)
+	if err == nil {
+		return sum, nil
+	}
+	if !errors.Is(err, ErrChecksumNotAvailable) {
+		log.Warn(ctx, "failed to read stored checksum, computing it instead", log.Data{"key": key, "error": err.Error()})
+	}
+
+	return CachedChecksum(ctx, cache, key, func() (io.ReadCloser, error) {
+		body, _, err := s3c.Get(ctx, key)
+		return body, err
+	})
+}
+
+// CachedChecksum returns the checksum of an object from the cache, computing it from the content returned by open
+// and storing it in the cache if it is not there. Objects are not changed once they have been uploaded, so cached
+// checksums never expire. The cache may be nil.
+func CachedChecksum(ctx context.Context, cache Cache, key string, open func() (io.ReadCloser, error)) (string, error) {
+	cacheKey := ChecksumKey(key)
+
+	if cache != nil {
+		if sum, err := cachedChecksum(ctx, cache, cacheKey); err == nil {
+			return sum, nil
+		}
+	}
+
+	body, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer closeAndLogError(ctx, body)
+
+	h := sha256.New()
+	if _, err = io.Copy(h, body); err != nil {
+		return "", fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	if cache != nil {
+		if err = cache.Put(ctx, cacheKey, "text/plain", []byte(sum)); err != nil {
+			log.Warn(ctx, "failed to cache checksum", log.Data{"key": cacheKey, "error": err.Error()})
+		}
+	}
+
+	return sum, nil
+}
+
+// ChecksumKey returns the storage key of the cached checksum of the object with the source key
+func ChecksumKey(source string) string {
+	return "derived/" + strings.TrimPrefix(source, "/") + ".sha256"
+}
+
+func cachedChecksum(ctx context.Context, cache Cache, key string) (string, error) {
+	body, _, err := cache.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer closeAndLogError(ctx, body)
+
+	b, err := io.ReadAll(io.LimitReader(body, sha256.Size*2+1))
+	if err != nil {
+		return "", err
+	}
+
+	sum := string(b)
+	if len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid cached checksum %q", sum)
+	}
+	if _, err = hex.DecodeString(sum); err != nil {
+		return "", fmt.Errorf("invalid cached checksum %q", sum)
+	}
+
+	return sum, nil
+}
+
+// getChecksum reads the checksum stored with an object if s3c supports it
+func getChecksum(ctx context.Context, s3c S3Client, key string) (string, error) {
+	if cg, ok := s3c.(ChecksumGetter); ok {
+		return cg.GetChecksum(ctx, key)
+	}
+	return "", ErrChecksumNotAvailable
+}
+
+// GetChecksum returns the SHA-256 checksum stored with the object, if the AWS SDK client can read object attributes.
+// Checksums of multipart uploads that are composed from the checksums of each part are not checksums of the whole
+// object, so are not available.
+func (r *RangedS3Client) GetChecksum(ctx context.Context, key string) (string, error) {
+	objects, ok := r.Objects.(ObjectHeader)
+	if !ok {
+		return "", ErrChecksumNotAvailable
+	}
+
+	out, err := objects.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(r.Bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting object attributes from s3: %w", err)
+	}
+
+	if out.ChecksumSHA256 == nil || out.ChecksumType == types.ChecksumTypeComposite {
+		return "", ErrChecksumNotAvailable
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(aws.ToString(out.ChecksumSHA256))
+	if err != nil || len(sum) != sha256.Size {
+		return "", ErrChecksumNotAvailable
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// GetChecksum returns the checksum stored with the object using the wrapped S3Client
+func (r *RetryingS3Client) GetChecksum(ctx context.Context, key string) (string, error) {
+	return getChecksum(ctx, r.S3Client, key)
+}
+
+// GetChecksum returns the checksum stored with the object in the primary bucket. If it cannot be read the checksum is
+// computed from the object, which is read with failover.
+func (f *FailoverS3Client) GetChecksum(ctx context.Context, key string) (string, error) {
+	return getChecksum(ctx, f.Primary, key)
+}
+
+// GetChecksum returns the checksum stored with the object. Checksums of SSE-C encrypted objects can only be read
+// with the customer key, so are not available.
+func (e *EncryptedS3Client) GetChecksum(ctx context.Context, key string) (string, error) {
+	customerKey, err := e.customerKey(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if customerKey != nil {
+		return "", ErrChecksumNotAvailable
+	}
+	return getChecksum(ctx, e.S3Client, key)
+}
+
+// GetChecksum returns the checksum stored with the object. The checksum of an encrypted object is the checksum of
+// its ciphertext, so is not available.
+func (e *EnvelopeS3Client) GetChecksum(ctx context.Context, key string) (string, error) {
+	if e.encrypted(key) {
+		return "", ErrChecksumNotAvailable
+	}
+	return getChecksum(ctx, e.S3Client, key)
+}
@@ -0,0 +1,158 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const checksumContent = "file content"
+
+// checksumStorage is a stand-in for an S3 bucket that returns the checksums stored with its objects when asked for
+// them and counts how many times each object is read
+type checksumStorage struct {
+	objects   map[string]string
+	checksums map[string]string
+	reads     map[string]int
+}
+
+func (st *checksumStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/"+testBucket+"/")
+	obj, ok := st.objects[key]
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	if sum, ok := st.checksums[key]; ok && r.Header.Get("X-Amz-Checksum-Mode") == "ENABLED" {
+		w.Header().Set("X-Amz-Checksum-Sha256", sum)
+		w.Header().Set("X-Amz-Checksum-Type", "FULL_OBJECT")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj)))
+
+	if r.Method == http.MethodGet {
+		st.reads[key]++
+		w.Write([]byte(obj)) // nolint
+	}
+}
+
+// memoryCache is a Cache held in memory
+type memoryCache map[string][]byte
+
+func (c memoryCache) Get(_ context.Context, key string) (io.ReadCloser, *int64, error) {
+	b, ok := c[key]
+	if !ok {
+		return nil, nil, errors.New("not cached")
+	}
+	size := int64(len(b))
+	return io.NopCloser(bytes.NewReader(b)), &size, nil
+}
+
+func (c memoryCache) Put(_ context.Context, key, _ string, body []byte) error {
+	c[key] = body
+	return nil
+}
+
+func TestChecksum(t *testing.T) {
+	ctx := context.Background()
+
+	digest := sha256.Sum256([]byte(checksumContent))
+	expected := hex.EncodeToString(digest[:])
+
+	storage := &checksumStorage{
+		objects: map[string]string{
+			"stored/file.csv":   checksumContent,
+			"computed/file.csv": checksumContent,
+			"envelope/file.csv": checksumContent,
+		},
+		checksums: map[string]string{
+			"stored/file.csv":   base64.StdEncoding.EncodeToString(digest[:]),
+			"envelope/file.csv": base64.StdEncoding.EncodeToString(digest[:]),
+		},
+		reads: map[string]int{},
+	}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	objects := newStandInObjectGetter(server.URL)
+	s3c := NewRangedS3Client(&standInS3Client{objects: objects}, objects, testBucket)
+
+	Convey("should use the checksum stored with the object", t, func() {
+		sum, err := Checksum(ctx, s3c, nil, "stored/file.csv")
+
+		So(err, ShouldBeNil)
+		So(sum, ShouldEqual, expected)
+		So(storage.reads["stored/file.csv"], ShouldEqual, 0)
+	})
+
+	Convey("should compute the checksum and cache it when none is stored", t, func() {
+		cache := memoryCache{}
+
+		sum, err := Checksum(ctx, s3c, cache, "computed/file.csv")
+
+		So(err, ShouldBeNil)
+		So(sum, ShouldEqual, expected)
+		So(string(cache["derived/computed/file.csv.sha256"]), ShouldEqual, expected)
+
+		Convey("and read it from the cache afterwards", func() {
+			reads := storage.reads["computed/file.csv"]
+
+			sum, err := Checksum(ctx, s3c, cache, "computed/file.csv")
+
+			So(err, ShouldBeNil)
+			So(sum, ShouldEqual, expected)
+			So(storage.reads["computed/file.csv"], ShouldEqual, reads)
+		})
+	})
+
+	Convey("should ignore invalid cached checksums", t, func() {
+		cache := memoryCache{"derived/computed/file.csv.sha256": []byte("not a checksum")}
+
+		sum, err := Checksum(ctx, s3c, cache, "computed/file.csv")
+
+		So(err, ShouldBeNil)
+		So(sum, ShouldEqual, expected)
+		So(string(cache["derived/computed/file.csv.sha256"]), ShouldEqual, expected)
+	})
+
+	Convey("should not use the stored checksum of an envelope encrypted object", t, func() {
+		envelope := NewEnvelopeS3Client(s3c, nil, []EncryptionRule{{Prefix: "envelope/", Mode: EncryptionEnvelope}})
+
+		_, err := envelope.GetChecksum(ctx, "envelope/file.csv")
+
+		So(errors.Is(err, ErrChecksumNotAvailable), ShouldBeTrue)
+	})
+
+	Convey("should return an error for objects that do not exist", t, func() {
+		_, err := Checksum(ctx, s3c, nil, "missing/file.csv")
+
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// standInS3Client is an S3Client that reads whole objects with the AWS SDK
+type standInS3Client struct {
+	S3Client
+	objects ObjectGetter
+}
+
+func (c *standInS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	out, err := c.objects.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(testBucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Body, out.ContentLength, nil
+}
@@ -0,0 +1,322 @@
+package content
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec // S3 requires the MD5 digest of SSE-C keys as an integrity check
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// EncryptionMode determines how the key used to read an object is obtained
+type EncryptionMode string
+
+// Possible values for an EncryptionMode
+const (
+	EncryptionNone      EncryptionMode = "none"         // object is not encrypted with a customer-provided key
+	EncryptionSSEC      EncryptionMode = "sse-c"        // SSE-C with a single key derived from the master secret
+	EncryptionSSECPath  EncryptionMode = "sse-c-path"   // SSE-C with a per-file key derived from the master secret and object key
+	EncryptionSSECKeyID EncryptionMode = "sse-c-key-id" // SSE-C with a key derived from the master secret and a key ID
+	EncryptionEnvelope  EncryptionMode = "envelope"     // client-side AES-GCM envelope encryption, see EnvelopeS3Client
+)
+
+const sseCustomerAlgorithm = "AES256"
+
+var (
+	ErrInvalidEncryptionRule = errors.New("invalid encryption rule")
+	ErrMissingSecretKey      = errors.New("a secret key is required for SSE-C encryption")
+)
+
+// EncryptionRule assigns an EncryptionMode to all objects whose key starts with Prefix. An empty Prefix applies
+// to the whole bucket.
+type EncryptionRule struct {
+	Prefix string
+	Mode   EncryptionMode
+	KeyID  string
+}
+
+// ParseEncryptionRules parses a comma separated list of rules in the form prefix=mode or prefix=mode:keyID.
+// The bucket-wide default mode is added as a rule with an empty prefix.
+func ParseEncryptionRules(defaultMode, rules string) ([]EncryptionRule, error) {
+	parsed := []EncryptionRule{}
+
+	if defaultMode != "" {
+		rule, err := newEncryptionRule("", defaultMode)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, rule)
+	}
+
+	for _, r := range strings.Split(rules, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		prefix, mode, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidEncryptionRule, r)
+		}
+
+		rule, err := newEncryptionRule(strings.TrimPrefix(prefix, "/"), mode)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, rule)
+	}
+
+	return parsed, nil
+}
+
+func newEncryptionRule(prefix, mode string) (EncryptionRule, error) {
+	m, keyID, _ := strings.Cut(mode, ":")
+	rule := EncryptionRule{Prefix: prefix, Mode: EncryptionMode(m), KeyID: keyID}
+
+	switch rule.Mode {
+	case EncryptionNone, EncryptionSSEC, EncryptionSSECPath, EncryptionEnvelope:
+	case EncryptionSSECKeyID:
+		if keyID == "" {
+			return rule, fmt.Errorf("%w: mode %s requires a key ID for prefix %q", ErrInvalidEncryptionRule, m, prefix)
+		}
+	default:
+		return rule, fmt.Errorf("%w: unknown mode %q for prefix %q", ErrInvalidEncryptionRule, m, prefix)
+	}
+
+	return rule, nil
+}
+
+// KeyIDFetcher returns the ID of the key an object was encrypted with, as recorded when the file was uploaded, or an
+// empty string if no key ID was recorded
+type KeyIDFetcher func(ctx context.Context, key string) (string, error)
+
+// CacheKeyIDs returns a KeyIDFetcher that remembers the key IDs returned by fetch for ttl, so that retries, failover
+// reads and variant probes of the same object do not each look the key ID up again. At most size key IDs are kept.
+// Errors are not cached.
+func CacheKeyIDs(fetch KeyIDFetcher, ttl time.Duration, size int) KeyIDFetcher {
+	c := &keyIDCache{fetch: fetch, ttl: ttl, size: size, entries: map[string]keyIDEntry{}}
+	return c.keyID
+}
+
+type keyIDEntry struct {
+	keyID   string
+	expires time.Time
+}
+
+type keyIDCache struct {
+	fetch   KeyIDFetcher
+	ttl     time.Duration
+	size    int
+	mu      sync.Mutex
+	entries map[string]keyIDEntry
+}
+
+func (c *keyIDCache) keyID(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.keyID, nil
+	}
+
+	keyID, err := c.fetch(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.size {
+		c.evict()
+	}
+	c.entries[key] = keyIDEntry{keyID: keyID, expires: time.Now().Add(c.ttl)}
+
+	return keyID, nil
+}
+
+// evict removes the expired entries, or an arbitrary entry if none have expired
+func (c *keyIDCache) evict() {
+	now := time.Now()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	for k := range c.entries {
+		if len(c.entries) < c.size {
+			return
+		}
+		delete(c.entries, k)
+	}
+}
+
+// ObjectGetter is the subset of the AWS SDK S3 client used for requests the dp-s3 client does not support
+type ObjectGetter interface {
+	GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// EncryptedS3Client is an S3Client that reads objects encrypted with customer-provided keys (SSE-C). The key for
+// each object is derived from the master secret and the key ID recorded for the object by KeyIDs. Objects without a
+// recorded key ID use the longest matching EncryptionRule, and those that do not match an SSE-C rule are read with
+// the wrapped S3Client. A recorded key ID takes precedence over any rule, including none and envelope rules.
+type EncryptedS3Client struct {
+	S3Client S3Client
+	Objects  ObjectGetter
+	Bucket   string
+	Rules    []EncryptionRule
+	KeyIDs   KeyIDFetcher
+	secret   []byte
+}
+
+// NewEncryptedS3Client creates a new EncryptedS3Client. An error is returned if any rule needs a key and no secret
+// is provided.
+func NewEncryptedS3Client(s3c S3Client, objects ObjectGetter, bucket, secret string, rules []EncryptionRule) (*EncryptedS3Client, error) {
+	sorted := sortRules(rules)
+
+	for _, r := range sorted {
+		if usesCustomerKey(r.Mode) && secret == "" {
+			return nil, ErrMissingSecretKey
+		}
+	}
+
+	return &EncryptedS3Client{
+		S3Client: s3c,
+		Objects:  objects,
+		Bucket:   bucket,
+		Rules:    sorted,
+		secret:   []byte(secret),
+	}, nil
+}
+
+// Get returns the object for the provided key, supplying the derived customer key if the object is SSE-C encrypted.
+func (e *EncryptedS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	customerKey, err := e.customerKey(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if customerKey == nil {
+		return e.S3Client.Get(ctx, key)
+	}
+
+	out, err := e.Objects.GetObject(ctx, e.getObjectInput(key, customerKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting SSE-C object from s3: %w", err)
+	}
+
+	return newObjectBody(out), out.ContentLength, nil
+}
+
+// GetRange returns part of the object for the provided key, supplying the derived customer key if the object is
+// SSE-C encrypted.
+func (e *EncryptedS3Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	customerKey, err := e.customerKey(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if customerKey == nil {
+		return GetRange(ctx, e.S3Client, key, offset, length)
+	}
+
+	return getObjectRange(ctx, e.Objects, e.getObjectInput(key, customerKey), offset, length)
+}
+
+// GetVersion returns part of a specific version of the object for the provided key, supplying the derived customer
+// key if the object is SSE-C encrypted.
+func (e *EncryptedS3Client) GetVersion(ctx context.Context, key, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+	customerKey, err := e.customerKey(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if customerKey == nil {
+		return GetVersion(ctx, e.S3Client, key, versionID, offset, length)
+	}
+
+	in := e.getObjectInput(key, customerKey)
+	in.VersionId = aws.String(versionID)
+
+	return getObjectVersion(ctx, e.Objects, in, offset, length)
+}
+
+// Checker checks the health of the bucket using the wrapped S3Client
+func (e *EncryptedS3Client) Checker(ctx context.Context, check *healthcheck.CheckState) error {
+	return e.S3Client.Checker(ctx, check)
+}
+
+func (e *EncryptedS3Client) getObjectInput(key string, customerKey []byte) *s3.GetObjectInput {
+	digest := md5.Sum(customerKey) //nolint:gosec // see import
+
+	return &s3.GetObjectInput{
+		Bucket:               aws.String(e.Bucket),
+		Key:                  aws.String(key),
+		SSECustomerAlgorithm: aws.String(sseCustomerAlgorithm),
+		SSECustomerKey:       aws.String(base64.StdEncoding.EncodeToString(customerKey)),
+		SSECustomerKeyMD5:    aws.String(base64.StdEncoding.EncodeToString(digest[:])),
+	}
+}
+
+// customerKey returns the 256-bit SSE-C key for the object, or nil if the object is not SSE-C encrypted. The key ID
+// recorded for the object takes precedence over the matching rule, even a none or envelope rule, as an object with a
+// recorded key ID was always written with SSE-C.
+func (e *EncryptedS3Client) customerKey(ctx context.Context, key string) ([]byte, error) {
+	key = strings.TrimPrefix(key, "/")
+
+	if e.KeyIDs != nil {
+		keyID, err := e.KeyIDs(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("error getting key ID for SSE-C object: %w", err)
+		}
+		if keyID != "" {
+			return DeriveKey(e.secret, "key-id", keyID), nil
+		}
+	}
+
+	rule, ok := matchRule(e.Rules, key)
+	if !ok {
+		return nil, nil
+	}
+
+	switch rule.Mode {
+	case EncryptionSSEC:
+		return DeriveKey(e.secret, "bucket", ""), nil
+	case EncryptionSSECPath:
+		return DeriveKey(e.secret, "path", key), nil
+	case EncryptionSSECKeyID:
+		return DeriveKey(e.secret, "key-id", rule.KeyID), nil
+	default:
+		return nil, nil
+	}
+}
+
+// sortRules returns a copy of the rules ordered so that longer, more specific prefixes are matched first
+func sortRules(rules []EncryptionRule) []EncryptionRule {
+	sorted := append([]EncryptionRule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Prefix) > len(sorted[j].Prefix) })
+	return sorted
+}
+
+// usesCustomerKey reports whether objects with the EncryptionMode are read with an SSE-C customer key
+func usesCustomerKey(mode EncryptionMode) bool {
+	return mode == EncryptionSSEC || mode == EncryptionSSECPath || mode == EncryptionSSECKeyID
+}
+
+// DeriveKey derives a 256-bit key from the master secret for the given purpose (such as "path") and value using
+// HMAC-SHA256, so that the upload pipeline can derive the same key independently.
+func DeriveKey(secret []byte, purpose, value string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose + ":" + value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,279 @@
+package content
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // matches the S3 SSE-C key digest
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-download-service/content/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testBucket = "private-bucket"
+	testSecret = "master-secret"
+)
+
+// sseCStorage is a stand-in for S3 that serves objects only when the SSE-C headers match the object's key,
+// mirroring the validation S3 performs for objects stored with customer-provided keys.
+type sseCStorage struct {
+	objects map[string]sseCObject
+}
+
+type sseCObject struct {
+	content string
+	key     []byte
+}
+
+func (st *sseCStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	obj, ok := st.objects[strings.TrimPrefix(r.URL.Path, "/"+testBucket+"/")]
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	algorithm := r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm")
+	key := r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key")
+	keyMD5 := r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5")
+
+	if obj.key == nil {
+		if algorithm != "" {
+			http.Error(w, "<Error><Code>InvalidRequest</Code></Error>", http.StatusBadRequest)
+			return
+		}
+	} else {
+		digest := md5.Sum(obj.key) //nolint:gosec // see import
+		if algorithm != "AES256" || key != base64.StdEncoding.EncodeToString(obj.key) || keyMD5 != base64.StdEncoding.EncodeToString(digest[:]) {
+			http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+			return
+		}
+	}
+
+	w.Write([]byte(obj.content)) // nolint
+}
+
+func newStandInObjectGetter(url string) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "eu-west-2",
+		BaseEndpoint: aws.String(url),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	})
+}
+
+func TestParseEncryptionRules(t *testing.T) {
+	Convey("should parse a default mode and prefix rules", t, func() {
+		rules, err := ParseEncryptionRules("none", "pre-publication/=sse-c-path, /archive/=sse-c-key-id:2026-01")
+
+		So(err, ShouldBeNil)
+		So(rules, ShouldResemble, []EncryptionRule{
+			{Prefix: "", Mode: EncryptionNone},
+			{Prefix: "pre-publication/", Mode: EncryptionSSECPath},
+			{Prefix: "archive/", Mode: EncryptionSSECKeyID, KeyID: "2026-01"},
+		})
+	})
+
+	Convey("should reject unknown modes", t, func() {
+		_, err := ParseEncryptionRules("", "a/=sse-kms")
+		So(errors.Is(err, ErrInvalidEncryptionRule), ShouldBeTrue)
+	})
+
+	Convey("should reject key ID rules without a key ID", t, func() {
+		_, err := ParseEncryptionRules("sse-c-key-id", "")
+		So(errors.Is(err, ErrInvalidEncryptionRule), ShouldBeTrue)
+	})
+
+	Convey("should reject rules without a mode", t, func() {
+		_, err := ParseEncryptionRules("", "pre-publication/")
+		So(errors.Is(err, ErrInvalidEncryptionRule), ShouldBeTrue)
+	})
+}
+
+func TestEncryptedS3Client_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	secret := []byte(testSecret)
+
+	storage := &sseCStorage{objects: map[string]sseCObject{
+		"pre-publication/data.csv": {content: "path key", key: DeriveKey(secret, "path", "pre-publication/data.csv")},
+		"archive/data.csv":         {content: "key id", key: DeriveKey(secret, "key-id", "2026-01")},
+		"bucket/data.csv":          {content: "bucket key", key: DeriveKey(secret, "bucket", "")},
+		"archive/rotated.csv":      {content: "recorded key id", key: DeriveKey(secret, "key-id", "2026-02")},
+		"public/rotated.csv":       {content: "recorded key id", key: DeriveKey(secret, "key-id", "2026-02")},
+	}}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	rules, err := ParseEncryptionRules("sse-c", "pre-publication/=sse-c-path,archive/=sse-c-key-id:2026-01,public/=none")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("should read objects with per-file keys derived from the path", t, func() {
+		e, err := NewEncryptedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket, testSecret, rules)
+		So(err, ShouldBeNil)
+
+		body, _, err := e.Get(ctx, "pre-publication/data.csv")
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "path key")
+	})
+
+	Convey("should read objects with keys derived from the rule's key ID", t, func() {
+		e, _ := NewEncryptedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket, testSecret, rules)
+
+		body, _, err := e.Get(ctx, "archive/data.csv")
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "key id")
+	})
+
+	Convey("should read objects with keys derived from the key ID recorded for the object", t, func() {
+		e, _ := NewEncryptedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket, testSecret, rules)
+		e.KeyIDs = func(ctx context.Context, key string) (string, error) {
+			if key == "archive/rotated.csv" {
+				return "2026-02", nil
+			}
+			return "", nil
+		}
+
+		body, _, err := e.Get(ctx, "archive/rotated.csv")
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "recorded key id")
+
+		Convey("even when the object matches a rule for unencrypted objects", func() {
+			e.KeyIDs = func(ctx context.Context, key string) (string, error) { return "2026-02", nil }
+
+			body, _, err := e.Get(ctx, "public/rotated.csv")
+
+			So(err, ShouldBeNil)
+			b, _ := io.ReadAll(body)
+			So(string(b), ShouldEqual, "recorded key id")
+		})
+
+		Convey("and fall back to the rule for objects without a recorded key ID", func() {
+			body, _, err := e.Get(ctx, "archive/data.csv")
+
+			So(err, ShouldBeNil)
+			b, _ := io.ReadAll(body)
+			So(string(b), ShouldEqual, "key id")
+		})
+	})
+
+	Convey("should fail when the key ID of the object cannot be read", t, func() {
+		errKeyID := errors.New("files api unavailable")
+		e, _ := NewEncryptedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket, testSecret, rules)
+		e.KeyIDs = func(ctx context.Context, key string) (string, error) { return "", errKeyID }
+
+		_, _, err := e.Get(ctx, "archive/data.csv")
+
+		So(errors.Is(err, errKeyID), ShouldBeTrue)
+	})
+
+	Convey("should use the bucket-wide key for objects matching no prefix rule", t, func() {
+		e, _ := NewEncryptedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket, testSecret, rules)
+
+		body, _, err := e.Get(ctx, "bucket/data.csv")
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "bucket key")
+	})
+
+	Convey("should fail when the derived key does not match the object's key", t, func() {
+		e, _ := NewEncryptedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket, "wrong-secret", rules)
+
+		_, _, err := e.Get(ctx, "pre-publication/data.csv")
+
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should read unencrypted prefixes with the wrapped client", t, func() {
+		s3Cli := s3ClientGetReturnsReader(ctrl, "public/data.csv", io.NopCloser(strings.NewReader("plain")))
+		e, _ := NewEncryptedS3Client(s3Cli, newStandInObjectGetter(server.URL), testBucket, testSecret, rules)
+
+		body, _, err := e.Get(ctx, "public/data.csv")
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "plain")
+	})
+
+	Convey("should refuse to be created without a secret when a rule needs a key", t, func() {
+		_, err := NewEncryptedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket, "", rules)
+
+		So(err, ShouldEqual, ErrMissingSecretKey)
+	})
+}
+
+func TestCacheKeyIDs(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a cached key ID fetcher", t, func() {
+		calls := 0
+		fetch := func(ctx context.Context, key string) (string, error) {
+			calls++
+			return "id-" + key, nil
+		}
+
+		Convey("key IDs should only be fetched once while they are cached", func() {
+			keyIDs := CacheKeyIDs(fetch, time.Minute, 10)
+
+			for i := 0; i < 3; i++ {
+				keyID, err := keyIDs(ctx, "data.csv")
+				So(err, ShouldBeNil)
+				So(keyID, ShouldEqual, "id-data.csv")
+			}
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("expired key IDs should be fetched again", func() {
+			keyIDs := CacheKeyIDs(fetch, 0, 10)
+
+			_, _ = keyIDs(ctx, "data.csv")
+			_, _ = keyIDs(ctx, "data.csv")
+
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("no more than size key IDs should be kept", func() {
+			cache := &keyIDCache{fetch: fetch, ttl: time.Minute, size: 2, entries: map[string]keyIDEntry{}}
+
+			for _, key := range []string{"a.csv", "b.csv", "c.csv"} {
+				_, _ = cache.keyID(ctx, key)
+			}
+
+			So(cache.entries, ShouldHaveLength, 2)
+			So(cache.entries, ShouldContainKey, "c.csv")
+		})
+
+		Convey("errors should not be cached", func() {
+			errFetch := errors.New("files api unavailable")
+			keyIDs := CacheKeyIDs(func(ctx context.Context, key string) (string, error) {
+				calls++
+				return "", errFetch
+			}, time.Minute, 10)
+
+			_, err := keyIDs(ctx, "data.csv")
+			So(errors.Is(err, errFetch), ShouldBeTrue)
+			_, _ = keyIDs(ctx, "data.csv")
+
+			So(calls, ShouldEqual, 2)
+		})
+	})
+}
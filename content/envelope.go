@@ -0,0 +1,310 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// Client-side encrypted objects start with a header, followed by the plaintext split into frames of FrameSize bytes
+// (the last frame may be shorter), each sealed with AES-GCM under the object's data key:
+//
+//	magic "DPE1" | frame size uint32 | key id length uint8 | key id | wrapped key length uint16 | wrapped key | nonce prefix [7]byte
+//
+// The nonce of each frame is the nonce prefix, the frame index as a uint32 and a byte set to 1 for the last frame,
+// so that frames cannot be reordered and truncation is detected.
+const (
+	envelopeMagic         = "DPE1"
+	envelopeNoncePrefix   = 7
+	envelopeTagSize       = 16
+	maxEnvelopeHeaderSize = 1024
+)
+
+var (
+	ErrInvalidEnvelope     = errors.New("invalid envelope encrypted object")
+	ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+	ErrFrameAuthentication = errors.New("failed to authenticate encrypted frame")
+)
+
+type envelopeHeader struct {
+	length      int64
+	frameSize   int64
+	keyID       string
+	wrappedKey  []byte
+	noncePrefix []byte
+}
+
+// frames returns the number of frames in an object of the given total size
+func (h envelopeHeader) frames(objectSize int64) int64 {
+	return (objectSize - h.length + h.frameSize + envelopeTagSize - 1) / (h.frameSize + envelopeTagSize)
+}
+
+func parseEnvelopeHeader(b []byte) (envelopeHeader, error) {
+	var h envelopeHeader
+	r := bytes.NewReader(b)
+
+	magic := make([]byte, len(envelopeMagic))
+	var frameSize uint32
+	var keyIDLen uint8
+	var wrappedKeyLen uint16
+
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != envelopeMagic {
+		return h, fmt.Errorf("%w: missing header", ErrInvalidEnvelope)
+	}
+	if err := binary.Read(r, binary.BigEndian, &frameSize); err != nil || frameSize == 0 {
+		return h, fmt.Errorf("%w: bad frame size", ErrInvalidEnvelope)
+	}
+	if err := binary.Read(r, binary.BigEndian, &keyIDLen); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrInvalidEnvelope)
+	}
+	keyID := make([]byte, keyIDLen)
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrInvalidEnvelope)
+	}
+	if err := binary.Read(r, binary.BigEndian, &wrappedKeyLen); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrInvalidEnvelope)
+	}
+	h.wrappedKey = make([]byte, wrappedKeyLen)
+	if _, err := io.ReadFull(r, h.wrappedKey); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrInvalidEnvelope)
+	}
+	h.noncePrefix = make([]byte, envelopeNoncePrefix)
+	if _, err := io.ReadFull(r, h.noncePrefix); err != nil {
+		return h, fmt.Errorf("%w: truncated header", ErrInvalidEnvelope)
+	}
+
+	h.length = int64(len(b) - r.Len())
+	h.frameSize = int64(frameSize)
+	h.keyID = string(keyID)
+
+	return h, nil
+}
+
+// EnvelopeS3Client is an S3Client that decrypts client-side encrypted objects as they are streamed. Objects are
+// decrypted if they match an EncryptionRule with the envelope mode; anything else is read unchanged.
+type EnvelopeS3Client struct {
+	S3Client S3Client
+	Keys     KeyProvider
+	Rules    []EncryptionRule
+}
+
+// NewEnvelopeS3Client creates a new EnvelopeS3Client
+func NewEnvelopeS3Client(s3c S3Client, keys KeyProvider, rules []EncryptionRule) *EnvelopeS3Client {
+	return &EnvelopeS3Client{
+		S3Client: s3c,
+		Keys:     keys,
+		Rules:    sortRules(rules),
+	}
+}
+
+// Get returns the decrypted object and its plaintext size
+func (e *EnvelopeS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	if !e.encrypted(key) {
+		return e.S3Client.Get(ctx, key)
+	}
+
+	return e.GetRange(ctx, key, 0, -1)
+}
+
+// GetRange returns part of the decrypted object. Offset and length refer to the plaintext; only the frames
+// covering the range are fetched and decrypted. The returned size is the plaintext size of the whole object.
+func (e *EnvelopeS3Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	return e.GetVersion(ctx, key, "", offset, length)
+}
+
+// GetVersion returns part of a specific version of the decrypted object, as for GetRange
+func (e *EnvelopeS3Client) GetVersion(ctx context.Context, key, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+	if !e.encrypted(key) {
+		return GetVersion(ctx, e.S3Client, key, versionID, offset, length)
+	}
+
+	h, objectSize, object, err := e.header(ctx, key, versionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The frames are read from the version of the object the header was read from, so that an object replaced
+	// between the reads cannot be decrypted with the wrong header
+	if versionID == "" {
+		versionID = object.VersionID
+	}
+
+	dataKey, err := e.Keys.DataKey(ctx, h.keyID, h.wrappedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	frames := h.frames(objectSize)
+	size := objectSize - h.length - frames*envelopeTagSize
+	if frames < 1 || size < 0 {
+		return nil, nil, fmt.Errorf("%w: object is truncated", ErrInvalidEnvelope)
+	}
+
+	if length < 0 || offset+length > size {
+		length = size - offset
+	}
+	if offset < 0 || (offset >= size && size > 0) || length < 0 {
+		return nil, &size, ErrRangeNotSatisfiable
+	}
+
+	first := offset / h.frameSize
+	last := frames - 1
+	if length > 0 {
+		last = min((offset+length-1)/h.frameSize, frames-1)
+	}
+
+	start := h.length + first*(h.frameSize+envelopeTagSize)
+	end := min(h.length+(last+1)*(h.frameSize+envelopeTagSize), objectSize)
+
+	body, _, err := GetVersion(ctx, e.S3Client, key, versionID, start, end-start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &decryptingReader{
+		src:        body,
+		object:     object,
+		aead:       aead,
+		header:     h,
+		objectSize: objectSize,
+		frame:      first,
+		lastFrame:  frames - 1,
+		skip:       offset - first*h.frameSize,
+		remaining:  length,
+	}, &size, nil
+}
+
+// Checker checks the health of the bucket using the wrapped S3Client
+func (e *EnvelopeS3Client) Checker(ctx context.Context, check *healthcheck.CheckState) error {
+	return e.S3Client.Checker(ctx, check)
+}
+
+func (e *EnvelopeS3Client) encrypted(key string) bool {
+	rule, ok := matchRule(e.Rules, key)
+	return ok && rule.Mode == EncryptionEnvelope
+}
+
+// header reads and parses the envelope header, returning it with the total size and version of the stored object
+func (e *EnvelopeS3Client) header(ctx context.Context, key, versionID string) (envelopeHeader, int64, ObjectInfo, error) {
+	body, size, err := GetVersion(ctx, e.S3Client, key, versionID, 0, maxEnvelopeHeaderSize)
+	if err != nil {
+		return envelopeHeader{}, 0, ObjectInfo{}, err
+	}
+	defer closeAndLogError(ctx, body)
+
+	object, _ := ObjectOf(body)
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return envelopeHeader{}, 0, object, fmt.Errorf("failed to read envelope header: %w", err)
+	}
+
+	if size == nil {
+		return envelopeHeader{}, 0, object, fmt.Errorf("%w: unknown object size", ErrInvalidEnvelope)
+	}
+
+	h, err := parseEnvelopeHeader(b)
+	return h, *size, object, err
+}
+
+// decryptingReader decrypts frames one at a time as they are read, so that memory use is bounded by the frame size
+type decryptingReader struct {
+	src        io.ReadCloser
+	object     ObjectInfo
+	aead       cipher.AEAD
+	header     envelopeHeader
+	objectSize int64
+	frame      int64
+	lastFrame  int64
+	skip       int64
+	remaining  int64
+	buf        []byte
+	plain      []byte
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	if d.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	for len(d.plain) == 0 {
+		if d.frame > d.lastFrame {
+			return 0, io.EOF
+		}
+		if err := d.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain[:min(int64(len(d.plain)), d.remaining)])
+	d.plain = d.plain[n:]
+	d.remaining -= int64(n)
+
+	return n, nil
+}
+
+func (d *decryptingReader) nextFrame() error {
+	frameLength := d.header.frameSize + envelopeTagSize
+	if d.frame == d.lastFrame {
+		frameLength = d.objectSize - d.header.length - d.frame*(d.header.frameSize+envelopeTagSize)
+	}
+
+	if d.buf == nil {
+		d.buf = make([]byte, d.header.frameSize+envelopeTagSize)
+	}
+
+	if _, err := io.ReadFull(d.src, d.buf[:frameLength]); err != nil {
+		return fmt.Errorf("failed to read encrypted frame %d: %w", d.frame, err)
+	}
+
+	plain, err := d.aead.Open(d.buf[:0], frameNonce(d.header.noncePrefix, d.frame, d.frame == d.lastFrame), d.buf[:frameLength], nil)
+	if err != nil {
+		return fmt.Errorf("%w %d: %w", ErrFrameAuthentication, d.frame, err)
+	}
+
+	d.plain = plain[d.skip:]
+	d.skip = 0
+	d.frame++
+
+	return nil
+}
+
+func (d *decryptingReader) Close() error {
+	return d.src.Close()
+}
+
+func (d *decryptingReader) Object() ObjectInfo {
+	return d.object
+}
+
+func frameNonce(prefix []byte, frame int64, last bool) []byte {
+	nonce := make([]byte, 0, envelopeNoncePrefix+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, uint32(frame))
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// matchRule returns the rule with the longest prefix matching the object key, given rules sorted by sortRules
+func matchRule(rules []EncryptionRule, key string) (EncryptionRule, bool) {
+	key = strings.TrimPrefix(key, "/")
+	for _, r := range rules {
+		if strings.HasPrefix(key, r.Prefix) {
+			return r, true
+		}
+	}
+	return EncryptionRule{}, false
+}
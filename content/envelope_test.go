@@ -0,0 +1,226 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testKeyID = "pre-release-2026"
+
+// memoryS3Client is an in-memory S3Client supporting ranged reads, recording the bytes fetched from each object
+type memoryS3Client struct {
+	objects map[string][]byte
+	fetched int64
+}
+
+func (m *memoryS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	return m.GetRange(ctx, key, 0, -1)
+}
+
+func (m *memoryS3Client) GetRange(_ context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, nil, errExample
+	}
+
+	size := int64(len(obj))
+	end := size
+	if length >= 0 {
+		end = min(offset+length, size)
+	}
+	m.fetched += end - offset
+
+	return io.NopCloser(bytes.NewReader(obj[offset:end])), &size, nil
+}
+
+func (m *memoryS3Client) Checker(context.Context, *healthcheck.CheckState) error {
+	return nil
+}
+
+// envelopeEncrypt encrypts plaintext in the format read by EnvelopeS3Client, as the upload pipeline would
+func envelopeEncrypt(t *testing.T, kek, plaintext []byte, frameSize int) []byte {
+	dataKey := randomBytes(t, 32)
+	noncePrefix := randomBytes(t, envelopeNoncePrefix)
+
+	wrap, err := newGCM(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapNonce := randomBytes(t, wrap.NonceSize())
+	wrappedKey := wrap.Seal(wrapNonce, wrapNonce, dataKey, []byte(testKeyID))
+
+	var out bytes.Buffer
+	out.WriteString(envelopeMagic)
+	binary.Write(&out, binary.BigEndian, uint32(frameSize))       // nolint
+	out.WriteByte(byte(len(testKeyID)))                           // nolint
+	out.WriteString(testKeyID)                                    // nolint
+	binary.Write(&out, binary.BigEndian, uint16(len(wrappedKey))) // nolint
+	out.Write(wrappedKey)                                         // nolint
+	out.Write(noncePrefix)                                        // nolint
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := max((len(plaintext)+frameSize-1)/frameSize, 1)
+	for i := 0; i < frames; i++ {
+		frame := plaintext[i*frameSize : min((i+1)*frameSize, len(plaintext))]
+		out.Write(aead.Seal(nil, frameNonce(noncePrefix, int64(i), i == frames-1), frame, nil)) // nolint
+	}
+
+	return out.Bytes()
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func writeKeysFile(t *testing.T, keys map[string][]byte) string {
+	encoded := map[string]string{}
+	for id, k := range keys {
+		encoded[id] = base64.StdEncoding.EncodeToString(k)
+	}
+	b, _ := json.Marshal(encoded)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvelopeS3Client(t *testing.T) {
+	ctx := context.Background()
+	kek := randomBytes(t, 32)
+	plaintext := bytes.Repeat([]byte("0123456789"), 100)
+
+	keys, err := NewFileKeyProvider(writeKeysFile(t, map[string][]byte{testKeyID: kek}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, _ := ParseEncryptionRules("none", "pre-release/=envelope")
+
+	newClient := func() (*EnvelopeS3Client, *memoryS3Client) {
+		storage := &memoryS3Client{objects: map[string][]byte{
+			"pre-release/data.csv":  envelopeEncrypt(t, kek, plaintext, 64),
+			"pre-release/empty.csv": envelopeEncrypt(t, kek, nil, 64),
+			"public/data.csv":       []byte("plain"),
+		}}
+		return NewEnvelopeS3Client(storage, keys, rules), storage
+	}
+
+	Convey("should decrypt the whole object and return its plaintext size", t, func() {
+		e, _ := newClient()
+
+		body, size, err := e.Get(ctx, "pre-release/data.csv")
+
+		So(err, ShouldBeNil)
+		b, err := io.ReadAll(body)
+		So(err, ShouldBeNil)
+		So(b, ShouldResemble, plaintext)
+		So(*size, ShouldEqual, len(plaintext))
+	})
+
+	Convey("should decrypt empty objects", t, func() {
+		e, _ := newClient()
+
+		body, size, err := e.Get(ctx, "pre-release/empty.csv")
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(b, ShouldBeEmpty)
+		So(*size, ShouldEqual, 0)
+	})
+
+	Convey("should decrypt ranges spanning frames, fetching only the frames covering the range", t, func() {
+		e, storage := newClient()
+
+		body, _, err := e.GetRange(ctx, "pre-release/data.csv", 100, 50)
+
+		So(err, ShouldBeNil)
+		b, err := io.ReadAll(body)
+		So(err, ShouldBeNil)
+		So(b, ShouldResemble, plaintext[100:150])
+		So(storage.fetched, ShouldBeLessThan, maxEnvelopeHeaderSize+2*(64+envelopeTagSize)+1)
+	})
+
+	Convey("should decrypt ranges to the end of the object", t, func() {
+		e, _ := newClient()
+
+		body, _, err := e.GetRange(ctx, "pre-release/data.csv", 990, -1)
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(b, ShouldResemble, plaintext[990:])
+	})
+
+	Convey("should reject ranges starting beyond the end of the object", t, func() {
+		e, _ := newClient()
+
+		_, _, err := e.GetRange(ctx, "pre-release/data.csv", 1000, 10)
+
+		So(err, ShouldEqual, ErrRangeNotSatisfiable)
+	})
+
+	Convey("should fail to read tampered frames", t, func() {
+		e, storage := newClient()
+		obj := storage.objects["pre-release/data.csv"]
+		obj[len(obj)-1] ^= 0xff
+
+		body, _, err := e.GetRange(ctx, "pre-release/data.csv", 990, -1)
+		So(err, ShouldBeNil)
+
+		_, err = io.ReadAll(body)
+		So(errors.Is(err, ErrFrameAuthentication), ShouldBeTrue)
+	})
+
+	Convey("should detect truncated objects", t, func() {
+		e, storage := newClient()
+		obj := storage.objects["pre-release/data.csv"]
+		storage.objects["pre-release/data.csv"] = obj[:len(obj)-(64+envelopeTagSize)]
+
+		body, _, err := e.Get(ctx, "pre-release/data.csv")
+		So(err, ShouldBeNil)
+
+		_, err = io.ReadAll(body)
+		So(errors.Is(err, ErrFrameAuthentication), ShouldBeTrue)
+	})
+
+	Convey("should fail when the key ID is unknown", t, func() {
+		other, _ := NewFileKeyProvider(writeKeysFile(t, map[string][]byte{"other": kek}))
+		_, storage := newClient()
+		e := NewEnvelopeS3Client(storage, other, rules)
+
+		_, _, err := e.Get(ctx, "pre-release/data.csv")
+
+		So(errors.Is(err, ErrUnknownKeyID), ShouldBeTrue)
+	})
+
+	Convey("should read objects not matching an envelope rule unchanged", t, func() {
+		e, _ := newClient()
+
+		body, _, err := e.Get(ctx, "public/data.csv")
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "plain")
+	})
+}
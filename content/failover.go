@@ -0,0 +1,188 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// FailoverPolicy determines which primary bucket errors cause a read to fall back to the secondary bucket
+type FailoverPolicy string
+
+// Possible values for a FailoverPolicy
+const (
+	FailoverNever     FailoverPolicy = "never"     // never read from the secondary bucket
+	FailoverRetryable FailoverPolicy = "retryable" // fail over on retryable S3 errors and timeouts
+	FailoverAny       FailoverPolicy = "any"       // fail over on any error other than a missing object
+)
+
+// ErrPrimaryTimeout is returned when the primary bucket does not respond within the configured timeout
+var ErrPrimaryTimeout = errors.New("timed out waiting for primary bucket")
+
+var retryables = retry.IsErrorRetryables(retry.DefaultRetryables)
+
+// FailoverS3Client is an S3Client that reads from a primary bucket and falls back to a secondary bucket
+// (for example a cross-region replication target) according to its FailoverPolicy.
+type FailoverS3Client struct {
+	Primary   S3Client
+	Secondary S3Client
+	Policy    FailoverPolicy
+	Timeout   time.Duration
+}
+
+// NewFailoverS3Client creates a new FailoverS3Client. A Timeout of 0 means the primary bucket is given as long as the
+// request context allows.
+func NewFailoverS3Client(primary, secondary S3Client, policy FailoverPolicy, timeout time.Duration) *FailoverS3Client {
+	return &FailoverS3Client{
+		Primary:   primary,
+		Secondary: secondary,
+		Policy:    policy,
+		Timeout:   timeout,
+	}
+}
+
+// Get returns the object from the primary bucket, or from the secondary bucket if the primary read fails in a way
+// that the policy allows to fail over.
+func (f *FailoverS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	return f.read(ctx, key, func(ctx context.Context, s3c S3Client) (io.ReadCloser, *int64, error) {
+		return s3c.Get(ctx, key)
+	})
+}
+
+// GetRange returns part of the object from the primary bucket, or from the secondary bucket if the primary read
+// fails in a way that the policy allows to fail over.
+func (f *FailoverS3Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	return f.read(ctx, key, func(ctx context.Context, s3c S3Client) (io.ReadCloser, *int64, error) {
+		return GetRange(ctx, s3c, key, offset, length)
+	})
+}
+
+// GetVersion returns part of a specific version of the object from the primary bucket, or from the secondary bucket
+// if the primary read fails in a way that the policy allows to fail over. Replication preserves version IDs, so the
+// same version is read from either bucket.
+func (f *FailoverS3Client) GetVersion(ctx context.Context, key, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+	return f.read(ctx, key, func(ctx context.Context, s3c S3Client) (io.ReadCloser, *int64, error) {
+		return GetVersion(ctx, s3c, key, versionID, offset, length)
+	})
+}
+
+func (f *FailoverS3Client) read(ctx context.Context, key string, get func(context.Context, S3Client) (io.ReadCloser, *int64, error)) (io.ReadCloser, *int64, error) {
+	body, size, err := f.readPrimary(ctx, key, get)
+	if err == nil {
+		return body, size, nil
+	}
+
+	if !f.shouldFailover(ctx, err) {
+		return nil, nil, err
+	}
+
+	reason := "error"
+	if errors.Is(err, ErrPrimaryTimeout) {
+		reason = "timeout"
+	}
+	metrics.Add("failover_"+reason, 1)
+
+	logData := log.Data{"s3_key": key, "reason": reason, "primary_error": err.Error()}
+	log.Warn(ctx, "primary bucket read failed, failing over to secondary bucket", logData)
+
+	body, size, secondaryErr := get(ctx, f.Secondary)
+	if secondaryErr != nil {
+		metrics.Add("failover_failed", 1)
+		return nil, nil, fmt.Errorf("failed to get object from secondary bucket after primary failed (%s): %w", err.Error(), secondaryErr)
+	}
+
+	return body, size, nil
+}
+
+// Checker reports OK when the primary bucket is healthy, WARNING when only the secondary bucket is healthy,
+// and CRITICAL when neither is.
+func (f *FailoverS3Client) Checker(ctx context.Context, check *healthcheck.CheckState) error {
+	primary := healthcheck.NewCheckState(check.Name())
+	if err := f.Primary.Checker(ctx, primary); err != nil {
+		return err
+	}
+
+	if primary.Status() == healthcheck.StatusOK || f.Secondary == nil {
+		return check.Update(primary.Status(), primary.Message(), primary.StatusCode())
+	}
+
+	secondary := healthcheck.NewCheckState(check.Name())
+	if err := f.Secondary.Checker(ctx, secondary); err != nil {
+		return err
+	}
+
+	if secondary.Status() == healthcheck.StatusOK && f.Policy != FailoverNever {
+		return check.Update(healthcheck.StatusWarning, fmt.Sprintf("primary bucket unavailable, reading from secondary bucket: %s", primary.Message()), 0)
+	}
+
+	return check.Update(healthcheck.StatusCritical, fmt.Sprintf("primary bucket: %s; secondary bucket: %s", primary.Message(), secondary.Message()), 0)
+}
+
+// readPrimary reads from the primary bucket, giving up with ErrPrimaryTimeout if the bucket has not responded
+// within the timeout. The timeout only applies to the initial response, not to reading the body.
+func (f *FailoverS3Client) readPrimary(ctx context.Context, key string, get func(context.Context, S3Client) (io.ReadCloser, *int64, error)) (io.ReadCloser, *int64, error) {
+	if f.Timeout <= 0 {
+		return get(ctx, f.Primary)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(f.Timeout, func() { cancel(ErrPrimaryTimeout) })
+
+	body, size, err := get(ctx, f.Primary)
+	if !timer.Stop() {
+		if err == nil {
+			closeAndLogError(ctx, body)
+		}
+		return nil, nil, fmt.Errorf("failed to get %s from primary bucket: %w", key, ErrPrimaryTimeout)
+	}
+	if err != nil {
+		cancel(nil)
+		return nil, nil, err
+	}
+
+	return &cancelOnClose{ReadCloser: body, cancel: func() { cancel(nil) }}, size, nil
+}
+
+func (f *FailoverS3Client) shouldFailover(ctx context.Context, err error) bool {
+	if f.Secondary == nil || ctx.Err() != nil {
+		return false
+	}
+
+	switch f.Policy {
+	case FailoverAny:
+		// missing objects are not failed over, as variant probes expect them and the replica would not have them either
+		return !IsNotFound(err)
+	case FailoverRetryable:
+		return errors.Is(err, ErrPrimaryTimeout) || IsRetryable(err)
+	default:
+		return false
+	}
+}
+
+// IsRetryable reports whether err is a transient S3 error, such as throttling, a 5xx response or a dropped connection.
+func IsRetryable(err error) bool {
+	return retryables.IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// cancelOnClose releases the context used to get an object once its body has been read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func (c *cancelOnClose) Object() ObjectInfo {
+	info, _ := ObjectOf(c.ReadCloser)
+	return info
+}
@@ -0,0 +1,166 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-download-service/content/mocks"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFailoverS3Client_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	Convey("should return the primary object without touching the secondary bucket when the primary succeeds", t, func() {
+		primary := s3ClientGetReturnsReader(ctrl, testS3Path, io.NopCloser(strings.NewReader("primary")))
+		secondary := mocks.NewMockS3Client(ctrl)
+
+		f := NewFailoverS3Client(primary, secondary, FailoverRetryable, time.Second)
+		body, _, err := f.Get(ctx, testS3Path)
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "primary")
+		So(body.Close(), ShouldBeNil)
+	})
+
+	Convey("should read from the secondary bucket when the primary returns a retryable error", t, func() {
+		primary := mocks.NewMockS3Client(ctrl)
+		primary.EXPECT().Get(gomock.Any(), testS3Path).Return(nil, nil, serverError())
+		secondary := s3ClientGetReturnsReader(ctrl, testS3Path, io.NopCloser(strings.NewReader("secondary")))
+
+		f := NewFailoverS3Client(primary, secondary, FailoverRetryable, 0)
+		body, _, err := f.Get(ctx, testS3Path)
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "secondary")
+	})
+
+	Convey("should not fail over on a non-retryable error with the retryable policy", t, func() {
+		primary := s3ClientGetReturnsErrorOnce(ctrl, testS3Path)
+		secondary := mocks.NewMockS3Client(ctrl)
+
+		f := NewFailoverS3Client(primary, secondary, FailoverRetryable, 0)
+		_, _, err := f.Get(ctx, testS3Path)
+
+		So(errors.Is(err, errExample), ShouldBeTrue)
+	})
+
+	Convey("should fail over on any error with the any policy", t, func() {
+		primary := s3ClientGetReturnsErrorOnce(ctrl, testS3Path)
+		secondary := s3ClientGetReturnsReader(ctrl, testS3Path, io.NopCloser(strings.NewReader("secondary")))
+
+		f := NewFailoverS3Client(primary, secondary, FailoverAny, 0)
+		_, _, err := f.Get(ctx, testS3Path)
+
+		So(err, ShouldBeNil)
+	})
+
+	Convey("should not fail over on a missing object with the any policy", t, func() {
+		primary := mocks.NewMockS3Client(ctrl)
+		primary.EXPECT().Get(gomock.Any(), testS3Path).Return(nil, nil, &smithy.GenericAPIError{Code: "NoSuchKey"})
+		secondary := mocks.NewMockS3Client(ctrl)
+
+		f := NewFailoverS3Client(primary, secondary, FailoverAny, 0)
+		_, _, err := f.Get(ctx, testS3Path)
+
+		So(IsNotFound(err), ShouldBeTrue)
+	})
+
+	Convey("should never fail over with the never policy", t, func() {
+		primary := mocks.NewMockS3Client(ctrl)
+		primary.EXPECT().Get(gomock.Any(), testS3Path).Return(nil, nil, serverError())
+		secondary := mocks.NewMockS3Client(ctrl)
+
+		f := NewFailoverS3Client(primary, secondary, FailoverNever, 0)
+		_, _, err := f.Get(ctx, testS3Path)
+
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should fail over when the primary does not respond within the timeout", t, func() {
+		primary := mocks.NewMockS3Client(ctrl)
+		primary.EXPECT().Get(gomock.Any(), testS3Path).DoAndReturn(func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+			<-ctx.Done()
+			return nil, nil, ctx.Err()
+		})
+		secondary := s3ClientGetReturnsReader(ctrl, testS3Path, io.NopCloser(strings.NewReader("secondary")))
+
+		f := NewFailoverS3Client(primary, secondary, FailoverRetryable, 10*time.Millisecond)
+		_, _, err := f.Get(ctx, testS3Path)
+
+		So(err, ShouldBeNil)
+	})
+
+	Convey("should return both errors when the secondary bucket also fails", t, func() {
+		primary := mocks.NewMockS3Client(ctrl)
+		primary.EXPECT().Get(gomock.Any(), testS3Path).Return(nil, nil, serverError())
+		secondary := s3ClientGetReturnsErrorOnce(ctrl, testS3Path)
+
+		f := NewFailoverS3Client(primary, secondary, FailoverRetryable, 0)
+		_, _, err := f.Get(ctx, testS3Path)
+
+		So(errors.Is(err, errExample), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "primary failed")
+	})
+}
+
+func TestFailoverS3Client_Checker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	Convey("should report the primary state when the primary bucket is healthy", t, func() {
+		f := NewFailoverS3Client(checkerReporting(ctrl, healthcheck.StatusOK), mocks.NewMockS3Client(ctrl), FailoverRetryable, 0)
+		check := healthcheck.NewCheckState("S3")
+
+		So(f.Checker(ctx, check), ShouldBeNil)
+		So(check.Status(), ShouldEqual, healthcheck.StatusOK)
+	})
+
+	Convey("should report warning when only the primary bucket is down", t, func() {
+		f := NewFailoverS3Client(checkerReporting(ctrl, healthcheck.StatusCritical), checkerReporting(ctrl, healthcheck.StatusOK), FailoverRetryable, 0)
+		check := healthcheck.NewCheckState("S3")
+
+		So(f.Checker(ctx, check), ShouldBeNil)
+		So(check.Status(), ShouldEqual, healthcheck.StatusWarning)
+	})
+
+	Convey("should report critical when both buckets are down", t, func() {
+		f := NewFailoverS3Client(checkerReporting(ctrl, healthcheck.StatusCritical), checkerReporting(ctrl, healthcheck.StatusCritical), FailoverRetryable, 0)
+		check := healthcheck.NewCheckState("S3")
+
+		So(f.Checker(ctx, check), ShouldBeNil)
+		So(check.Status(), ShouldEqual, healthcheck.StatusCritical)
+	})
+}
+
+func serverError() error {
+	return &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}, Err: errExample}
+}
+
+func s3ClientGetReturnsErrorOnce(ctrl *gomock.Controller, key string) *mocks.MockS3Client {
+	cli := mocks.NewMockS3Client(ctrl)
+	cli.EXPECT().Get(gomock.Any(), key).Times(1).Return(nil, nil, errExample)
+	return cli
+}
+
+func checkerReporting(ctrl *gomock.Controller, status string) *mocks.MockS3Client {
+	cli := mocks.NewMockS3Client(ctrl)
+	cli.EXPECT().Checker(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *healthcheck.CheckState) error {
+		return check.Update(status, "bucket is "+status, 0)
+	})
+	return cli
+}
@@ -0,0 +1,87 @@
+package content
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrUnknownKeyID      = errors.New("unknown key id")
+	ErrInvalidWrappedKey = errors.New("invalid wrapped data key")
+)
+
+// KeyProvider unwraps the data keys of client-side encrypted objects
+type KeyProvider interface {
+	DataKey(ctx context.Context, keyID string, wrappedKey []byte) ([]byte, error)
+}
+
+// FileKeyProvider is a KeyProvider backed by a local JSON file mapping key IDs to base64 encoded 256-bit key
+// encryption keys. Data keys are wrapped with AES-GCM using the key encryption key, with the nonce prepended to
+// the ciphertext. It is intended for local development and tests rather than production use.
+type FileKeyProvider struct {
+	keys map[string][]byte
+}
+
+// NewFileKeyProvider creates a new FileKeyProvider from the keys in the file at path
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var encoded map[string]string
+	if err = json.Unmarshal(b, &encoded); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(encoded))
+	for id, k := range encoded {
+		key, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %s: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	return &FileKeyProvider{keys: keys}, nil
+}
+
+// DataKey unwraps the data key using the key encryption key with the given ID
+func (p *FileKeyProvider) DataKey(ctx context.Context, keyID string, wrappedKey []byte) ([]byte, error) {
+	kek, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, keyID)
+	}
+
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, ErrInvalidWrappedKey
+	}
+
+	nonce, ciphertext := wrappedKey[:aead.NonceSize()], wrappedKey[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidWrappedKey, err)
+	}
+
+	return dataKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,7 @@
+package content
+
+import "expvar"
+
+// metrics counts storage events, such as bucket failovers, that would otherwise only be visible in the logs.
+// The counters are published under "s3" by the expvar handler.
+var metrics = expvar.NewMap("s3")
@@ -0,0 +1,48 @@
+package content
+
+import (
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ObjectInfo identifies the version of an object that a body was read from
+type ObjectInfo struct {
+	VersionID string
+	ETag      string
+}
+
+// objectReader is implemented by bodies that know the version of the object they were read from
+type objectReader interface {
+	Object() ObjectInfo
+}
+
+// ObjectOf returns the version of the object that body was read from. It returns false if the body was not read by
+// an S3 client that reports it, or the bucket returned neither a version ID nor an entity tag.
+func ObjectOf(body any) (ObjectInfo, bool) {
+	o, ok := body.(objectReader)
+	if !ok {
+		return ObjectInfo{}, false
+	}
+
+	info := o.Object()
+	return info, info != ObjectInfo{}
+}
+
+// objectBody is the body of a GetObject response with the version of the object it was read from
+type objectBody struct {
+	io.ReadCloser
+	info ObjectInfo
+}
+
+func newObjectBody(out *s3.GetObjectOutput) io.ReadCloser {
+	return objectBody{
+		ReadCloser: out.Body,
+		info:       ObjectInfo{VersionID: aws.ToString(out.VersionId), ETag: aws.ToString(out.ETag)},
+	}
+}
+
+func (b objectBody) Object() ObjectInfo {
+	return b.info
+}
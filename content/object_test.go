@@ -0,0 +1,64 @@
+package content
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-download-service/content/mocks"
+	"github.com/ONSdigital/dp-download-service/retry"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestObjectOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amz-version-id", "v2")
+		w.Header().Set("ETag", `"etag-v2"`)
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-2/3")
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write([]byte("new")) // nolint
+	}))
+	defer server.Close()
+
+	ranged := NewRangedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket)
+	policy := retry.NewPolicy(3, time.Millisecond, time.Millisecond)
+	clients := map[string]S3Client{
+		"ranged":   ranged,
+		"retrying": NewRetryingS3Client(ranged, policy),
+		"failover": NewFailoverS3Client(NewRetryingS3Client(ranged, policy), ranged, FailoverRetryable, 0),
+	}
+
+	for name, s3c := range clients {
+		Convey("should report the version of the object read by the "+name+" client", t, func() {
+			body, _, err := s3c.Get(ctx, testS3Path)
+			So(err, ShouldBeNil)
+
+			info, ok := ObjectOf(body)
+			So(ok, ShouldBeTrue)
+			So(info, ShouldResemble, ObjectInfo{VersionID: "v2", ETag: `"etag-v2"`})
+
+			body, _, err = GetRange(ctx, s3c, testS3Path, 0, 3)
+			So(err, ShouldBeNil)
+
+			info, ok = ObjectOf(body)
+			So(ok, ShouldBeTrue)
+			So(info.VersionID, ShouldEqual, "v2")
+		})
+	}
+
+	Convey("should not report a version for bodies from other readers", t, func() {
+		_, ok := ObjectOf(io.NopCloser(nil))
+
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,146 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrInvalidContentRange is returned when the total object size cannot be read from a ranged response
+var ErrInvalidContentRange = errors.New("invalid content range in s3 response")
+
+// RangeGetter is implemented by S3 clients that can read part of an object
+type RangeGetter interface {
+	// GetRange returns length bytes of the object starting at offset, or the rest of the object if length is
+	// negative. The returned size is the total size of the object, not the length of the range.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error)
+}
+
+// GetRange reads part of an object, using ranged reads if s3c supports them and otherwise discarding the start of
+// the full object.
+func GetRange(ctx context.Context, s3c S3Client, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	if rg, ok := s3c.(RangeGetter); ok {
+		return rg.GetRange(ctx, key, offset, length)
+	}
+
+	body, size, err := s3c.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err = io.CopyN(io.Discard, body, offset); err != nil {
+		closeAndLogError(ctx, body)
+		return nil, nil, fmt.Errorf("failed to skip to offset %d: %w", offset, err)
+	}
+
+	if length < 0 {
+		return body, size, nil
+	}
+
+	return readCloser{Reader: io.LimitReader(body, length), Closer: body}, size, nil
+}
+
+// RangedS3Client adds ranged reads to an S3Client using the AWS SDK directly
+type RangedS3Client struct {
+	S3Client S3Client
+	Objects  ObjectGetter
+	Bucket   string
+}
+
+// NewRangedS3Client creates a new RangedS3Client
+func NewRangedS3Client(s3c S3Client, objects ObjectGetter, bucket string) *RangedS3Client {
+	return &RangedS3Client{
+		S3Client: s3c,
+		Objects:  objects,
+		Bucket:   bucket,
+	}
+}
+
+// Get returns the whole object using the AWS SDK, so that the version of the object read is known
+func (r *RangedS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	out, err := r.Objects.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting object from s3: %w", err)
+	}
+
+	return newObjectBody(out), out.ContentLength, nil
+}
+
+// Checker checks the health of the bucket using the wrapped S3Client
+func (r *RangedS3Client) Checker(ctx context.Context, check *healthcheck.CheckState) error {
+	return r.S3Client.Checker(ctx, check)
+}
+
+// GetRange returns part of an object using an HTTP range request
+func (r *RangedS3Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(r.Bucket),
+		Key:    aws.String(key),
+	}
+
+	return getObjectRange(ctx, r.Objects, in, offset, length)
+}
+
+func getObjectRange(ctx context.Context, objects ObjectGetter, in *s3.GetObjectInput, offset, length int64) (io.ReadCloser, *int64, error) {
+	in.Range = aws.String(rangeHeader(offset, length))
+
+	out, err := objects.GetObject(ctx, in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting object range from s3: %w", err)
+	}
+
+	size, err := totalSize(out.ContentRange)
+	if err != nil {
+		closeAndLogError(ctx, out.Body)
+		return nil, nil, err
+	}
+
+	return newObjectBody(out), &size, nil
+}
+
+func rangeHeader(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// totalSize parses the total object size from a Content-Range header such as "bytes 0-99/1234"
+func totalSize(contentRange *string) (int64, error) {
+	if contentRange == nil {
+		return 0, ErrInvalidContentRange
+	}
+
+	_, total, ok := strings.Cut(aws.ToString(contentRange), "/")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, aws.ToString(contentRange))
+	}
+
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, aws.ToString(contentRange))
+	}
+
+	return size, nil
+}
+
+// readCloser combines a reader with the closer of the stream it reads from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (r readCloser) Object() ObjectInfo {
+	info, _ := ObjectOf(r.Closer)
+	return info
+}
@@ -0,0 +1,197 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ONSdigital/dp-download-service/retry"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// ErrObjectChanged is returned when the object being read is replaced before a broken stream can be resumed
+var ErrObjectChanged = errors.New("object changed while it was being read")
+
+// RetryingS3Client is an S3Client that retries reads failing with a transient error according to its retry.Policy.
+// If a stream breaks part way through, reading continues from the failed byte offset with a ranged read, so callers
+// that have already written part of the object see an uninterrupted stream. Resumed reads are pinned to the version
+// of the object first read, and fail with ErrObjectChanged rather than splice together two versions of the object.
+type RetryingS3Client struct {
+	S3Client S3Client
+	Policy   retry.Policy
+}
+
+// NewRetryingS3Client creates a new RetryingS3Client
+func NewRetryingS3Client(s3c S3Client, policy retry.Policy) *RetryingS3Client {
+	return &RetryingS3Client{
+		S3Client: s3c,
+		Policy:   policy,
+	}
+}
+
+// Get returns the object, retrying transient errors
+func (r *RetryingS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	return r.read(ctx, key, 0, -1, func() (io.ReadCloser, *int64, error) {
+		return r.S3Client.Get(ctx, key)
+	}, func(versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+		return GetVersion(ctx, r.S3Client, key, versionID, offset, length)
+	})
+}
+
+// GetRange returns part of the object, retrying transient errors
+func (r *RetryingS3Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	get := func(versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+		return GetVersion(ctx, r.S3Client, key, versionID, offset, length)
+	}
+	return r.read(ctx, key, offset, length, func() (io.ReadCloser, *int64, error) { return get("", offset, length) }, get)
+}
+
+// GetVersion returns part of a specific version of the object, retrying transient errors
+func (r *RetryingS3Client) GetVersion(ctx context.Context, key, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+	get := func(_ string, offset, length int64) (io.ReadCloser, *int64, error) {
+		return GetVersion(ctx, r.S3Client, key, versionID, offset, length)
+	}
+	return r.read(ctx, key, offset, length, func() (io.ReadCloser, *int64, error) { return get(versionID, offset, length) }, get)
+}
+
+// Checker checks the health of the bucket using the wrapped S3Client
+func (r *RetryingS3Client) Checker(ctx context.Context, check *healthcheck.CheckState) error {
+	return r.S3Client.Checker(ctx, check)
+}
+
+func (r *RetryingS3Client) read(ctx context.Context, key string, offset, length int64, get func() (io.ReadCloser, *int64, error), getRange func(versionID string, offset, length int64) (io.ReadCloser, *int64, error)) (io.ReadCloser, *int64, error) {
+	var body io.ReadCloser
+	var size *int64
+
+	attempt := 0
+	err := r.Policy.Do(ctx, IsRetryable, func() error {
+		attempt++
+		if attempt > 1 {
+			metrics.Add("retry_get", 1)
+			log.Warn(ctx, "retrying s3 read", log.Data{"key": key, "attempt": attempt})
+		}
+
+		var err error
+		body, size, err = get()
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	object, _ := ObjectOf(body)
+
+	return &resumingReader{
+		ctx:      ctx,
+		key:      key,
+		policy:   r.Policy,
+		body:     body,
+		object:   object,
+		getRange: getRange,
+		offset:   offset,
+		length:   length,
+	}, size, nil
+}
+
+// resumingReader reads an object, re-fetching the rest of it from the current offset if the stream breaks. The rest
+// of the object is read from the version first read, and its entity tag checked, so that a stream is never continued
+// from a different object.
+type resumingReader struct {
+	ctx      context.Context
+	key      string
+	policy   retry.Policy
+	body     io.ReadCloser
+	object   ObjectInfo // the version of the object first read, if the S3 client reports it
+	getRange func(versionID string, offset, length int64) (io.ReadCloser, *int64, error)
+	offset   int64
+	length   int64 // remaining bytes to read, or negative to read to the end of the object
+	resumes  int
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	if r.length == 0 {
+		return 0, io.EOF
+	}
+	if r.length > 0 && int64(len(p)) > r.length {
+		p = p[:r.length]
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if r.length > 0 {
+		r.length -= int64(n)
+	}
+
+	if err == nil || err == io.EOF || !isResumable(err) || r.resumes+1 >= r.policy.MaxAttempts {
+		return n, err
+	}
+
+	if resumeErr := r.resume(); resumeErr != nil {
+		if errors.Is(resumeErr, ErrObjectChanged) {
+			return n, resumeErr
+		}
+		return n, err
+	}
+
+	return n, nil
+}
+
+func (r *resumingReader) resume() error {
+	r.resumes++
+	closeAndLogError(r.ctx, r.body)
+
+	if err := r.policy.Wait(r.ctx, r.resumes); err != nil {
+		return err
+	}
+
+	metrics.Add("retry_resume", 1)
+	log.Warn(r.ctx, "resuming s3 read after stream error", log.Data{"key": r.key, "offset": r.offset, "resumes": r.resumes})
+
+	body, _, err := r.getRange(r.object.VersionID, r.offset, r.length)
+	if err == nil {
+		err = r.checkObject(body)
+	}
+	if err != nil {
+		log.Error(r.ctx, "failed to resume s3 read", err, log.Data{"key": r.key, "offset": r.offset, "version_id": r.object.VersionID})
+		r.body = io.NopCloser(errReader{err})
+		return err
+	}
+
+	r.body = body
+	return nil
+}
+
+// checkObject closes the resumed body and returns ErrObjectChanged if it was read from a different object than the
+// one first read
+func (r *resumingReader) checkObject(body io.ReadCloser) error {
+	resumed, _ := ObjectOf(body)
+	if (r.object.VersionID == "" || resumed.VersionID == r.object.VersionID) && (r.object.ETag == "" || resumed.ETag == r.object.ETag) {
+		return nil
+	}
+
+	closeAndLogError(r.ctx, body)
+	return fmt.Errorf("%w: %s was version %q with etag %s and is now version %q with etag %s", ErrObjectChanged, r.key, r.object.VersionID, r.object.ETag, resumed.VersionID, resumed.ETag)
+}
+
+func (r *resumingReader) Close() error {
+	return r.body.Close()
+}
+
+func (r *resumingReader) Object() ObjectInfo {
+	return r.object
+}
+
+// isResumable reports whether a stream that failed with err can be continued with a ranged read
+func isResumable(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) || IsRetryable(err)
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
@@ -0,0 +1,213 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-download-service/content/mocks"
+	"github.com/ONSdigital/dp-download-service/retry"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// breakingReader returns an unexpected EOF after n bytes, as when a connection drops part way through a body
+type breakingReader struct {
+	r io.Reader
+	n int
+}
+
+func (b *breakingReader) Read(p []byte) (int, error) {
+	if b.n == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.r.Read(p)
+	b.n -= n
+	return n, err
+}
+
+func TestRetryingS3Client(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	policy := retry.NewPolicy(3, time.Millisecond, time.Millisecond)
+
+	Convey("should retry transient errors getting an object", t, func() {
+		s3Cli := mocks.NewMockS3Client(ctrl)
+		s3Cli.EXPECT().Get(gomock.Any(), testS3Path).Return(nil, nil, serverError())
+		s3Cli.EXPECT().Get(gomock.Any(), testS3Path).Return(io.NopCloser(strings.NewReader("content")), nil, nil)
+		r := NewRetryingS3Client(s3Cli, policy)
+
+		body, _, err := r.Get(ctx, testS3Path)
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "content")
+	})
+
+	Convey("should not retry errors that are not transient", t, func() {
+		s3Cli, expectedErr := s3ClientGetReturnsError(ctrl, testS3Path)
+		r := NewRetryingS3Client(s3Cli, policy)
+
+		_, _, err := r.Get(ctx, testS3Path)
+
+		So(errors.Is(err, expectedErr), ShouldBeTrue)
+	})
+
+	Convey("should resume a broken stream from the failed byte offset", t, func() {
+		content := []byte("0123456789")
+		storage := &memoryS3Client{objects: map[string][]byte{testS3Path: content}}
+		breaking := &breakingS3Client{memoryS3Client: storage, breakAfter: 4}
+		r := NewRetryingS3Client(breaking, policy)
+
+		body, _, err := r.Get(ctx, testS3Path)
+		So(err, ShouldBeNil)
+
+		b, err := io.ReadAll(body)
+		So(err, ShouldBeNil)
+		So(b, ShouldResemble, content)
+		So(breaking.offsets, ShouldResemble, []int64{0, 4, 8})
+	})
+
+	Convey("should resume a broken range within the requested range", t, func() {
+		content := []byte("0123456789")
+		storage := &memoryS3Client{objects: map[string][]byte{testS3Path: content}}
+		breaking := &breakingS3Client{memoryS3Client: storage, breakAfter: 2}
+		r := NewRetryingS3Client(breaking, policy)
+
+		body, _, err := r.GetRange(ctx, testS3Path, 1, 5)
+		So(err, ShouldBeNil)
+
+		b, err := io.ReadAll(body)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "12345")
+	})
+
+	Convey("should return the stream error once the attempts are used up", t, func() {
+		storage := &memoryS3Client{objects: map[string][]byte{testS3Path: bytes.Repeat([]byte("x"), 100)}}
+		breaking := &breakingS3Client{memoryS3Client: storage, breakAfter: 1}
+		r := NewRetryingS3Client(breaking, policy)
+
+		body, _, err := r.Get(ctx, testS3Path)
+		So(err, ShouldBeNil)
+
+		_, err = io.ReadAll(body)
+		So(errors.Is(err, io.ErrUnexpectedEOF), ShouldBeTrue)
+	})
+}
+
+// changingStorage is a stand-in for an S3 bucket whose object is replaced after it is first read. The first response
+// breaks part way through its body, as when a connection drops. Objects are only given version IDs if versioned is
+// set.
+type changingStorage struct {
+	versioned bool
+	versions  map[string]string // content by version ID, where v1 is the original object and v2 its replacement
+	current   string
+	requests  []string
+}
+
+func (st *changingStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	versionID := r.URL.Query().Get("versionId")
+	st.requests = append(st.requests, versionID)
+	if versionID == "" {
+		versionID = st.current
+	}
+	obj := st.versions[versionID]
+
+	if st.versioned {
+		w.Header().Set("x-amz-version-id", versionID)
+	}
+	w.Header().Set("ETag", strconv.Quote("etag-"+versionID))
+
+	offset := int64(0)
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		fmt.Sscanf(rangeHeader, "bytes=%d-", &offset) // nolint
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(obj)-1, len(obj)))
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj)-int(offset)))
+
+	if len(st.requests) == 1 {
+		// Replace the object and break the stream after part of the body has been written
+		st.current = "v2"
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(obj[:4])) // nolint
+		return
+	}
+
+	if r.Header.Get("Range") != "" {
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	w.Write([]byte(obj[offset:])) // nolint
+}
+
+func TestRetryingS3ClientObjectChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	policy := retry.NewPolicy(3, time.Millisecond, time.Millisecond)
+
+	newStorage := func(versioned bool) (*changingStorage, *httptest.Server, *RetryingS3Client) {
+		storage := &changingStorage{versioned: versioned, versions: map[string]string{"v1": "0123456789", "v2": "abcdefghij"}, current: "v1"}
+		server := httptest.NewServer(storage)
+		ranged := NewRangedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket)
+		return storage, server, NewRetryingS3Client(ranged, policy)
+	}
+
+	Convey("should resume from the version of the object first read when the object is replaced", t, func() {
+		storage, server, r := newStorage(true)
+		defer server.Close()
+
+		body, _, err := r.Get(ctx, testS3Path)
+		So(err, ShouldBeNil)
+
+		b, err := io.ReadAll(body)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "0123456789")
+		So(storage.requests, ShouldResemble, []string{"", "v1"})
+	})
+
+	Convey("should fail rather than splice objects when an unversioned object is replaced", t, func() {
+		_, server, r := newStorage(false)
+		defer server.Close()
+
+		body, _, err := r.Get(ctx, testS3Path)
+		So(err, ShouldBeNil)
+
+		b, err := io.ReadAll(body)
+		So(errors.Is(err, ErrObjectChanged), ShouldBeTrue)
+		So(string(b), ShouldEqual, "0123")
+	})
+}
+
+// breakingS3Client breaks every stream after a number of bytes, recording the offsets that were read from
+type breakingS3Client struct {
+	*memoryS3Client
+	breakAfter int
+	offsets    []int64
+}
+
+func (b *breakingS3Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	return b.GetRange(ctx, key, 0, -1)
+}
+
+func (b *breakingS3Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *int64, error) {
+	b.offsets = append(b.offsets, offset)
+
+	body, size, err := b.memoryS3Client.GetRange(ctx, key, offset, length)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return readCloser{Reader: &breakingReader{r: body, n: b.breakAfter}, Closer: body}, size, nil
+}
@@ -26,6 +26,7 @@ type S3Client interface {
 // S3StreamWriter provides functionality for retrieving content from an S3 bucket. The content is streamed and and written to the provided io.Writer
 type S3StreamWriter struct {
 	S3Client S3Client
+	Cache    Cache // computed checksums are stored in the Cache, if there is one
 }
 
 // NewStreamWriter create a new S3StreamWriter instance.
@@ -35,16 +36,22 @@ func NewStreamWriter(s3c S3Client) *S3StreamWriter {
 	}
 }
 
-// StreamAndWrite stream the request file writing the content to the provided io.Writer.
-func (s S3StreamWriter) StreamAndWrite(ctx context.Context, s3Path string, w io.Writer) (err error) {
+// StreamAndWrite stream the request file writing the content to the provided io.Writer. If the size of the file is
+// known it is passed to setSize, if not nil, before any content is written.
+func (s S3StreamWriter) StreamAndWrite(ctx context.Context, s3Path string, w io.Writer, setSize func(size int64)) (err error) {
 	var s3ReadCloser io.ReadCloser
-	s3ReadCloser, _, err = s.S3Client.Get(ctx, s3Path)
+	var size *int64
+	s3ReadCloser, size, err = s.S3Client.Get(ctx, s3Path)
 	if err != nil {
 		return fmt.Errorf("failed to get stream object from S3 client: %w", err)
 	}
 
 	defer closeAndLogError(ctx, s3ReadCloser)
 
+	if size != nil && setSize != nil {
+		setSize(*size)
+	}
+
 	_, err = io.Copy(w, s3ReadCloser)
 	if err != nil {
 		return fmt.Errorf("failed to write response: %w", err)
@@ -53,6 +60,16 @@ func (s S3StreamWriter) StreamAndWrite(ctx context.Context, s3Path string, w io.
 	return nil
 }
 
+// GetRange returns length bytes of the requested file starting at offset, along with the size of the whole file.
+func (s S3StreamWriter) GetRange(ctx context.Context, s3Path string, offset, length int64) (io.ReadCloser, *int64, error) {
+	return GetRange(ctx, s.S3Client, s3Path, offset, length)
+}
+
+// Checksum returns the hex encoded SHA-256 checksum of the requested file
+func (s S3StreamWriter) Checksum(ctx context.Context, s3Path string) (string, error) {
+	return Checksum(ctx, s.S3Client, s.Cache, s3Path)
+}
+
 func closeAndLogError(ctx context.Context, closer io.Closer) {
 	if err := closer.Close(); err != nil {
 		log.Error(ctx, "error closing io.Closer", err)
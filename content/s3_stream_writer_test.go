@@ -39,7 +39,7 @@ func TestStreamWriter_WriteContent(t *testing.T) {
 			S3Client: s3Cli,
 		}
 
-		err := s.StreamAndWrite(ctx, testS3Path, w)
+		err := s.StreamAndWrite(ctx, testS3Path, w, nil)
 
 		So(errors.Is(err, expectedErr), ShouldBeTrue)
 	})
@@ -53,7 +53,7 @@ func TestStreamWriter_WriteContent(t *testing.T) {
 			S3Client: s3Cli,
 		}
 
-		err := s.StreamAndWrite(ctx, testS3Path, w)
+		err := s.StreamAndWrite(ctx, testS3Path, w, nil)
 
 		So(errors.Is(err, expectedErr), ShouldBeTrue)
 	})
@@ -67,7 +67,7 @@ func TestStreamWriter_WriteContent(t *testing.T) {
 			S3Client: s3Cli,
 		}
 
-		err := s.StreamAndWrite(ctx, testS3Path, w)
+		err := s.StreamAndWrite(ctx, testS3Path, w, nil)
 
 		So(errors.Is(err, expectedErr), ShouldBeTrue)
 	})
@@ -81,7 +81,7 @@ func TestStreamWriter_WriteContent(t *testing.T) {
 			S3Client: s3Cli,
 		}
 
-		err := s.StreamAndWrite(ctx, testS3Path, writer)
+		err := s.StreamAndWrite(ctx, testS3Path, writer, nil)
 
 		So(err, ShouldBeNil)
 		So(writer.data, ShouldResemble, []byte("1, 2, 3, 4"))
@@ -96,11 +96,35 @@ func TestStreamWriter_WriteContent(t *testing.T) {
 			S3Client: s3Cli,
 		}
 
-		err := s.StreamAndWrite(ctx, testS3Path, writer)
+		err := s.StreamAndWrite(ctx, testS3Path, writer, nil)
 
 		So(err, ShouldBeNil)
 		So(writer.data, ShouldResemble, []byte("1, 2, 3, 4"))
 	})
+
+	Convey("should pass the size of the file to setSize before writing it", t, func() {
+		readCloser := io.NopCloser(strings.NewReader("1, 2, 3, 4"))
+		writer := &StubWriter{}
+		size := int64(10)
+		cli := mocks.NewMockS3Client(ctrl)
+		cli.EXPECT().Get(gomock.Any(), testS3Path).Times(1).Return(readCloser, &size, nil)
+
+		s := &S3StreamWriter{
+			S3Client: cli,
+		}
+
+		var written []byte
+		var setSize int64
+		err := s.StreamAndWrite(ctx, testS3Path, writer, func(size int64) {
+			written = writer.data
+			setSize = size
+		})
+
+		So(err, ShouldBeNil)
+		So(setSize, ShouldEqual, 10)
+		So(written, ShouldBeNil)
+		So(writer.data, ShouldResemble, []byte("1, 2, 3, 4"))
+	})
 }
 
 func s3ClientGetReturnsError(ctrl *gomock.Controller, key string) (*mocks.MockS3Client, error) {
@@ -0,0 +1,76 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	ErrVersionNotFound        = errors.New("object version not found")
+	ErrVersioningNotSupported = errors.New("s3 client does not support reading object versions")
+)
+
+// VersionGetter is implemented by S3 clients that can read a specific version of an object in a versioned bucket
+type VersionGetter interface {
+	// GetVersion returns length bytes of the object version starting at offset, or the rest of the object version
+	// if length is negative. The returned size is the total size of the object version.
+	GetVersion(ctx context.Context, key, versionID string, offset, length int64) (io.ReadCloser, *int64, error)
+}
+
+// GetVersion reads part of a specific version of an object. An empty versionID reads the current version. An error
+// is returned rather than the current version if s3c cannot read object versions.
+func GetVersion(ctx context.Context, s3c S3Client, key, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+	if versionID == "" {
+		return GetRange(ctx, s3c, key, offset, length)
+	}
+
+	vg, ok := s3c.(VersionGetter)
+	if !ok {
+		return nil, nil, ErrVersioningNotSupported
+	}
+
+	return vg.GetVersion(ctx, key, versionID, offset, length)
+}
+
+// GetVersion returns part of a specific version of an object using the AWS SDK
+func (r *RangedS3Client) GetVersion(ctx context.Context, key, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+	in := &s3.GetObjectInput{
+		Bucket:    aws.String(r.Bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}
+
+	return getObjectVersion(ctx, r.Objects, in, offset, length)
+}
+
+// getObjectVersion reads a range of the object version requested by in, identifying errors caused by the version
+func getObjectVersion(ctx context.Context, objects ObjectGetter, in *s3.GetObjectInput, offset, length int64) (io.ReadCloser, *int64, error) {
+	body, size, err := getObjectRange(ctx, objects, in, offset, length)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.ErrorCode() {
+			case "NoSuchVersion", "NoSuchKey", "InvalidArgument":
+				return nil, nil, fmt.Errorf("%w: %s %s: %w", ErrVersionNotFound, aws.ToString(in.Key), aws.ToString(in.VersionId), err)
+			}
+		}
+		return nil, nil, err
+	}
+
+	return body, size, nil
+}
+
+// IsNotFound reports whether err is caused by an object that does not exist in the bucket
+func IsNotFound(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "NoSuchKey" || apiErr.ErrorCode() == "NotFound"
+}
@@ -0,0 +1,74 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-download-service/content/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// versionedStorage is a stand-in for a versioned S3 bucket holding versions of a single object
+type versionedStorage map[string]string
+
+func (st versionedStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	obj, ok := st[r.URL.Query().Get("versionId")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<Error><Code>NoSuchVersion</Code></Error>")) // nolint
+		return
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(obj)-1, len(obj)))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write([]byte(obj)) // nolint
+}
+
+func TestGetVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	server := httptest.NewServer(versionedStorage{"v1": "old", "v2": "new"})
+	defer server.Close()
+
+	r := NewRangedS3Client(mocks.NewMockS3Client(ctrl), newStandInObjectGetter(server.URL), testBucket)
+
+	Convey("should read the requested version", t, func() {
+		body, size, err := GetVersion(ctx, r, testS3Path, "v1", 0, -1)
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "old")
+		So(*size, ShouldEqual, 3)
+	})
+
+	Convey("should return ErrVersionNotFound for unknown versions", t, func() {
+		_, _, err := GetVersion(ctx, r, testS3Path, "v3", 0, -1)
+
+		So(errors.Is(err, ErrVersionNotFound), ShouldBeTrue)
+	})
+
+	Convey("should fail rather than read the current version if the client does not support versions", t, func() {
+		_, _, err := GetVersion(ctx, mocks.NewMockS3Client(ctrl), testS3Path, "v1", 0, -1)
+
+		So(err, ShouldEqual, ErrVersioningNotSupported)
+	})
+
+	Convey("should read the current version when no version is given", t, func() {
+		s3Cli := s3ClientGetReturnsReader(ctrl, testS3Path, io.NopCloser(strings.NewReader("current")))
+
+		body, _, err := GetVersion(ctx, s3Cli, testS3Path, "", 0, -1)
+
+		So(err, ShouldBeNil)
+		b, _ := io.ReadAll(body)
+		So(string(b), ShouldEqual, "current")
+	})
+}
@@ -0,0 +1,119 @@
+package disposition
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Disposition types
+const (
+	Attachment = "attachment"
+	Inline     = "inline"
+)
+
+// QueryParam is the query parameter used to request a disposition type, e.g. ?disposition=inline
+const QueryParam = "disposition"
+
+// defaultFilename is used when nothing is left of a filename once it has been sanitised
+const defaultFilename = "download"
+
+// inlineTypes are the media types that browsers render themselves. HTML and SVG are left out so files cannot run
+// scripts on the download service's domain.
+var inlineTypes = map[string]bool{
+	"application/json": true,
+	"application/pdf":  true,
+	"image/gif":        true,
+	"image/jpeg":       true,
+	"image/png":        true,
+	"image/webp":       true,
+	"text/csv":         true,
+	"text/plain":       true,
+}
+
+// Type returns the disposition type of a response with the given content type. Files are downloaded as attachments
+// unless inline display is requested, by the query parameter or by default, and browsers can render the content type.
+// Attachments can always be requested with ?disposition=attachment.
+func Type(req *http.Request, contentType string, inlineByDefault bool) string {
+	inline := inlineByDefault
+	switch strings.ToLower(req.URL.Query().Get(QueryParam)) {
+	case Inline:
+		inline = true
+	case Attachment:
+		inline = false
+	}
+
+	if inline && Renderable(contentType) {
+		return Inline
+	}
+	return Attachment
+}
+
+// Renderable reports whether browsers can display content of the given type
+func Renderable(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return inlineTypes[mediaType]
+}
+
+// Header returns a Content-Disposition header value following RFC 6266. The filename is given as a quoted ASCII
+// fallback for older clients and as UTF-8 in a filename* parameter (RFC 5987).
+func Header(dispositionType, filename string) string {
+	filename = Sanitise(filename)
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, asciiFallback(filename), encode(filename))
+}
+
+// Sanitise removes control characters from a filename and replaces path separators, so the name cannot be used to
+// write outside of the directory it is saved to.
+func Sanitise(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, filename)
+
+	filename = strings.TrimSpace(filename)
+	if filename == "" || filename == "." || filename == ".." {
+		return defaultFilename
+	}
+	return filename
+}
+
+// asciiFallback replaces the characters of a filename that cannot appear in a quoted string understood by all clients
+func asciiFallback(filename string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || r == '"' || r == '%' {
+			return '_'
+		}
+		return r
+	}, filename)
+}
+
+// encode percent-encodes the UTF-8 bytes of a filename that are not attr-chars (RFC 5987)
+func encode(filename string) string {
+	var b strings.Builder
+	for _, c := range []byte(filename) {
+		if isAttrChar(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
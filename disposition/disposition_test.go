@@ -0,0 +1,73 @@
+package disposition
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+	t.Run("quotes ASCII filenames", func(t *testing.T) {
+		assert.Equal(t, `attachment; filename="published.csv"; filename*=UTF-8''published.csv`, Header(Attachment, "published.csv"))
+	})
+
+	t.Run("encodes spaces, commas and semicolons", func(t *testing.T) {
+		assert.Equal(t,
+			`attachment; filename="prices, 2026; final.csv"; filename*=UTF-8''prices%2C%202026%3B%20final.csv`,
+			Header(Attachment, "prices, 2026; final.csv"))
+	})
+
+	t.Run("keeps reserved characters the feature files use", func(t *testing.T) {
+		assert.Equal(t,
+			`attachment; filename="weird&chars#published.csv"; filename*=UTF-8''weird&chars#published.csv`,
+			Header(Attachment, "weird&chars#published.csv"))
+	})
+
+	t.Run("gives an ASCII fallback for UTF-8 filenames", func(t *testing.T) {
+		assert.Equal(t,
+			`inline; filename="chwyddiant-prisiau-defnyddwyr-c_r.csv"; filename*=UTF-8''chwyddiant-prisiau-defnyddwyr-c%C5%B5r.csv`,
+			Header(Inline, "chwyddiant-prisiau-defnyddwyr-cŵr.csv"))
+	})
+
+	t.Run("replaces quotes and percent signs in the fallback", func(t *testing.T) {
+		assert.Equal(t,
+			`attachment; filename="_100__ _real_.csv"; filename*=UTF-8''%22100%25%22%20%22real%22.csv`,
+			Header(Attachment, `"100%" "real".csv`))
+	})
+}
+
+func TestSanitise(t *testing.T) {
+	assert.Equal(t, "_etc_passwd", Sanitise("/etc/passwd"))
+	assert.Equal(t, ".._windows_win.ini", Sanitise(`..\windows\win.ini`))
+	assert.Equal(t, "report.csv", Sanitise("re\r\nport\x00.csv\t"))
+	assert.Equal(t, "download", Sanitise(" \x7f"))
+	assert.Equal(t, "download", Sanitise(".."))
+}
+
+func TestType(t *testing.T) {
+	request := func(target string) *http.Request {
+		return httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	}
+
+	t.Run("defaults to attachments", func(t *testing.T) {
+		assert.Equal(t, Attachment, Type(request("/downloads/files/image.png"), "image/png", false))
+	})
+
+	t.Run("displays renderable types inline when requested", func(t *testing.T) {
+		assert.Equal(t, Inline, Type(request("/downloads/files/image.png?disposition=inline"), "image/png", false))
+		assert.Equal(t, Inline, Type(request("/downloads/files/data.csv?disposition=INLINE"), "text/csv; charset=utf-8", false))
+	})
+
+	t.Run("displays renderable types inline by default when configured", func(t *testing.T) {
+		assert.Equal(t, Inline, Type(request("/downloads/files/report.pdf"), "application/pdf", true))
+		assert.Equal(t, Attachment, Type(request("/downloads/files/report.pdf?disposition=attachment"), "application/pdf", true))
+	})
+
+	t.Run("downloads types browsers cannot render safely as attachments", func(t *testing.T) {
+		for _, contentType := range []string{"text/html", "image/svg+xml", "application/zip", "application/vnd.ms-excel", ""} {
+			assert.Equal(t, Attachment, Type(request("/downloads/files/file?disposition=inline"), contentType, true), contentType)
+		}
+	})
+}
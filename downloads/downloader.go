@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
 	"github.com/ONSdigital/dp-api-clients-go/v2/filter"
+	"github.com/ONSdigital/dp-api-clients-go/v2/headers"
 	"github.com/ONSdigital/dp-api-clients-go/v2/image"
 	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
 	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
@@ -23,12 +26,15 @@ import (
 type DatasetClient interface {
 	GetVersion(ctx context.Context, userAuthToken, serviceAuthToken, downloadServiceAuthToken, collectionID, datasetID, edition, version string) (m dataset.Version, err error)
 	GetInstance(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, instanceID, ifMatch string) (m dataset.Instance, eTag string, err error)
+	GetDatasetCurrentAndNext(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, datasetID string) (m dataset.Dataset, err error)
+	GetFullEditionsDetails(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, datasetID string) (m []dataset.EditionsDetails, err error)
 	Checker(ctx context.Context, check *healthcheck.CheckState) error
 }
 
 // FilesClient is interface to the files api
 type FilesClient interface {
 	GetFile(ctx context.Context, filePath string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error)
+	GetFiles(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error)
 	CreateFileEvent(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error)
 	Checker(ctx context.Context, state *healthcheck.CheckState) error
 }
@@ -53,6 +59,7 @@ const (
 	TypeDatasetVersion FileType = iota
 	TypeFilterOutput
 	TypeImage
+	TypeInstance
 	StatePublished = "published"
 	StateCompleted = "completed"
 )
@@ -63,6 +70,12 @@ type Model struct {
 	Public          string
 	PrivateS3Path   string
 	PrivateFilename string
+	Variant         string // the variant downloaded, which may be a fallback for the variant requested
+	HasCSVW         bool   // whether a CSV download is described by a CSVW download of the same resource
+	ReleaseDate     string
+	DatasetID       string
+	Edition         string
+	Version         string
 }
 
 // Parameters is the union of required parameters to perform all downloads
@@ -86,6 +99,7 @@ type Downloader struct {
 	FilterCli  FilterClient
 	DatasetCli DatasetClient
 	ImageCli   ImageClient
+	Variants   map[string][]string // the variants downloaded, in order of preference, for a requested variant
 }
 
 // Get requests the required metadata using a client depending on the provided paramters
@@ -115,6 +129,13 @@ func (d Downloader) Get(ctx context.Context, p Parameters, fileType FileType, va
 		})
 		return d.getDatasetVersionDownload(ctx, p, variant)
 
+	case TypeInstance:
+		log.Info(ctx, "getting downloads for instance", log.Data{
+			"instance_id":   p.InstanceID,
+			"collection_id": p.CollectionID,
+		})
+		return d.getInstanceDownload(ctx, p, variant)
+
 	default:
 		return Model{}, errors.New("unsupported file type")
 	}
@@ -132,11 +153,18 @@ func (d Downloader) getFilterOutputDownload(ctx context.Context, p Parameters, v
 
 	model := Model{
 		IsPublished: fo.IsPublished,
+		HasCSVW:     variant == "csv" && hasDownload(fo.Downloads["csvw"].Private, fo.Downloads["csvw"].Public),
+		DatasetID:   fo.Dataset.DatasetID,
+		Edition:     fo.Dataset.Edition,
+	}
+	if fo.Dataset.Version > 0 {
+		model.Version = strconv.Itoa(fo.Dataset.Version)
 	}
 
-	v, ok := fo.Downloads[variant]
+	key, v, ok := findDownload(fo.Downloads, d.variants(variant))
 	if ok {
 		// The filter output will be considered published (available for public downloads), when it is in 'published' state.
+		model.Variant = key
 		model.Public = v.Public
 		s3Path, filename, err := parseURL(v.Private)
 		if err != nil {
@@ -159,11 +187,52 @@ func (d Downloader) getDatasetVersionDownload(ctx context.Context, p Parameters,
 
 	model := Model{
 		IsPublished: version.State == dataset.StatePublished.String(),
+		HasCSVW:     variant == "csv" && hasDownload(version.Downloads["csvw"].Private, version.Downloads["csvw"].Public),
+		ReleaseDate: version.ReleaseDate,
+		DatasetID:   p.DatasetID,
+		Edition:     p.Edition,
+		Version:     p.Version,
 	}
 
-	v, ok := version.Downloads[variant]
+	key, v, ok := findDownload(version.Downloads, d.variants(variant))
 	if ok {
 		// The dataset will be considered published (available for public downloads), when it is in 'published' state.
+		model.Variant = key
+		model.Public = v.Public
+		s3Path, filename, err := parseURL(v.Private)
+		if err != nil {
+			return downloads, err
+		}
+		model.PrivateS3Path = s3Path
+		model.PrivateFilename = filename
+	}
+
+	return model, nil
+}
+
+// getInstanceDownload gets the Model for an instance, which is not published until it becomes a dataset version
+func (d Downloader) getInstanceDownload(ctx context.Context, p Parameters, variant string) (Model, error) {
+	var downloads Model
+
+	instance, _, err := d.DatasetCli.GetInstance(ctx, p.UserAuthToken, p.ServiceAuthToken, p.CollectionID, p.InstanceID, headers.IfMatchAnyETag)
+	if err != nil {
+		return downloads, err
+	}
+
+	model := Model{
+		IsPublished: instance.State == dataset.StatePublished.String(),
+		HasCSVW:     variant == "csv" && hasDownload(instance.Downloads["csvw"].Private, instance.Downloads["csvw"].Public),
+		ReleaseDate: instance.ReleaseDate,
+		DatasetID:   instance.Links.Dataset.ID,
+		Edition:     instance.Edition,
+	}
+	if instance.Version.Version > 0 {
+		model.Version = strconv.Itoa(instance.Version.Version)
+	}
+
+	key, v, ok := findDownload(instance.Downloads, d.variants(variant))
+	if ok {
+		model.Variant = key
 		model.Public = v.Public
 		s3Path, filename, err := parseURL(v.Private)
 		if err != nil {
@@ -191,6 +260,7 @@ func (d Downloader) getImageDownload(ctx context.Context, p Parameters, variant
 		IsPublished:     (StatePublished == imageVariant.State || StateCompleted == imageVariant.State),
 		PrivateS3Path:   privatePath,
 		PrivateFilename: p.Filename,
+		Variant:         variant,
 	}
 	if imageVariant.State == StateCompleted {
 		downloads.Public = imageVariant.Href
@@ -204,6 +274,40 @@ func (m Model) IsPublicLinkAvailable() bool {
 	return m.Public != "" && m.IsPublished
 }
 
+// Embargoed reports whether the download has a release date that has not passed yet
+func (m Model) Embargoed(now time.Time) (release time.Time, embargoed bool) {
+	release, err := time.Parse(time.RFC3339, m.ReleaseDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return release, now.Before(release)
+}
+
+// variants returns the variants downloaded for a requested variant, in order of preference. Only the requested variant
+// is downloaded unless other variants are configured for it, e.g. xlsx downloads falling back to xls downloads.
+func (d Downloader) variants(variant string) []string {
+	if variants, ok := d.Variants[variant]; ok && len(variants) > 0 {
+		return variants
+	}
+	return []string{variant}
+}
+
+// findDownload returns the first of the variants that has a download
+func findDownload[T any](downloads map[string]T, variants []string) (string, T, bool) {
+	for _, variant := range variants {
+		if download, ok := downloads[variant]; ok {
+			return variant, download, true
+		}
+	}
+
+	var none T
+	return "", none, false
+}
+
+func hasDownload(private, public string) bool {
+	return private != "" || public != ""
+}
+
 func parseURL(urlString string) (path, filename string, err error) {
 	parsedURL, err := url.Parse(urlString)
 	if err != nil {
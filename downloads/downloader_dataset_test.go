@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
 	"github.com/ONSdigital/dp-download-service/downloads/mocks"
@@ -146,6 +147,61 @@ func TestGetDownloadForDataset(t *testing.T) {
 		So(downloads.PrivateS3Path, ShouldResemble, testCSVPrivateS3Path)
 		So(err, ShouldBeNil)
 	})
+
+	Convey("should describe where a CSV download came from and whether it has CSVW metadata", t, func() {
+		datasetDownload := testDatasetDownload()
+		datasetVersion := testDatasetVersion("published", &datasetDownload)
+		datasetVersion.Downloads["csvw"] = datasetDownload
+		datasetVersion.ReleaseDate = "2026-03-04T09:30:00.000Z"
+
+		d := Downloader{
+			DatasetCli: successfulDatasetClient(ctrl, testDatasetVersionDownloadParams, datasetVersion),
+			FilterCli:  filterOutputClientNeverInvoked(ctrl),
+			ImageCli:   imageClientNeverInvoked(ctrl),
+		}
+
+		downloads, err := d.Get(ctx, testDatasetVersionDownloadParams, TypeDatasetVersion, "csv")
+
+		So(err, ShouldBeNil)
+		So(downloads.HasCSVW, ShouldBeTrue)
+		So(downloads.ReleaseDate, ShouldEqual, "2026-03-04T09:30:00.000Z")
+		So(downloads.DatasetID, ShouldEqual, "datasetID")
+		So(downloads.Edition, ShouldEqual, "edition")
+		So(downloads.Version, ShouldEqual, "version")
+	})
+
+	Convey("should download distinct xls and xlsx variants, falling back between them in order of preference", t, func() {
+		variants := map[string][]string{"xls": {"xls", "xlsx"}, "xlsx": {"xlsx", "xls"}}
+		xls := dataset.Download{Private: "http://private.localhost/private/filename.xls"}
+		xlsx := dataset.Download{Private: "http://private.localhost/private/filename.xlsx"}
+
+		get := func(downloads map[string]dataset.Download, variant string) Model {
+			d := Downloader{
+				DatasetCli: successfulDatasetClient(ctrl, testDatasetVersionDownloadParams, dataset.Version{Downloads: downloads}),
+				FilterCli:  filterOutputClientNeverInvoked(ctrl),
+				ImageCli:   imageClientNeverInvoked(ctrl),
+				Variants:   variants,
+			}
+
+			m, err := d.Get(ctx, testDatasetVersionDownloadParams, TypeDatasetVersion, variant)
+			So(err, ShouldBeNil)
+			return m
+		}
+
+		both := map[string]dataset.Download{"xls": xls, "xlsx": xlsx}
+		So(get(both, "xls").PrivateFilename, ShouldEqual, "filename.xls")
+		So(get(both, "xls").ContentType(), ShouldEqual, "application/vnd.ms-excel")
+		So(get(both, "xlsx").PrivateFilename, ShouldEqual, "filename.xlsx")
+		So(get(both, "xlsx").ContentType(), ShouldEqual, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+
+		fallback := get(map[string]dataset.Download{"xls": xls}, "xlsx")
+		So(fallback.Variant, ShouldEqual, "xls")
+		So(fallback.PrivateFilename, ShouldEqual, "filename.xls")
+		So(fallback.ContentType(), ShouldEqual, "application/vnd.ms-excel")
+
+		variants["xlsx"] = []string{"xlsx"}
+		So(get(map[string]dataset.Download{"xls": xls}, "xlsx").PrivateS3Path, ShouldBeBlank)
+	})
 }
 
 func erroringDatasetClient(c *gomock.Controller, p Parameters, err error) *mocks.MockDatasetClient {
@@ -194,6 +250,31 @@ func testDatasetVersion(state string, dl *dataset.Download) dataset.Version {
 	return version
 }
 
+func TestModelEmbargoed(t *testing.T) {
+	now := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	Convey("Given a download with a release date in the future then it is embargoed until the release date", t, func() {
+		release, embargoed := Model{ReleaseDate: "2026-03-04T09:30:00.000Z"}.Embargoed(now)
+
+		So(embargoed, ShouldBeTrue)
+		So(release, ShouldEqual, time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC))
+	})
+
+	Convey("Given a download with a release date that has passed then it is not embargoed", t, func() {
+		_, embargoed := Model{ReleaseDate: "2026-03-04T09:00:00Z"}.Embargoed(now)
+
+		So(embargoed, ShouldBeFalse)
+	})
+
+	Convey("Given a download without a valid release date then it is not embargoed", t, func() {
+		for _, releaseDate := range []string{"", "4 March 2026"} {
+			_, embargoed := Model{ReleaseDate: releaseDate}.Embargoed(now)
+
+			So(embargoed, ShouldBeFalse)
+		}
+	})
+}
+
 func successfulDatasetClient(c *gomock.Controller, p Parameters, v dataset.Version) *mocks.MockDatasetClient {
 	cli := mocks.NewMockDatasetClient(c)
 
@@ -0,0 +1,103 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-download-service/downloads/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	errInstance                = errors.New("borked instance")
+	testInstanceDownloadParams = Parameters{
+		UserAuthToken:    "userAuthToken",
+		ServiceAuthToken: "serviceAuthToken",
+		CollectionID:     "collectionID",
+		InstanceID:       "instanceID",
+	}
+)
+
+func TestGetDownloadForInstance(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	Convey("should return error if dataset client get instance returns an error", t, func() {
+		d := Downloader{
+			DatasetCli: instanceDatasetClient(ctrl, testInstanceDownloadParams, dataset.Instance{}, errInstance),
+			FilterCli:  filterOutputClientNeverInvoked(ctrl),
+			ImageCli:   imageClientNeverInvoked(ctrl),
+		}
+
+		downloads, err := d.Get(ctx, testInstanceDownloadParams, TypeInstance, "csv")
+
+		So(downloads.PrivateS3Path, ShouldBeBlank)
+		So(err, ShouldResemble, errInstance)
+	})
+
+	Convey("should return the private download of an unpublished instance and the version it will become", t, func() {
+		instance := dataset.Instance{Version: dataset.Version{
+			State:       "edition-confirmed",
+			Edition:     "time-series",
+			Version:     6,
+			ReleaseDate: "2026-03-04T09:30:00.000Z",
+			Links:       dataset.Links{Dataset: dataset.Link{ID: "cpih01"}},
+			Downloads: map[string]dataset.Download{
+				"csv":  {Private: testCSVPrivateUrl},
+				"csvw": {Private: "http://private.localhost/private/filename.csv-metadata.json"},
+			},
+		}}
+
+		d := Downloader{
+			DatasetCli: instanceDatasetClient(ctrl, testInstanceDownloadParams, instance, nil),
+			FilterCli:  filterOutputClientNeverInvoked(ctrl),
+			ImageCli:   imageClientNeverInvoked(ctrl),
+		}
+
+		downloads, err := d.Get(ctx, testInstanceDownloadParams, TypeInstance, "csv")
+
+		So(err, ShouldBeNil)
+		So(downloads, ShouldResemble, Model{
+			PrivateS3Path:   testCSVPrivateS3Path,
+			PrivateFilename: testCSVPrivateFilename,
+			Variant:         "csv",
+			HasCSVW:         true,
+			ReleaseDate:     "2026-03-04T09:30:00.000Z",
+			DatasetID:       "cpih01",
+			Edition:         "time-series",
+			Version:         "6",
+		})
+	})
+
+	Convey("should return empty downloads if the instance has no download of the variant", t, func() {
+		d := Downloader{
+			DatasetCli: instanceDatasetClient(ctrl, testInstanceDownloadParams, dataset.Instance{}, nil),
+			FilterCli:  filterOutputClientNeverInvoked(ctrl),
+			ImageCli:   imageClientNeverInvoked(ctrl),
+		}
+
+		downloads, err := d.Get(ctx, testInstanceDownloadParams, TypeInstance, "xlsx")
+
+		So(err, ShouldBeNil)
+		So(downloads.PrivateS3Path, ShouldBeBlank)
+		So(downloads.Public, ShouldBeBlank)
+	})
+}
+
+func instanceDatasetClient(c *gomock.Controller, p Parameters, i dataset.Instance, err error) *mocks.MockDatasetClient {
+	cli := mocks.NewMockDatasetClient(c)
+
+	cli.EXPECT().GetInstance(
+		gomock.Any(),
+		gomock.Eq(p.UserAuthToken),
+		gomock.Eq(p.ServiceAuthToken),
+		gomock.Eq(p.CollectionID),
+		gomock.Eq(p.InstanceID),
+		gomock.Eq("*"),
+	).Times(1).Return(i, "", err)
+	return cli
+}
@@ -0,0 +1,98 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Latest is the alias of the most recent edition or version of a dataset
+const Latest = "latest"
+
+// ErrLatestVersionNotFound is returned when a dataset or edition has no version that the latest alias resolves to
+var ErrLatestVersionNotFound = errors.New("latest version not found")
+
+// LatestVersion resolves the latest edition and version aliases in the parameters to the edition and version of the
+// most recent published version of the dataset or edition. Unpublished versions are included if includeUnpublished
+// is true and the dataset API returns them. Parameters that are not aliases are returned unchanged.
+func (d Downloader) LatestVersion(ctx context.Context, p Parameters, includeUnpublished bool) (edition, version string, err error) {
+	log.Info(ctx, "resolving latest dataset version", log.Data{
+		"dataset_id":          p.DatasetID,
+		"edition":             p.Edition,
+		"version":             p.Version,
+		"include_unpublished": includeUnpublished,
+	})
+
+	var link dataset.Link
+	if p.Edition == Latest {
+		ds, err := d.DatasetCli.GetDatasetCurrentAndNext(ctx, p.UserAuthToken, p.ServiceAuthToken, p.CollectionID, p.DatasetID)
+		if err != nil {
+			return "", "", err
+		}
+		link = latestVersionLink(includeUnpublished, ds.Links, datasetLinks(ds.Current), datasetLinks(ds.Next))
+	} else {
+		editions, err := d.DatasetCli.GetFullEditionsDetails(ctx, p.UserAuthToken, p.ServiceAuthToken, p.CollectionID, p.DatasetID)
+		if err != nil {
+			return "", "", err
+		}
+		for _, e := range editions {
+			if e.Edition.Edition == p.Edition || e.Current.Edition == p.Edition || e.Next.Edition == p.Edition {
+				link = latestVersionLink(includeUnpublished, e.Links, e.Current.Links, e.Next.Links)
+				break
+			}
+		}
+	}
+
+	edition, version, ok := parseVersionLink(link.URL)
+	if !ok {
+		return "", "", ErrLatestVersionNotFound
+	}
+
+	if p.Edition != Latest {
+		edition = p.Edition
+	}
+	if p.Version != Latest {
+		version = p.Version
+	}
+	return edition, version, nil
+}
+
+// latestVersionLink returns the link to the latest version of a dataset or edition. Authorised callers of the dataset
+// API in the publishing environment are given the current (published) and next documents, which includes unpublished
+// versions, while other callers are only given the published document.
+func latestVersionLink(includeUnpublished bool, published, current, next dataset.Links) dataset.Link {
+	if includeUnpublished && next.LatestVersion.URL != "" {
+		return next.LatestVersion
+	}
+	if current.LatestVersion.URL != "" {
+		return current.LatestVersion
+	}
+	return published.LatestVersion
+}
+
+func datasetLinks(details *dataset.DatasetDetails) dataset.Links {
+	if details == nil {
+		return dataset.Links{}
+	}
+	return details.Links
+}
+
+// parseVersionLink returns the edition and version of a link to a dataset version, such as
+// http://localhost:22000/datasets/cpih01/editions/time-series/versions/6
+func parseVersionLink(href string) (edition, version string, ok bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", "", false
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	n := len(segments)
+	if n < 4 || segments[n-4] != "editions" || segments[n-2] != "versions" || segments[n-3] == "" || segments[n-1] == "" {
+		return "", "", false
+	}
+	return segments[n-3], segments[n-1], true
+}
@@ -0,0 +1,139 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-download-service/downloads/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testPublishedVersionURL   = "http://localhost:22000/datasets/cpih01/editions/time-series/versions/6"
+	testUnpublishedVersionURL = "http://localhost:22000/datasets/cpih01/editions/time-series/versions/7"
+)
+
+func latestVersionLinks(href string) dataset.Links {
+	return dataset.Links{LatestVersion: dataset.Link{URL: href}}
+}
+
+func TestLatestVersion(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	Convey("Given the latest edition then it resolves to the edition and version of the latest version of the dataset", t, func() {
+		p := Parameters{DatasetID: "cpih01", Edition: Latest, Version: Latest}
+		ds := dataset.Dataset{DatasetDetails: dataset.DatasetDetails{Links: latestVersionLinks(testPublishedVersionURL)}}
+
+		cli := mocks.NewMockDatasetClient(ctrl)
+		cli.EXPECT().GetDatasetCurrentAndNext(gomock.Any(), "", "", "", "cpih01").Return(ds, nil)
+
+		edition, version, err := Downloader{DatasetCli: cli}.LatestVersion(ctx, p, false)
+
+		So(err, ShouldBeNil)
+		So(edition, ShouldEqual, "time-series")
+		So(version, ShouldEqual, "6")
+	})
+
+	Convey("Given the latest edition and a version then only the edition is resolved", t, func() {
+		p := Parameters{DatasetID: "cpih01", Edition: Latest, Version: "2"}
+		ds := dataset.Dataset{DatasetDetails: dataset.DatasetDetails{Links: latestVersionLinks(testPublishedVersionURL)}}
+
+		cli := mocks.NewMockDatasetClient(ctrl)
+		cli.EXPECT().GetDatasetCurrentAndNext(gomock.Any(), "", "", "", "cpih01").Return(ds, nil)
+
+		edition, version, err := Downloader{DatasetCli: cli}.LatestVersion(ctx, p, false)
+
+		So(err, ShouldBeNil)
+		So(edition, ShouldEqual, "time-series")
+		So(version, ShouldEqual, "2")
+	})
+
+	Convey("Given the current and next documents of a dataset then unpublished versions are only included when asked for", t, func() {
+		p := Parameters{DatasetID: "cpih01", Edition: Latest, Version: Latest}
+		ds := dataset.Dataset{
+			Current: &dataset.DatasetDetails{Links: latestVersionLinks(testPublishedVersionURL)},
+			Next:    &dataset.DatasetDetails{Links: latestVersionLinks(testUnpublishedVersionURL)},
+		}
+
+		cli := mocks.NewMockDatasetClient(ctrl)
+		cli.EXPECT().GetDatasetCurrentAndNext(gomock.Any(), "", "", "", "cpih01").Return(ds, nil).Times(2)
+		d := Downloader{DatasetCli: cli}
+
+		_, version, err := d.LatestVersion(ctx, p, false)
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, "6")
+
+		_, version, err = d.LatestVersion(ctx, p, true)
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, "7")
+	})
+
+	Convey("Given the latest version of an edition then it resolves to the latest version of the edition", t, func() {
+		p := Parameters{DatasetID: "cpih01", Edition: "time-series", Version: Latest}
+		editions := []dataset.EditionsDetails{
+			{Edition: dataset.Edition{Edition: "2019", Links: latestVersionLinks("http://localhost:22000/datasets/cpih01/editions/2019/versions/1")}},
+			{
+				Current: dataset.Edition{Edition: "time-series", Links: latestVersionLinks(testPublishedVersionURL)},
+				Next:    dataset.Edition{Edition: "time-series", Links: latestVersionLinks(testUnpublishedVersionURL)},
+			},
+		}
+
+		cli := mocks.NewMockDatasetClient(ctrl)
+		cli.EXPECT().GetFullEditionsDetails(gomock.Any(), "", "", "", "cpih01").Return(editions, nil).Times(2)
+		d := Downloader{DatasetCli: cli}
+
+		edition, version, err := d.LatestVersion(ctx, p, false)
+		So(err, ShouldBeNil)
+		So(edition, ShouldEqual, "time-series")
+		So(version, ShouldEqual, "6")
+
+		_, version, err = d.LatestVersion(ctx, p, true)
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, "7")
+	})
+
+	Convey("Given an edition that does not exist then the latest version is not found", t, func() {
+		p := Parameters{DatasetID: "cpih01", Edition: "2020", Version: Latest}
+
+		cli := mocks.NewMockDatasetClient(ctrl)
+		cli.EXPECT().GetFullEditionsDetails(gomock.Any(), "", "", "", "cpih01").Return([]dataset.EditionsDetails{}, nil)
+
+		_, _, err := Downloader{DatasetCli: cli}.LatestVersion(ctx, p, false)
+
+		So(errors.Is(err, ErrLatestVersionNotFound), ShouldBeTrue)
+	})
+
+	Convey("Given the dataset API returns an error then it is returned", t, func() {
+		p := Parameters{DatasetID: "cpih01", Edition: Latest, Version: Latest}
+
+		cli := mocks.NewMockDatasetClient(ctrl)
+		cli.EXPECT().GetDatasetCurrentAndNext(gomock.Any(), "", "", "", "cpih01").Return(dataset.Dataset{}, errDataset)
+
+		_, _, err := Downloader{DatasetCli: cli}.LatestVersion(ctx, p, false)
+
+		So(err, ShouldEqual, errDataset)
+	})
+}
+
+func TestParseVersionLink(t *testing.T) {
+	Convey("Links to dataset versions are parsed to their edition and version", t, func() {
+		edition, version, ok := parseVersionLink(testPublishedVersionURL)
+
+		So(ok, ShouldBeTrue)
+		So(edition, ShouldEqual, "time-series")
+		So(version, ShouldEqual, "6")
+	})
+
+	Convey("Links that are not to dataset versions are not parsed", t, func() {
+		for _, href := range []string{"", "http://localhost:22000/datasets/cpih01", "http://localhost:22000/datasets/cpih01/editions/time-series/versions/"} {
+			_, _, ok := parseVersionLink(href)
+
+			So(ok, ShouldBeFalse)
+		}
+	})
+}
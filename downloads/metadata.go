@@ -0,0 +1,85 @@
+package downloads
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+)
+
+// States of files registered with the files API that a download can be in
+const (
+	fileStateUploaded  = "UPLOADED"
+	fileStatePublished = "PUBLISHED"
+	fileStateMoved     = "MOVED"
+)
+
+const defaultContentType = "application/octet-stream"
+
+// contentTypes are the media types of download extensions, in the order they are matched against filenames. Other
+// downloads, such as images, are given the media type of their extension.
+var contentTypes = []struct {
+	extension   string
+	contentType string
+}{
+	{".csv-metadata.json", "application/csvm+json"},
+	{".csvw", "application/csvm+json"},
+	{".csv", "text/csv"},
+	{".txt", "text/plain; charset=utf-8"},
+	{".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	{".xls", "application/vnd.ms-excel"},
+}
+
+// ContentType returns the media type of the download, from the extension of its filename or else its variant
+func (m Model) ContentType() string {
+	contentType := ContentType(m.PrivateFilename)
+	if contentType == defaultContentType && m.Variant != "" {
+		return ContentType("." + m.Variant)
+	}
+	return contentType
+}
+
+// ContentType returns the media type of a download with the filename
+func ContentType(filename string) string {
+	lower := strings.ToLower(filename)
+	for _, t := range contentTypes {
+		if strings.HasSuffix(lower, t.extension) {
+			return t.contentType
+		}
+	}
+
+	if contentType := mime.TypeByExtension(path.Ext(lower)); contentType != "" {
+		return contentType
+	}
+	return defaultContentType
+}
+
+// Metadata returns the private copy of the download as the metadata of an equivalent file registered with the files
+// API, so that it can be handled in the same way as files. Published downloads with a public link are moved.
+// The size of the download is not known.
+func (m Model) Metadata() *filesAPIModels.StoredRegisteredMetaData {
+	metadata := &filesAPIModels.StoredRegisteredMetaData{
+		Path:          m.PrivateS3Path,
+		IsPublishable: true,
+		Type:          m.ContentType(),
+		State:         fileStateUploaded,
+	}
+
+	switch {
+	case m.IsPublicLinkAvailable():
+		metadata.State = fileStateMoved
+	case m.IsPublished:
+		metadata.State = fileStatePublished
+	}
+
+	if m.DatasetID != "" {
+		metadata.ContentItem = &filesAPIModels.StoredContentItem{
+			DatasetID: m.DatasetID,
+			Edition:   m.Edition,
+			Version:   m.Version,
+		}
+	}
+
+	return metadata
+}
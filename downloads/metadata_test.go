@@ -0,0 +1,61 @@
+package downloads
+
+import (
+	"testing"
+
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContentType(t *testing.T) {
+	Convey("Download extensions are given their media type", t, func() {
+		So(ContentType("cpih01-time-series-v6.csv"), ShouldEqual, "text/csv")
+		So(ContentType("cpih01-time-series-v6.csv-metadata.json"), ShouldEqual, "application/csvm+json")
+		So(ContentType("cpih01-time-series-v6.txt"), ShouldEqual, "text/plain; charset=utf-8")
+		So(ContentType("cpih01-time-series-v6.xls"), ShouldEqual, "application/vnd.ms-excel")
+		So(ContentType("CPIH01-TIME-SERIES-V6.XLSX"), ShouldEqual, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		So(ContentType("chart.png"), ShouldEqual, "image/png")
+	})
+
+	Convey("Downloads without a known extension are binary", t, func() {
+		So(ContentType("original"), ShouldEqual, "application/octet-stream")
+	})
+
+	Convey("Downloads without a known extension are given the media type of their variant", t, func() {
+		So(Model{PrivateFilename: "download", Variant: "xlsx"}.ContentType(), ShouldEqual, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		So(Model{PrivateFilename: "download.xls", Variant: "xlsx"}.ContentType(), ShouldEqual, "application/vnd.ms-excel")
+		So(Model{PrivateFilename: "original", Variant: "original"}.ContentType(), ShouldEqual, "application/octet-stream")
+	})
+}
+
+func TestModelMetadata(t *testing.T) {
+	Convey("Given an unpublished dataset version download then its metadata is an uploaded file", t, func() {
+		m := Model{
+			PrivateS3Path:   testCSVPrivateS3Path,
+			PrivateFilename: testCSVPrivateFilename,
+			ReleaseDate:     "2026-03-04T09:30:00.000Z",
+			DatasetID:       "cpih01",
+			Edition:         "time-series",
+			Version:         "6",
+		}.Metadata()
+
+		So(m.Path, ShouldEqual, testCSVPrivateS3Path)
+		So(m.Type, ShouldEqual, "text/csv")
+		So(m.State, ShouldEqual, "UPLOADED")
+		So(m.IsPublishable, ShouldBeTrue)
+		So(m.ContentItem, ShouldResemble, &filesAPIModels.StoredContentItem{DatasetID: "cpih01", Edition: "time-series", Version: "6"})
+	})
+
+	Convey("Given a published download then its metadata is a published file", t, func() {
+		m := Model{IsPublished: true, PrivateS3Path: testCSVPrivateS3Path, PrivateFilename: testCSVPrivateFilename}.Metadata()
+
+		So(m.State, ShouldEqual, "PUBLISHED")
+		So(m.ContentItem, ShouldBeNil)
+	})
+
+	Convey("Given a published download with a public link then its metadata is a moved file", t, func() {
+		m := Model{IsPublished: true, Public: testCSVPublicUrl, PrivateS3Path: testCSVPrivateS3Path, PrivateFilename: testCSVPrivateFilename}.Metadata()
+
+		So(m.State, ShouldEqual, "MOVED")
+	})
+}
@@ -54,6 +54,36 @@ func (mr *MockDatasetClientMockRecorder) Checker(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checker", reflect.TypeOf((*MockDatasetClient)(nil).Checker), arg0, arg1)
 }
 
+// GetDatasetCurrentAndNext mocks base method.
+func (m *MockDatasetClient) GetDatasetCurrentAndNext(arg0 context.Context, arg1, arg2, arg3, arg4 string) (dataset.Dataset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDatasetCurrentAndNext", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(dataset.Dataset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDatasetCurrentAndNext indicates an expected call of GetDatasetCurrentAndNext.
+func (mr *MockDatasetClientMockRecorder) GetDatasetCurrentAndNext(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDatasetCurrentAndNext", reflect.TypeOf((*MockDatasetClient)(nil).GetDatasetCurrentAndNext), arg0, arg1, arg2, arg3, arg4)
+}
+
+// GetFullEditionsDetails mocks base method.
+func (m *MockDatasetClient) GetFullEditionsDetails(arg0 context.Context, arg1, arg2, arg3, arg4 string) ([]dataset.EditionsDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFullEditionsDetails", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]dataset.EditionsDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFullEditionsDetails indicates an expected call of GetFullEditionsDetails.
+func (mr *MockDatasetClientMockRecorder) GetFullEditionsDetails(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFullEditionsDetails", reflect.TypeOf((*MockDatasetClient)(nil).GetFullEditionsDetails), arg0, arg1, arg2, arg3, arg4)
+}
+
 // GetInstance mocks base method.
 func (m *MockDatasetClient) GetInstance(arg0 context.Context, arg1, arg2, arg3, arg4, arg5 string) (dataset.Instance, string, error) {
 	m.ctrl.T.Helper()
@@ -152,6 +182,21 @@ func (mr *MockFilesClientMockRecorder) GetFile(arg0, arg1, arg2 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockFilesClient)(nil).GetFile), arg0, arg1, arg2)
 }
 
+// GetFiles mocks base method.
+func (m *MockFilesClient) GetFiles(arg0 context.Context, arg1, arg2 string, arg3 sdk.Headers) ([]files.StoredRegisteredMetaData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFiles", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]files.StoredRegisteredMetaData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFiles indicates an expected call of GetFiles.
+func (mr *MockFilesClientMockRecorder) GetFiles(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFiles", reflect.TypeOf((*MockFilesClient)(nil).GetFiles), arg0, arg1, arg2, arg3)
+}
+
 // MockFilterClient is a mock of FilterClient interface.
 type MockFilterClient struct {
 	ctrl     *gomock.Controller
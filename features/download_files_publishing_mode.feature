@@ -31,7 +31,8 @@ Feature: Download preview feature - publishing
     When I GET "/downloads/files/data/published.csv"
     Then the HTTP status code should be "200"
     And the response header "Cache-Control" should be "no-cache"
-    And the response header "Content-Disposition" should be "attachment; filename=published.csv"
+    And the headers should be:
+      | Content-Disposition | attachment; filename="published.csv"; filename*=UTF-8''published.csv |
     And a file event with action "READ" and resource "data/published.csv" should be created by user "janedoe@example.com"
   
   Scenario: File is published and downloaded successfully (With only an access_token cookie)
@@ -62,7 +63,8 @@ Feature: Download preview feature - publishing
     When I GET "/downloads/files/data/published.csv"
     Then the HTTP status code should be "200"
     And the response header "Cache-Control" should be "no-cache"
-    And the response header "Content-Disposition" should be "attachment; filename=published.csv"
+    And the headers should be:
+      | Content-Disposition | attachment; filename="published.csv"; filename*=UTF-8''published.csv |
     And a file event with action "READ" and resource "data/published.csv" should be created by user "janedoe@example.com"
 
   Scenario: File is not uploaded and not published returns 404
@@ -109,7 +111,8 @@ Feature: Download preview feature - publishing
     When I GET "/downloads/files/data/unpublished.csv"
     Then the HTTP status code should be "200"
     And the response header "Cache-Control" should be "no-cache"
-    And the response header "Content-Disposition" should be "attachment; filename=unpublished.csv"
+    And the headers should be:
+      | Content-Disposition | attachment; filename="unpublished.csv"; filename*=UTF-8''unpublished.csv |
     And a file event with action "READ" and resource "data/unpublished.csv" should be created by user "janedoe@example.com"
 
   Scenario: File is uploaded but collection is published and file is downloaded
@@ -141,7 +144,8 @@ Feature: Download preview feature - publishing
     When I GET "/downloads/files/data/published.csv"
     Then the HTTP status code should be "200"
     And the response header "Cache-Control" should be "no-cache"
-    And the response header "Content-Disposition" should be "attachment; filename=published.csv"
+    And the headers should be:
+      | Content-Disposition | attachment; filename="published.csv"; filename*=UTF-8''published.csv |
     And a file event with action "READ" and resource "data/published.csv" should be created by user "janedoe@example.com"
 
   Scenario: An authorised viewer user requests a file that has been uploaded but not yet published
@@ -176,7 +180,8 @@ Feature: Download preview feature - publishing
         When I GET "/downloads/files/data/unpublished.csv"
         Then the HTTP status code should be "200"
         And the response header "Cache-Control" should be "no-cache"
-        And the response header "Content-Disposition" should be "attachment; filename=unpublished.csv"
+        And the headers should be:
+          | Content-Disposition | attachment; filename="unpublished.csv"; filename*=UTF-8''unpublished.csv |
         And a file event with action "READ" and resource "data/unpublished.csv" should be created by user "viewer1@ons.gov.uk"
 
     Scenario: A viewer user with no permission requests a file that has been uploaded but not yet published
@@ -101,5 +101,6 @@ Feature: Download files feature - web
     And the collection "collection-published-1234" is marked as PUBLISHED
     When I GET "/downloads/files/data/published.csv"
     Then the HTTP status code should be "200"
-    And the response header "Content-Disposition" should be "attachment; filename=published.csv"
+    And the headers should be:
+      | Content-Disposition | attachment; filename="published.csv"; filename*=UTF-8''published.csv |
     And no file event should be logged
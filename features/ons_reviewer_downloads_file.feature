@@ -32,7 +32,8 @@ Feature: Download preview feature - downloads-new endpoint (will be deprecated)
         When I GET "/downloads-new/data/unpublished.csv"
         Then the HTTP status code should be "200"
         And the response header "Cache-Control" should be "no-cache"
-        And the response header "Content-Disposition" should be "attachment; filename=unpublished.csv"
+        And the headers should be:
+          | Content-Disposition | attachment; filename="unpublished.csv"; filename*=UTF-8''unpublished.csv |
         And a file event with action "READ" and resource "data/unpublished.csv" should be created by user "janedoe@example.com"
 
     Scenario: ONS previewer requests data-file that has been uploaded but not yet published (With only an access_token cookie)
@@ -63,7 +64,8 @@ Feature: Download preview feature - downloads-new endpoint (will be deprecated)
         When I GET "/downloads-new/data/unpublished.csv"
         Then the HTTP status code should be "200"
         And the response header "Cache-Control" should be "no-cache"
-        And the response header "Content-Disposition" should be "attachment; filename=unpublished.csv"
+        And the headers should be:
+          | Content-Disposition | attachment; filename="unpublished.csv"; filename*=UTF-8''unpublished.csv |
         And a file event with action "READ" and resource "data/unpublished.csv" should be created by user "janedoe@example.com"
 
     Scenario: ONS previewer requests data-file with weird characters that has been uploaded but not yet published
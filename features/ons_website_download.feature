@@ -28,9 +28,9 @@ Feature: ONS Public Website Download files
     When I GET "/downloads-new/data/published.csv"
     Then the HTTP status code should be "200"
     And the headers should be:
-      | Content-Type        | text/csv                             |
-      | Content-Length      | 29                                   |
-      | Content-Disposition | attachment; filename=published.csv   |
+      | Content-Type        | text/csv                                                             |
+      | Content-Length      | 29                                                                   |
+      | Content-Disposition | attachment; filename="published.csv"; filename*=UTF-8''published.csv |
     And the file content should be:
       """
       mark,1
@@ -65,9 +65,9 @@ Feature: ONS Public Website Download files
     When I GET "/downloads-new/data/weird&chars#published.csv"
     Then the HTTP status code should be "200"
     And the headers should be:
-      | Content-Type        | text/csv                                       |
-      | Content-Length      | 29                                             |
-      | Content-Disposition | attachment; filename=weird&chars#published.csv |
+      | Content-Type        | text/csv                                                                                     |
+      | Content-Length      | 29                                                                                           |
+      | Content-Disposition | attachment; filename="weird&chars#published.csv"; filename*=UTF-8''weird&chars#published.csv |
     And the file content should be:
       """
       mark,1
@@ -119,6 +119,23 @@ func (e *External) S3Client(ctx context.Context, cfg *config.Config) (content.S3
 	return client, nil
 }
 
+func (e *External) ImageCache(ctx context.Context, cfg *config.Config) (content.Cache, error) {
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx,
+		awsConfig.WithRegion(cfg.AwsRegion),
+		awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("test", "test", "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create aws config: %w", err)
+	}
+
+	objects := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(localStackHost)
+		o.UsePathStyle = true
+	})
+
+	return content.NewS3Cache(objects, cfg.ImageCacheBucketName), nil
+}
+
 func (e *External) HealthCheck(c *config.Config, buildTime, gitCommit, version string) (service.HealthChecker, error) {
 	hc := healthcheck.New(healthcheck.VersionInfo{}, time.Second, time.Second)
 	return &hc, nil
@@ -3,6 +3,8 @@ package files
 import (
 	"context"
 	"errors"
+	"net/url"
+	"strings"
 
 	"github.com/ONSdigital/dp-download-service/downloads"
 	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
@@ -13,6 +15,47 @@ var (
 	ErrNilMetadata = errors.New("metadata cannot be nil")
 )
 
+// EventResource is the resource recorded in a file event. The file event has no fields for the version of the object
+// that was read or the watermark added to it, so they are recorded as query parameters of the file path, which
+// ParseEventResource reads back.
+type EventResource struct {
+	Path        string
+	VersionID   string
+	WatermarkID string
+}
+
+// String returns the resource as recorded in a file event, e.g. data/file.csv?version-id=v2&watermark=abc
+func (r EventResource) String() string {
+	query := url.Values{}
+	if r.VersionID != "" {
+		query.Set("version-id", r.VersionID)
+	}
+	if r.WatermarkID != "" {
+		query.Set("watermark", r.WatermarkID)
+	}
+
+	if len(query) == 0 {
+		return r.Path
+	}
+	return r.Path + "?" + query.Encode()
+}
+
+// ParseEventResource parses the resource recorded in a file event
+func ParseEventResource(resource string) (EventResource, error) {
+	path, rawQuery, _ := strings.Cut(resource, "?")
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return EventResource{}, err
+	}
+
+	return EventResource{
+		Path:        path,
+		VersionID:   query.Get("version-id"),
+		WatermarkID: query.Get("watermark"),
+	}, nil
+}
+
 type FileEventCreator func(ctx context.Context, event filesAPIModels.FileEvent, headers filesAPISDK.Headers) (*filesAPIModels.FileEvent, error)
 
 // CreateFileEvent returns a function that creates a file event using the provided files API client.
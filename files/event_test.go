@@ -66,3 +66,28 @@ func TestPopulateFileEvent(t *testing.T) {
 		})
 	})
 }
+
+func TestEventResource(t *testing.T) {
+	Convey("A resource with a version and watermark is recorded with them as query parameters", t, func() {
+		resource := EventResource{Path: testFilePath, VersionID: "v2", WatermarkID: "abc"}
+
+		So(resource.String(), ShouldEqual, testFilePath+"?version-id=v2&watermark=abc")
+
+		Convey("and is parsed back to the same resource", func() {
+			parsed, err := ParseEventResource(resource.String())
+
+			So(err, ShouldBeNil)
+			So(parsed, ShouldResemble, resource)
+		})
+	})
+
+	Convey("A resource with only a path is recorded as the path", t, func() {
+		resource := EventResource{Path: testFilePath}
+
+		So(resource.String(), ShouldEqual, testFilePath)
+
+		parsed, err := ParseEventResource(testFilePath)
+		So(err, ShouldBeNil)
+		So(parsed, ShouldResemble, resource)
+	})
+}
@@ -3,6 +3,7 @@ package files
 import (
 	"path/filepath"
 	"strconv"
+	"time"
 
 	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
 )
@@ -12,6 +13,8 @@ const (
 	UPLOADED  string = "UPLOADED"  // all chunks uploaded
 	PUBLISHED string = "PUBLISHED" // published - authorized for public download
 	MOVED     string = "MOVED"     // available from S3/CDN
+	WITHDRAWN string = "WITHDRAWN" // taken down after publication, e.g. because it was erroneous
+	ARCHIVED  string = "ARCHIVED"  // no longer current and taken down after publication
 )
 
 func GetFilename(m *filesAPIModels.StoredRegisteredMetaData) string {
@@ -37,3 +40,14 @@ func Moved(m *filesAPIModels.StoredRegisteredMetaData) bool {
 func Uploaded(m *filesAPIModels.StoredRegisteredMetaData) bool {
 	return m.State == UPLOADED
 }
+
+// Withdrawn reports whether a file has been taken down after publication
+func Withdrawn(m *filesAPIModels.StoredRegisteredMetaData) bool {
+	return m.State == WITHDRAWN || m.State == ARCHIVED
+}
+
+// Embargoed reports whether a file released at the release date has not been released yet. A release date may be
+// scheduled ahead of the file being marked as published.
+func Embargoed(release *time.Time, now time.Time) bool {
+	return release != nil && now.Before(*release)
+}
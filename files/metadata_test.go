@@ -2,6 +2,7 @@ package files_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-download-service/files"
 	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
@@ -27,3 +28,44 @@ func TestUnpublished(t *testing.T) {
 		assert.Equal(t, file.ExpectedUnpublished, m.State != files.PUBLISHED && m.State != files.MOVED)
 	}
 }
+
+func TestWithdrawn(t *testing.T) {
+	testFiles := []struct {
+		State             string
+		ExpectedWithdrawn bool
+	}{
+		{State: files.UPLOADED, ExpectedWithdrawn: false},
+		{State: files.PUBLISHED, ExpectedWithdrawn: false},
+		{State: files.MOVED, ExpectedWithdrawn: false},
+		{State: files.WITHDRAWN, ExpectedWithdrawn: true},
+		{State: files.ARCHIVED, ExpectedWithdrawn: true},
+	}
+
+	for _, file := range testFiles {
+		m := filesAPIModels.StoredRegisteredMetaData{
+			State: file.State,
+		}
+
+		assert.Equal(t, file.ExpectedWithdrawn, files.Withdrawn(&m), file.State)
+		assert.True(t, !file.ExpectedWithdrawn || files.Unpublished(&m), file.State)
+	}
+}
+
+func TestEmbargoed(t *testing.T) {
+	now := time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC)
+	before, after := now.Add(-time.Second), now.Add(time.Second)
+
+	releases := []struct {
+		Release           *time.Time
+		ExpectedEmbargoed bool
+	}{
+		{Release: nil, ExpectedEmbargoed: false},
+		{Release: &before, ExpectedEmbargoed: false},
+		{Release: &now, ExpectedEmbargoed: false},
+		{Release: &after, ExpectedEmbargoed: true},
+	}
+
+	for _, release := range releases {
+		assert.Equal(t, release.ExpectedEmbargoed, files.Embargoed(release.Release, now), release.Release)
+	}
+}
@@ -2,14 +2,20 @@ package files
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/ONSdigital/dp-download-service/content"
 	"github.com/ONSdigital/dp-download-service/downloads"
 	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
 	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
 )
 
 type HTTPClient interface {
@@ -23,9 +29,27 @@ var ErrInvalidAuth = errors.New("API error: status code 401")
 var ErrInternalServerError = errors.New("internal server error")
 var ErrUnknown = errors.New("an unknown error occurred")
 var ErrRequest = errors.New("an error occurred making a request to files api")
+var ErrVersionNotFound = errors.New("file version not found")
 
-type FileDownloader func(path string) (io.ReadCloser, error)
+// PrecompressedEncodings are the content encodings of the precompressed variants that may be stored alongside a file,
+// in order of preference
+var PrecompressedEncodings = []string{"br", "zstd", "gzip"}
+
+// precompressedExtensions are the extensions added to the path of a file for each precompressed variant
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"zstd": ".zst",
+	"gzip": ".gz",
+}
+
+type FileDownloader func(path, versionID string) (io.ReadCloser, error)
+type FileRangeDownloader func(path, versionID string, offset, length int64) (file io.ReadCloser, size *int64, err error)
+type FileVariantDownloader func(path string, encodings []string) (file io.ReadCloser, encoding string, size *int64, err error)
 type MetadataFetcher func(ctx context.Context, path string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error)
+type WithdrawalFetcher func(ctx context.Context, path string, headers filesAPISDK.Headers) (*Withdrawal, error)
+type FilesLister func(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error)
+type ChecksumFetcher func(ctx context.Context, metadata *filesAPIModels.StoredRegisteredMetaData) (string, error)
+type ReleaseFetcher func(ctx context.Context, metadata *filesAPIModels.StoredRegisteredMetaData) (*time.Time, error)
 type ContextKey string
 
 func FetchMetadata(filesClient downloads.FilesClient) MetadataFetcher {
@@ -34,9 +58,206 @@ func FetchMetadata(filesClient downloads.FilesClient) MetadataFetcher {
 	}
 }
 
+// FetchReleaseDate returns a fetcher for the release date of the dataset version a file belongs to, read from the
+// dataset API because the files API does not return when a file is released. The release date is nil for files that do
+// not belong to a dataset version, or whose version does not exist or has no release date.
+func FetchReleaseDate(datasetClient downloads.DatasetClient, serviceAuthToken, downloadServiceToken string) ReleaseFetcher {
+	return func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (*time.Time, error) {
+		if m.ContentItem == nil || m.ContentItem.DatasetID == "" {
+			return nil, nil
+		}
+
+		var collectionID string
+		if m.CollectionID != nil {
+			collectionID = *m.CollectionID
+		}
+
+		version, err := datasetClient.GetVersion(ctx, "", serviceAuthToken, downloadServiceToken, collectionID, m.ContentItem.DatasetID, m.ContentItem.Edition, m.ContentItem.Version)
+		if err != nil {
+			var status interface{ Code() int }
+			if errors.As(err, &status) && status.Code() == http.StatusNotFound {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to get the release date of the dataset version: %w", err)
+		}
+
+		if version.ReleaseDate == "" {
+			return nil, nil
+		}
+
+		release, err := time.Parse(time.RFC3339, version.ReleaseDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release date %q: %w", version.ReleaseDate, err)
+		}
+		return &release, nil
+	}
+}
+
+// Withdrawal links a withdrawn or archived file to a notice explaining why it was taken down and to the file replacing
+// it. Either may be empty.
+type Withdrawal struct {
+	NoticeURL       string `json:"withdrawal_notice_url"`
+	ReplacementPath string `json:"replaced_by"`
+}
+
+// FetchWithdrawal returns a function that reads the withdrawal notice and replacement of a file from its metadata in
+// the files API. The files API model does not have these fields, so they are decoded from the metadata directly.
+func FetchWithdrawal(client HTTPClient, filesAPIURL string) WithdrawalFetcher {
+	return func(ctx context.Context, path string, headers filesAPISDK.Headers) (*Withdrawal, error) {
+		var withdrawal Withdrawal
+		if err := fetchRawMetadata(ctx, client, filesAPIURL, path, headers, &withdrawal); err != nil {
+			return nil, err
+		}
+		return &withdrawal, nil
+	}
+}
+
+// FetchEncryptionKeyID returns a function that reads the ID of the key a file was encrypted with from its metadata in
+// the files API. Files that are not registered with the files API have no key ID.
+func FetchEncryptionKeyID(client HTTPClient, filesAPIURL, serviceAuthToken string) content.KeyIDFetcher {
+	return func(ctx context.Context, path string) (string, error) {
+		var encryption struct {
+			KeyID string `json:"encryption_key_id"`
+		}
+
+		err := fetchRawMetadata(ctx, client, filesAPIURL, path, filesAPISDK.Headers{Authorization: serviceAuthToken}, &encryption)
+		if errors.Is(err, ErrFileNotRegistered) {
+			return "", nil
+		}
+		return encryption.KeyID, err
+	}
+}
+
+// fetchRawMetadata decodes the metadata of a file in the files API into v, for fields the files API model does not have
+func fetchRawMetadata(ctx context.Context, client HTTPClient, filesAPIURL, path string, headers filesAPISDK.Headers, v any) error {
+	fileURL, err := url.JoinPath(filesAPIURL, "files", strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fileURL, http.NoBody)
+	if err != nil {
+		return err
+	}
+	headers.Add(req)
+
+	resp, err := client.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+	defer resp.Body.Close() // nolint
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrFileNotRegistered
+	default:
+		return fmt.Errorf("%w: files api returned status %d for %s", ErrUnknown, resp.StatusCode, path)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", ErrBadJSONResponse, err)
+	}
+	return nil
+}
+
+// ListFiles returns a function that lists the files in a collection or bundle
+func ListFiles(filesClient downloads.FilesClient) FilesLister {
+	return func(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+		return filesClient.GetFiles(ctx, collectionID, bundleID, headers)
+	}
+}
+
+// DownloadFile returns a function that downloads a file from the bucket. If a versionID is given, exactly that
+// version of the file is downloaded.
 func DownloadFile(ctx context.Context, s3client content.S3Client) FileDownloader {
-	return func(filePath string) (io.ReadCloser, error) {
-		file, _, err := s3client.Get(ctx, filePath)
-		return file, err
+	return func(filePath, versionID string) (io.ReadCloser, error) {
+		if versionID == "" {
+			file, _, err := s3client.Get(ctx, filePath)
+			return file, err
+		}
+
+		file, _, err := content.GetVersion(ctx, s3client, filePath, versionID, 0, -1)
+		return file, versionError(err)
+	}
+}
+
+// DownloadFileRange returns a function that downloads part of a file from the bucket, as for DownloadFile, along with
+// the total size of the version read
+func DownloadFileRange(ctx context.Context, s3client content.S3Client) FileRangeDownloader {
+	return func(filePath, versionID string, offset, length int64) (io.ReadCloser, *int64, error) {
+		file, size, err := content.GetVersion(ctx, s3client, filePath, versionID, offset, length)
+		return file, size, versionError(err)
+	}
+}
+
+// DownloadFileVariant returns a function that downloads the precompressed variant of a file for the first of the
+// encodings that is stored alongside it in the bucket, e.g. data/file.csv.gz for gzip. If none of the variants can be
+// read the plain file is downloaded and the returned encoding is empty.
+func DownloadFileVariant(ctx context.Context, s3client content.S3Client) FileVariantDownloader {
+	return func(filePath string, encodings []string) (io.ReadCloser, string, *int64, error) {
+		for _, encoding := range encodings {
+			ext, ok := precompressedExtensions[encoding]
+			if !ok {
+				continue
+			}
+
+			file, size, err := s3client.Get(ctx, filePath+ext)
+			if err == nil {
+				return file, encoding, size, nil
+			}
+			if !content.IsNotFound(err) {
+				log.Warn(ctx, "failed to read precompressed variant, falling back to the plain file", log.Data{"filePath": filePath, "encoding": encoding, "error": err.Error()})
+			}
+		}
+
+		file, size, err := s3client.Get(ctx, filePath)
+		return file, "", size, err
+	}
+}
+
+// FetchChecksum returns a function that gets the hex encoded SHA-256 checksum of a file. Files API does not store
+// checksums, so the checksum stored with the object in the bucket is used where there is one, and otherwise it is
+// computed and stored in the cache, which may be nil. Files that have been moved to the public bucket are read with
+// downloadMovedFile.
+func FetchChecksum(s3client content.S3Client, cache content.Cache, downloadMovedFile FileDownloader) ChecksumFetcher {
+	return func(ctx context.Context, m *filesAPIModels.StoredRegisteredMetaData) (string, error) {
+		if Moved(m) {
+			return content.CachedChecksum(ctx, cache, m.Path, func() (io.ReadCloser, error) {
+				return downloadMovedFile(m.Path, "")
+			})
+		}
+
+		return content.Checksum(ctx, s3client, cache, m.Path)
+	}
+}
+
+// DownloadMovedFile returns a function that downloads a file that has been moved to the public bucket, using
+// location to find the public URL of the file.
+func DownloadMovedFile(ctx context.Context, client HTTPClient, location func(path string) string) FileDownloader {
+	return func(filePath, _ string) (io.ReadCloser, error) {
+		req, err := http.NewRequest(http.MethodGet, location(filePath), http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRequest, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close() // nolint
+			return nil, fmt.Errorf("%w: public bucket returned status %d for %s", ErrUnknown, resp.StatusCode, filePath)
+		}
+
+		return resp.Body, nil
+	}
+}
+
+func versionError(err error) error {
+	if errors.Is(err, content.ErrVersionNotFound) {
+		return ErrVersionNotFound
 	}
+	return err
 }
@@ -3,10 +3,18 @@ package files
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
 	"github.com/ONSdigital/dp-download-service/content/mocks"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/aws/smithy-go"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/suite"
 )
@@ -31,8 +39,221 @@ func (s *RetrieverTestSuite) TestDownloadFile() {
 
 	s.s3c.EXPECT().Get(gomock.Any(), filePath).Return(fileContent, nil, nil)
 
-	file, err := DownloadFile(context.Background(), s.s3c)(filePath)
+	file, err := DownloadFile(context.Background(), s.s3c)(filePath, "")
 
 	s.NoError(err)
 	s.Equal(fileContent, file)
 }
+
+func (s *RetrieverTestSuite) TestDownloadFileRange() {
+	filePath := "data/file.csv"
+
+	s.s3c.EXPECT().Get(gomock.Any(), filePath).Return(io.NopCloser(bytes.NewBufferString("file content")), nil, nil)
+
+	file, _, err := DownloadFileRange(context.Background(), s.s3c)(filePath, "", 5, 3)
+
+	s.NoError(err)
+	b, _ := io.ReadAll(file)
+	s.Equal("con", string(b))
+}
+
+type httpClientFunc func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return f(ctx, req)
+}
+
+func (s *RetrieverTestSuite) TestDownloadMovedFile() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/public/data/file.csv" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("file content")) // nolint
+	}))
+	defer server.Close()
+
+	client := httpClientFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return http.DefaultClient.Do(req)
+	})
+	location := func(filePath string) string { return server.URL + "/public/" + filePath }
+	download := DownloadMovedFile(context.Background(), client, location)
+
+	file, err := download("data/file.csv", "")
+	s.Require().NoError(err)
+	b, _ := io.ReadAll(file)
+	s.Equal("file content", string(b))
+
+	_, err = download("data/missing.csv", "")
+	s.ErrorIs(err, ErrUnknown)
+}
+
+func (s *RetrieverTestSuite) TestFetchWithdrawal() {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/files/data/withdrawn.csv":
+			w.Write([]byte(`{"path": "data/withdrawn.csv", "state": "WITHDRAWN", "withdrawal_notice_url": "https://www.ons.gov.uk/notice", "replaced_by": "data/corrected.csv"}`)) // nolint
+		case "/files/data/archived.csv":
+			w.Write([]byte(`{"path": "data/archived.csv", "state": "ARCHIVED"}`)) // nolint
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := httpClientFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return http.DefaultClient.Do(req)
+	})
+	fetch := FetchWithdrawal(client, server.URL)
+
+	withdrawal, err := fetch(context.Background(), "data/withdrawn.csv", filesAPISDK.Headers{Authorization: "token"})
+	s.Require().NoError(err)
+	s.Equal(&Withdrawal{NoticeURL: "https://www.ons.gov.uk/notice", ReplacementPath: "data/corrected.csv"}, withdrawal)
+	s.Equal("Bearer token", authorization)
+
+	withdrawal, err = fetch(context.Background(), "/data/archived.csv", filesAPISDK.Headers{})
+	s.Require().NoError(err)
+	s.Equal(&Withdrawal{}, withdrawal)
+
+	_, err = fetch(context.Background(), "data/missing.csv", filesAPISDK.Headers{})
+	s.ErrorIs(err, ErrFileNotRegistered)
+}
+
+func (s *RetrieverTestSuite) TestFetchEncryptionKeyID() {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/files/data/encrypted.csv":
+			w.Write([]byte(`{"path": "data/encrypted.csv", "state": "UPLOADED", "encryption_key_id": "2026-02"}`)) // nolint
+		case "/files/data/plain.csv":
+			w.Write([]byte(`{"path": "data/plain.csv", "state": "UPLOADED"}`)) // nolint
+		case "/files/data/broken.csv":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := httpClientFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return http.DefaultClient.Do(req)
+	})
+	fetch := FetchEncryptionKeyID(client, server.URL, "service-token")
+
+	keyID, err := fetch(context.Background(), "data/encrypted.csv")
+	s.Require().NoError(err)
+	s.Equal("2026-02", keyID)
+	s.Equal("Bearer service-token", authorization)
+
+	keyID, err = fetch(context.Background(), "data/plain.csv")
+	s.Require().NoError(err)
+	s.Empty(keyID)
+
+	keyID, err = fetch(context.Background(), "legacy/unregistered.csv")
+	s.Require().NoError(err)
+	s.Empty(keyID)
+
+	_, err = fetch(context.Background(), "data/broken.csv")
+	s.ErrorIs(err, ErrUnknown)
+}
+
+func (s *RetrieverTestSuite) TestFetchReleaseDate() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/datasets/cpih01/editions/time-series/versions/2":
+			w.Write([]byte(`{"id": "v2", "state": "associated", "release_date": "2026-03-04T09:30:00.000Z"}`)) // nolint
+		case "/datasets/cpih01/editions/time-series/versions/3":
+			w.Write([]byte(`{"id": "v3", "state": "edition-confirmed"}`)) // nolint
+		case "/datasets/cpih01/editions/time-series/versions/4":
+			w.Write([]byte(`{"id": "v4", "state": "associated", "release_date": "next week"}`)) // nolint
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	fetch := FetchReleaseDate(dataset.NewAPIClient(server.URL), "service-token", "download-token")
+	file := func(version string) *filesAPIModels.StoredRegisteredMetaData {
+		return &filesAPIModels.StoredRegisteredMetaData{
+			Path:        "data/cpih.csv",
+			ContentItem: &filesAPIModels.StoredContentItem{DatasetID: "cpih01", Edition: "time-series", Version: version},
+		}
+	}
+
+	release, err := fetch(context.Background(), file("2"))
+	s.Require().NoError(err)
+	s.Require().NotNil(release)
+	s.Equal(time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC), release.UTC())
+
+	release, err = fetch(context.Background(), file("3"))
+	s.Require().NoError(err)
+	s.Nil(release)
+
+	release, err = fetch(context.Background(), file("5"))
+	s.Require().NoError(err)
+	s.Nil(release)
+
+	release, err = fetch(context.Background(), &filesAPIModels.StoredRegisteredMetaData{Path: "data/report.pdf"})
+	s.Require().NoError(err)
+	s.Nil(release)
+
+	_, err = fetch(context.Background(), file("4"))
+	s.Error(err)
+}
+
+func (s *RetrieverTestSuite) TestDownloadFileVariant() {
+	filePath := "data/file.csv"
+	notFound := &smithy.GenericAPIError{Code: "NoSuchKey"}
+	size := int64(7)
+
+	s.Run("serves the first variant that exists", func() {
+		s.s3c.EXPECT().Get(gomock.Any(), filePath+".br").Return(nil, nil, notFound)
+		s.s3c.EXPECT().Get(gomock.Any(), filePath+".gz").Return(io.NopCloser(bytes.NewBufferString("gzipped")), &size, nil)
+
+		file, encoding, fileSize, err := DownloadFileVariant(context.Background(), s.s3c)(filePath, []string{"br", "gzip"})
+
+		s.Require().NoError(err)
+		s.Equal("gzip", encoding)
+		s.Equal(&size, fileSize)
+		b, _ := io.ReadAll(file)
+		s.Equal("gzipped", string(b))
+	})
+
+	s.Run("falls back to the plain file", func() {
+		s.s3c.EXPECT().Get(gomock.Any(), filePath+".zst").Return(nil, nil, errors.New("access denied"))
+		s.s3c.EXPECT().Get(gomock.Any(), filePath).Return(io.NopCloser(bytes.NewBufferString("plain")), nil, nil)
+
+		file, encoding, _, err := DownloadFileVariant(context.Background(), s.s3c)(filePath, []string{"zstd", "deflate"})
+
+		s.Require().NoError(err)
+		s.Empty(encoding)
+		b, _ := io.ReadAll(file)
+		s.Equal("plain", string(b))
+	})
+}
+
+const fileContentChecksum = "e0ac3601005dfa1864f5392aabaf7d898b1b5bab854f1acb4491bcd806b76b0c"
+
+func (s *RetrieverTestSuite) TestFetchChecksum() {
+	s.s3c.EXPECT().Get(gomock.Any(), "data/file.csv").Return(io.NopCloser(bytes.NewBufferString("file content")), nil, nil)
+
+	checksum, err := FetchChecksum(s.s3c, nil, nil)(context.Background(), &filesAPIModels.StoredRegisteredMetaData{Path: "data/file.csv", State: PUBLISHED})
+
+	s.NoError(err)
+	s.Equal(fileContentChecksum, checksum)
+}
+
+func (s *RetrieverTestSuite) TestFetchChecksumOfMovedFile() {
+	downloadMovedFile := func(path, versionID string) (io.ReadCloser, error) {
+		s.Equal("data/file.csv", path)
+		return io.NopCloser(bytes.NewBufferString("file content")), nil
+	}
+
+	checksum, err := FetchChecksum(s.s3c, nil, downloadMovedFile)(context.Background(), &filesAPIModels.StoredRegisteredMetaData{Path: "data/file.csv", State: MOVED})
+
+	s.NoError(err)
+	s.Equal(fileContentChecksum, checksum)
+}
@@ -1,24 +1,36 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ONSdigital/dp-download-service/content"
+	"github.com/ONSdigital/dp-download-service/disposition"
 	"github.com/ONSdigital/dp-download-service/downloads"
+	"github.com/ONSdigital/dp-download-service/imaging"
+	"github.com/ONSdigital/dp-download-service/provenance"
+	"github.com/ONSdigital/dp-download-service/tabular"
 	dphandlers "github.com/ONSdigital/dp-net/v3/handlers"
 	"github.com/ONSdigital/dp-net/v3/request"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 )
 
-//go:generate mockgen -destination mocks/mocks.go -package mocks github.com/ONSdigital/dp-download-service/handlers Downloader,S3Content
+//go:generate mockgen -destination mocks/mocks.go -package mocks github.com/ONSdigital/dp-download-service/handlers Downloader,S3Content,ImageCache
 
 const (
 	notFoundMessage       = "resource not found"
 	internalServerMessage = "internal server error"
+
+	watermarkRequiredMessage = "pre-release copies of this file must be downloaded on their own so they can be watermarked"
 )
 
 // ClientError implements error interface with additional code method
@@ -34,22 +46,44 @@ type IdentityClient interface {
 
 // S3Content is an interface to represent methods called to action on S3
 type S3Content interface {
-	StreamAndWrite(ctx context.Context, s3Path string, w io.Writer) error
+	StreamAndWrite(ctx context.Context, s3Path string, w io.Writer, setSize func(size int64)) error
+	GetRange(ctx context.Context, s3Path string, offset, length int64) (io.ReadCloser, *int64, error)
+	Checksum(ctx context.Context, s3Path string) (string, error)
 }
 
 // Downloader is an interface to represent methods called to obtain the download metadata for any possible download type (dataset, image, etc)
 type Downloader interface {
 	Get(ctx context.Context, p downloads.Parameters, fileType downloads.FileType, variant string) (downloads.Model, error)
+	LatestVersion(ctx context.Context, p downloads.Parameters, includeUnpublished bool) (edition, version string, err error)
+}
+
+// ImageCache is an interface to represent methods called to store and retrieve images derived from an image variant
+type ImageCache interface {
+	Get(ctx context.Context, key string) (io.ReadCloser, *int64, error)
+	Put(ctx context.Context, key, contentType string, body []byte) error
 }
 
 // Download represents the configuration for a download handler
 type Download struct {
-	Downloader           Downloader
-	S3Content            S3Content
-	ServiceAuthToken     string
-	DownloadServiceToken string
-	SecretKey            string
-	IsPublishing         bool
+	Downloader                Downloader
+	S3Content                 S3Content
+	ServiceAuthToken          string
+	DownloadServiceToken      string
+	SecretKey                 string
+	IsPublishing              bool
+	PreviewMaxRows            int
+	PreviewMaxBytes           int64
+	RowGroupBytes             int64
+	ImageSizes                []int
+	ImageMaxPixels            int
+	ImageCache                ImageCache
+	LicenceURL                string
+	ProvenanceHeaders         bool
+	InlineDisposition         bool
+	Clock                     func() time.Time // the current time, time.Now if nil
+	LatestMaxAge              time.Duration    // how long redirects from the latest aliases may be cached in the web environment
+	LatestIncludesUnpublished bool             // whether the latest aliases include unpublished versions for authorised users
+	WatermarkPreRelease       bool             // whether pre-release files must be watermarked, so cannot be previewed
 }
 
 func setStatusCode(ctx context.Context, w http.ResponseWriter, err error, logData log.Data) {
@@ -72,22 +106,128 @@ func setStatusCode(ctx context.Context, w http.ResponseWriter, err error, logDat
 	http.Error(w, message, status)
 }
 
-// DoImage handles download image file requests.
+// DoImage handles download image file requests. Images are resized and converted to the width, height, fit and format
+// in the query parameters.
 func (d Download) DoImage(serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
+	return d.DoImageWith(nil, serviceAuthToken, downloadServiceToken)
+}
+
+// DoImageWith handles download image file requests as for DoImage, except that image variants that are not resized or
+// converted are handled by variant, if it is not nil.
+func (d Download) DoImageWith(variant http.HandlerFunc, serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		params := GetDownloadParameters(req, serviceAuthToken, downloadServiceToken)
+
+		opts, err := imaging.ParseOptions(req.URL.Query(), params.Filename, d.ImageSizes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if opts != nil {
+			d.image(w, req, params, *opts)
+			return
+		}
+		if variant != nil {
+			variant(w, req)
+			return
+		}
+
 		d.do(w, req, downloads.TypeImage, params, params.Variant)
 	}
 }
 
-// DoDatasetVersion handles dataset version file download requests.
+// DoDatasetVersion handles dataset version file download requests. CSV downloads are converted to the format requested
+// with the format query parameter or Accept header, and filtered with the columns and filter query parameters.
 func (d Download) DoDatasetVersion(extension, serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		params := GetDownloadParameters(req, serviceAuthToken, downloadServiceToken)
+
+		if extension == "csv" {
+			format, err := tabular.NegotiateFormat(req.URL.Query().Get("format"), req.Header.Get("Accept"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			filter, err := tabular.ParseFilter(req.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept")
+			if format != tabular.FormatCSV || filter != nil {
+				d.convert(w, req, downloads.TypeDatasetVersion, params, format, filter)
+				return
+			}
+		}
+
 		d.do(w, req, downloads.TypeDatasetVersion, params, extension)
 	}
 }
 
+// RedirectLatest redirects requests for the latest edition or version of a dataset to the same route for the
+// dataset version they resolve to, handling any other request with next. Redirects may be cached for a short time in
+// the web environment, but not in the publishing environment where they depend on who is asking.
+func (d Download) RedirectLatest(next http.HandlerFunc, serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := GetDownloadParameters(req, serviceAuthToken, downloadServiceToken)
+		if params.Edition != downloads.Latest && params.Version != downloads.Latest {
+			next(w, req)
+			return
+		}
+
+		ctx := req.Context()
+		logData := downloadParametersToLogData(params)
+		authorised, logData := d.authenticate(req, logData)
+
+		edition, version, err := d.Downloader.LatestVersion(ctx, params, authorised && d.LatestIncludesUnpublished)
+		if errors.Is(err, downloads.ErrLatestVersionNotFound) {
+			log.Info(ctx, "no latest version found", logData)
+			http.Error(w, notFoundMessage, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			setStatusCode(ctx, w, fmt.Errorf("failed to get latest version: %w", err), logData)
+			return
+		}
+
+		location, err := mux.CurrentRoute(req).URL("datasetID", params.DatasetID, "edition", edition, "version", version)
+		if err != nil {
+			setStatusCode(ctx, w, fmt.Errorf("failed to build latest version url: %w", err), logData)
+			return
+		}
+		location.RawQuery = req.URL.RawQuery
+
+		if d.IsPublishing {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", "max-age="+strconv.FormatInt(int64(d.LatestMaxAge/time.Second), 10))
+		}
+
+		logData["location"] = location.String()
+		log.Info(ctx, "redirecting to latest version", logData)
+		http.Redirect(w, req, location.String(), http.StatusFound)
+	}
+}
+
+// DoDatasetVersionPreview handles requests for the first rows of a dataset version CSV file as JSON.
+func (d Download) DoDatasetVersionPreview(serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := GetDownloadParameters(req, serviceAuthToken, downloadServiceToken)
+		d.preview(w, req, downloads.TypeDatasetVersion, params, "csv")
+	}
+}
+
+// DoDatasetVersionChecksum handles requests for the SHA-256 checksum of a dataset version file, written in the format
+// of sha256sum.
+func (d Download) DoDatasetVersionChecksum(extension, serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := GetDownloadParameters(req, serviceAuthToken, downloadServiceToken)
+		d.checksum(w, req, downloads.TypeDatasetVersion, params, extension)
+	}
+}
+
 // DoFilterOutput handles filter outpout download requests.
 func (d Download) DoFilterOutput(extension, serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
@@ -96,6 +236,29 @@ func (d Download) DoFilterOutput(extension, serviceAuthToken, downloadServiceTok
 	}
 }
 
+// DoInstance handles download requests for the files generated for an instance before it becomes a dataset version.
+func (d Download) DoInstance(extension, serviceAuthToken, downloadServiceToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := GetDownloadParameters(req, serviceAuthToken, downloadServiceToken)
+		d.do(w, req, downloads.TypeInstance, params, extension)
+	}
+}
+
+// Resolve returns a function resolving requests to the download of the file type and extension, for routes handled in
+// the same way as files registered with the files API. Image downloads are resolved to the variant in the route.
+func (d Download) Resolve(fileType downloads.FileType, extension, serviceAuthToken, downloadServiceToken string) func(req *http.Request) (downloads.Model, error) {
+	return func(req *http.Request) (downloads.Model, error) {
+		params := GetDownloadParameters(req, serviceAuthToken, downloadServiceToken)
+
+		variant := extension
+		if fileType == downloads.TypeImage {
+			variant = params.Variant
+		}
+
+		return d.Downloader.Get(req.Context(), params, fileType, variant)
+	}
+}
+
 // do handles download requests for any possible provided file type. If the object is published and a public download link is available then
 // the request is redirected to the existing public link.
 // If the object is published but a public link does not exist then the requested file is streamed from the content
@@ -112,6 +275,9 @@ func (d Download) do(w http.ResponseWriter, req *http.Request, fileType download
 	}
 
 	logData["published"] = fileDownloads.IsPublished
+	if d.embargoed(ctx, w, fileDownloads, logData) {
+		return
+	}
 	log.Info(req.Context(), "attempting to get download", logData)
 
 	authorised, logData := d.authenticate(req, logData)
@@ -129,10 +295,17 @@ func (d Download) do(w http.ResponseWriter, req *http.Request, fileType download
 		logData["private_s3_path"] = s3Path
 		logData["private_filename"] = filename
 		log.Info(req.Context(), "using private link", logData)
-		w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+		contentType := fileDownloads.ContentType()
+		dispositionType := disposition.Type(req, contentType, d.InlineDisposition)
+		w.Header().Set("Content-Disposition", disposition.Header(dispositionType, filename))
 
 		if fileDownloads.IsPublished || authorised {
-			err = d.S3Content.StreamAndWrite(ctx, s3Path, w)
+			w.Header().Set("Content-Type", contentType)
+			d.provenanceHeaders(req, fileDownloads).Set(w.Header())
+
+			err = d.S3Content.StreamAndWrite(ctx, s3Path, w, func(size int64) {
+				w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			})
 			if err != nil {
 				setStatusCode(ctx, w, fmt.Errorf("failed to stream response: %w", err), logData)
 				return
@@ -147,6 +320,386 @@ func (d Download) do(w http.ResponseWriter, req *http.Request, fileType download
 	http.Error(w, notFoundMessage, http.StatusNotFound)
 }
 
+// embargoed answers not found in the web environment for downloads that have not been released yet, even if they are
+// published, returning false for any other download. CDNs may cache the response until the release date but no later.
+func (d Download) embargoed(ctx context.Context, w http.ResponseWriter, m downloads.Model, logData log.Data) bool {
+	if d.IsPublishing {
+		return false
+	}
+
+	now := time.Now()
+	if d.Clock != nil {
+		now = d.Clock()
+	}
+
+	release, embargoed := m.Embargoed(now)
+	if !embargoed {
+		return false
+	}
+
+	logData["release_date"] = m.ReleaseDate
+	log.Info(ctx, "download is embargoed until its release date", logData)
+	w.Header().Set("Cache-Control", "max-age="+strconv.FormatInt(int64(release.Sub(now)/time.Second), 10))
+	w.Header().Set("Expires", release.UTC().Format(http.TimeFormat))
+	http.Error(w, notFoundMessage, http.StatusNotFound)
+	return true
+}
+
+// provenanceHeaders returns the licence of a download, the CSVW download describing it if it is a CSV download with
+// one, and where it came from if custom provenance headers are enabled. The CSVW download is at the same path as the
+// CSV download with the csv-metadata.json extension.
+func (d Download) provenanceHeaders(req *http.Request, m downloads.Model) provenance.Headers {
+	prov := provenance.Headers{LicenceURL: d.LicenceURL}
+
+	if m.HasCSVW && strings.HasSuffix(req.URL.Path, ".csv") {
+		prov.DescribedBy = req.URL.Path + "-metadata.json"
+	}
+
+	if d.ProvenanceHeaders {
+		prov.ReleaseDate = m.ReleaseDate
+		prov.DatasetID = m.DatasetID
+		prov.Edition = m.Edition
+		prov.Version = m.Version
+	}
+
+	return prov
+}
+
+// preview writes the header and first rows of a CSV download as JSON. Only the start of the file is read from the
+// private S3 path, so previews are available to the same requests that can stream the private file and a public link
+// is never followed.
+func (d Download) preview(w http.ResponseWriter, req *http.Request, fileType downloads.FileType, params downloads.Parameters, variant string) {
+	ctx := req.Context()
+	logData := downloadParametersToLogData(params)
+
+	rows, err := tabular.ParseRows(req.URL.Query().Get("rows"), d.PreviewMaxRows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fileDownloads, err := d.Downloader.Get(ctx, params, fileType, variant)
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to get download: %w", err), logData)
+		return
+	}
+
+	logData["published"] = fileDownloads.IsPublished
+	if d.embargoed(ctx, w, fileDownloads, logData) {
+		return
+	}
+
+	authorised, logData := d.authenticate(req, logData)
+	logData["authorised"] = authorised
+
+	if fileDownloads.PrivateS3Path == "" || !(fileDownloads.IsPublished || authorised) {
+		log.Error(ctx, "no private link found for preview", errors.New("no private link found for preview"), logData)
+		http.Error(w, notFoundMessage, http.StatusNotFound)
+		return
+	}
+
+	if d.WatermarkPreRelease && !fileDownloads.IsPublished {
+		// A preview has nowhere to put the watermark that pre-release copies must carry
+		log.Info(ctx, "pre-release file cannot be watermarked in a preview", logData)
+		http.Error(w, watermarkRequiredMessage, http.StatusForbidden)
+		return
+	}
+
+	logData["private_s3_path"] = fileDownloads.PrivateS3Path
+	log.Info(ctx, "previewing private link", logData)
+
+	file, size, err := d.S3Content.GetRange(ctx, fileDownloads.PrivateS3Path, 0, d.PreviewMaxBytes)
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to get preview: %w", err), logData)
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Error(ctx, "error closing preview", err, logData)
+		}
+	}()
+
+	var sizeInBytes int64
+	if size != nil {
+		sizeInBytes = *size
+	}
+
+	preview, err := tabular.ReadPreview(file, sizeInBytes, rows, d.PreviewMaxBytes)
+	if errors.Is(err, tabular.ErrInvalidCSV) || errors.Is(err, tabular.ErrHeaderTooLarge) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to read preview: %w", err), logData)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(preview); err != nil {
+		log.Error(ctx, "failed to write preview", err, logData)
+		return
+	}
+
+	log.Info(ctx, "preview successfully written to response", logData)
+}
+
+// convert streams a CSV download converted to another format or filtered. As with previews, the file is read from the
+// private S3 path and a public link is never followed. Column types are read from the CSVW download of the same
+// resource, if there is one.
+func (d Download) convert(w http.ResponseWriter, req *http.Request, fileType downloads.FileType, params downloads.Parameters, format tabular.Format, filter *tabular.Filter) {
+	ctx := req.Context()
+	logData := downloadParametersToLogData(params)
+	logData["format"] = format
+
+	fileDownloads, err := d.Downloader.Get(ctx, params, fileType, "csv")
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to get download: %w", err), logData)
+		return
+	}
+
+	logData["published"] = fileDownloads.IsPublished
+	if d.embargoed(ctx, w, fileDownloads, logData) {
+		return
+	}
+
+	authorised, logData := d.authenticate(req, logData)
+	logData["authorised"] = authorised
+
+	if fileDownloads.PrivateS3Path == "" || !(fileDownloads.IsPublished || authorised) {
+		log.Error(ctx, "no private link found for conversion", errors.New("no private link found for conversion"), logData)
+		http.Error(w, notFoundMessage, http.StatusNotFound)
+		return
+	}
+
+	logData["private_s3_path"] = fileDownloads.PrivateS3Path
+	log.Info(ctx, "converting private link", logData)
+
+	file, _, err := d.S3Content.GetRange(ctx, fileDownloads.PrivateS3Path, 0, -1)
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to stream response: %w", err), logData)
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Error(ctx, "error closing converted file", err, logData)
+		}
+	}()
+
+	var source io.Reader = file
+	filename := fileDownloads.PrivateFilename
+	if filter != nil {
+		logData["filter"] = filter
+		if source, err = filter.Reader(file); err != nil {
+			log.Error(ctx, "failed to filter download", err, logData)
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, tabular.ErrUnknownColumn) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		filename = tabular.FilteredFilename(filename)
+	}
+
+	var schema tabular.Schema
+	if format != tabular.FormatCSV {
+		schema = d.schema(ctx, fileType, params, logData)
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", disposition.Header(disposition.Type(req, format.ContentType(), d.InlineDisposition), format.Filename(filename)))
+
+	if err = tabular.Convert(w, source, format, schema, d.RowGroupBytes); err != nil {
+		log.Error(ctx, "failed to convert download", err, logData)
+		return
+	}
+
+	log.Info(ctx, "converted download successfully written to response", logData)
+}
+
+// checksum writes the SHA-256 checksum of a download and its filename as a line of sha256sum output. As with previews,
+// the file is read from the private S3 path and a public link is never followed.
+func (d Download) checksum(w http.ResponseWriter, req *http.Request, fileType downloads.FileType, params downloads.Parameters, variant string) {
+	ctx := req.Context()
+	logData := downloadParametersToLogData(params)
+
+	fileDownloads, err := d.Downloader.Get(ctx, params, fileType, variant)
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to get download: %w", err), logData)
+		return
+	}
+
+	logData["published"] = fileDownloads.IsPublished
+	if d.embargoed(ctx, w, fileDownloads, logData) {
+		return
+	}
+
+	authorised, logData := d.authenticate(req, logData)
+	logData["authorised"] = authorised
+
+	if fileDownloads.PrivateS3Path == "" || !(fileDownloads.IsPublished || authorised) {
+		log.Error(ctx, "no private link found for checksum", errors.New("no private link found for checksum"), logData)
+		http.Error(w, notFoundMessage, http.StatusNotFound)
+		return
+	}
+
+	logData["private_s3_path"] = fileDownloads.PrivateS3Path
+
+	sum, err := d.S3Content.Checksum(ctx, fileDownloads.PrivateS3Path)
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to get checksum: %w", err), logData)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err = fmt.Fprintf(w, "%s  %s\n", sum, fileDownloads.PrivateFilename); err != nil {
+		log.Error(ctx, "failed to write checksum", err, logData)
+		return
+	}
+
+	log.Info(ctx, "checksum successfully written to response", logData)
+}
+
+// image streams an image variant resized and converted as described by opts. As with conversions, the variant is read
+// from the private S3 path and a public link is never followed. Derived images are stored in the image cache, if there
+// is one, under a key derived from the variant's path, its entity tag and the options, so each is only generated once
+// for each version of the variant. Variants whose version is not known are not cached.
+func (d Download) image(w http.ResponseWriter, req *http.Request, params downloads.Parameters, opts imaging.Options) {
+	ctx := req.Context()
+	logData := downloadParametersToLogData(params)
+	logData["image_options"] = opts
+
+	fileDownloads, err := d.Downloader.Get(ctx, params, downloads.TypeImage, params.Variant)
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to get download: %w", err), logData)
+		return
+	}
+
+	logData["published"] = fileDownloads.IsPublished
+	if d.embargoed(ctx, w, fileDownloads, logData) {
+		return
+	}
+
+	authorised, logData := d.authenticate(req, logData)
+	logData["authorised"] = authorised
+
+	if fileDownloads.PrivateS3Path == "" || !(fileDownloads.IsPublished || authorised) {
+		log.Error(ctx, "no private link found for image", errors.New("no private link found for image"), logData)
+		http.Error(w, notFoundMessage, http.StatusNotFound)
+		return
+	}
+
+	filename := opts.Format.Filename(fileDownloads.PrivateFilename)
+	dispositionType := disposition.Type(req, opts.Format.ContentType(), d.InlineDisposition)
+
+	// The variant is opened before the cache is checked, as the cache key depends on the version read
+	file, _, err := d.S3Content.GetRange(ctx, fileDownloads.PrivateS3Path, 0, -1)
+	if err != nil {
+		setStatusCode(ctx, w, fmt.Errorf("failed to stream response: %w", err), logData)
+		return
+	}
+	defer closeAndLog(ctx, file, logData)
+
+	cache, key := d.ImageCache, ""
+	if version := objectVersion(file); version != "" {
+		key = opts.Key(fileDownloads.PrivateS3Path, version)
+		logData["image_key"] = key
+	} else {
+		cache = nil
+	}
+
+	if cache != nil {
+		cached, size, err := cache.Get(ctx, key)
+		if err == nil {
+			defer closeAndLog(ctx, cached, logData)
+
+			writeImageHeaders(w, opts.Format, dispositionType, filename, size)
+			if _, err = io.Copy(w, cached); err != nil {
+				log.Error(ctx, "failed to write cached image", err, logData)
+				return
+			}
+
+			log.Info(ctx, "cached image successfully written to response", logData)
+			return
+		}
+		log.Info(ctx, "image not found in cache", logData)
+	}
+
+	var b bytes.Buffer
+	if err = imaging.Transform(&b, file, opts, d.ImageMaxPixels); err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedImage) || errors.Is(err, imaging.ErrImageTooLarge) {
+			log.Error(ctx, "image cannot be transformed", err, logData)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		setStatusCode(ctx, w, fmt.Errorf("failed to transform image: %w", err), logData)
+		return
+	}
+
+	if cache != nil {
+		if err = cache.Put(ctx, key, opts.Format.ContentType(), b.Bytes()); err != nil {
+			log.Warn(ctx, "failed to cache image", log.Data{"image_key": key, "error": err.Error()})
+		}
+	}
+
+	size := int64(b.Len())
+	writeImageHeaders(w, opts.Format, dispositionType, filename, &size)
+	if _, err = w.Write(b.Bytes()); err != nil {
+		log.Error(ctx, "failed to write image", err, logData)
+		return
+	}
+
+	log.Info(ctx, "image successfully written to response", logData)
+}
+
+// objectVersion returns the entity tag of the object the file was read from, or its version ID if the bucket did not
+// return an entity tag
+func objectVersion(file io.ReadCloser) string {
+	info, _ := content.ObjectOf(file)
+	if info.ETag != "" {
+		return info.ETag
+	}
+	return info.VersionID
+}
+
+func writeImageHeaders(w http.ResponseWriter, format imaging.Format, dispositionType, filename string, size *int64) {
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", disposition.Header(dispositionType, filename))
+	if size != nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(*size, 10))
+	}
+}
+
+func closeAndLog(ctx context.Context, c io.Closer, logData log.Data) {
+	if err := c.Close(); err != nil {
+		log.Error(ctx, "error closing file", err, logData)
+	}
+}
+
+// schema reads the column types from the CSVW download of a resource, returning nil if there is none
+func (d Download) schema(ctx context.Context, fileType downloads.FileType, params downloads.Parameters, logData log.Data) tabular.Schema {
+	csvw, err := d.Downloader.Get(ctx, params, fileType, "csvw")
+	if err != nil || csvw.PrivateS3Path == "" {
+		log.Info(ctx, "no csvw metadata found, converting columns as strings", logData)
+		return nil
+	}
+
+	file, _, err := d.S3Content.GetRange(ctx, csvw.PrivateS3Path, 0, -1)
+	if err != nil {
+		log.Warn(ctx, "failed to read csvw metadata, converting columns as strings", logData)
+		return nil
+	}
+	defer file.Close() // nolint
+
+	schema, err := tabular.ParseCSVW(file)
+	if err != nil {
+		log.Warn(ctx, "invalid csvw metadata, converting columns as strings", logData)
+		return nil
+	}
+
+	return schema
+}
+
 // GetDownloadParameters extracts the query parameters and context values for the provided request,
 // then returns a struct with all the available parameters, including the explicitly provided service and downloadService tokens
 func GetDownloadParameters(req *http.Request, serviceAuthToken, downloadServiceToken string) downloads.Parameters {
@@ -1,19 +1,26 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/health"
 	clientsidentity "github.com/ONSdigital/dp-api-clients-go/v2/identity"
+	"github.com/ONSdigital/dp-download-service/content"
 	"github.com/ONSdigital/dp-download-service/downloads"
 	"github.com/ONSdigital/dp-download-service/handlers/mocks"
+	"github.com/ONSdigital/dp-download-service/provenance"
 	dphandlers "github.com/ONSdigital/dp-net/v3/handlers"
 	dphttp "github.com/ONSdigital/dp-net/v3/http"
 	"github.com/ONSdigital/dp-net/v3/request"
@@ -232,7 +239,9 @@ func TestDownloadDoReturnsOK(t *testing.T) {
 		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv", d.DoDatasetVersion("csv", mockServiceAuthToken, mockDownloadToken))
 		r.ServeHTTP(w, req)
 
-		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=my-dataset.csv")
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=\"my-dataset.csv\"; filename*=UTF-8''my-dataset.csv")
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv")
+		So(w.Header().Get("Content-Length"), ShouldEqual, strconv.Itoa(len(testCsvContent)))
 		So(w.Code, ShouldEqual, http.StatusOK)
 		So(w.Body.Bytes(), ShouldResemble, testCsvContent)
 	})
@@ -276,11 +285,54 @@ func TestDownloadDoReturnsOK(t *testing.T) {
 
 		chain.ServeHTTP(w, req)
 
-		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=my-dataset.csv")
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=\"my-dataset.csv\"; filename*=UTF-8''my-dataset.csv")
 		So(w.Code, ShouldEqual, http.StatusOK)
 		So(w.Body.Bytes(), ShouldResemble, testCsvContent)
 	})
 
+	Convey("Given a private link to the instance download exists and user is authenticated then the file is streamed in the response body", t, func() {
+		req := httptest.NewRequest("GET", "http://localhost:28000/downloads/instances/abcde.csv", http.NoBody)
+		req = req.WithContext(context.WithValue(req.Context(), request.CallerIdentityKey, "me"))
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		params := downloads.Parameters{InstanceID: "abcde"}
+
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeInstance, "csv").Return(unpublishedDatasetDownloadPrivateLink, nil)
+		s3C := s3ContentWriterSuccessfullyWritesToResponse(mockCtrl, w, testPrivateCsvS3Path, testCsvContent)
+
+		d := Download{
+			Downloader:   dl,
+			S3Content:    s3C,
+			IsPublishing: true,
+		}
+
+		httpClient := &dphttp.ClienterMock{
+			DoFunc: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(`{"identifier": "me"}`)),
+				}, nil
+			},
+			SetPathsWithNoRetriesFunc: func(in1 []string) {},
+			GetPathsWithNoRetriesFunc: func() []string { return []string{"/healthcheck"} },
+		}
+		hc := health.Client{Client: httpClient}
+		idClient := clientsidentity.NewWithHealthClient(&hc)
+
+		chain := alice.New(dphandlers.IdentityWithHTTPClient(idClient)).Then(r)
+
+		r.HandleFunc("/downloads/instances/{instanceID}.csv", d.DoInstance("csv", mockServiceAuthToken, mockDownloadToken))
+		req.Header.Set(florenceTokenHeader, "Florence")
+
+		chain.ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv")
+		So(w.Body.Bytes(), ShouldResemble, testCsvContent)
+	})
+
 	Convey("Given a private link to the image download exists and the image is published then the file content is written to the response body", t, func() {
 		req := httptest.NewRequest("GET", "http://localhost:28000/images/54321/1280x720/myImage.png", http.NoBody)
 		req = req.WithContext(context.WithValue(req.Context(), dphandlers.UserAccess.Context(), testUserToken))
@@ -301,6 +353,8 @@ func TestDownloadDoReturnsOK(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "image/png")
+		So(w.Header().Get("Content-Length"), ShouldEqual, strconv.Itoa(len(testImageContent)))
 		So(w.Body.Bytes(), ShouldResemble, testImageContent)
 	})
 
@@ -349,6 +403,134 @@ func TestDownloadDoReturnsOK(t *testing.T) {
 	})
 }
 
+func TestDownloadProvenanceHeaders(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	params := downloads.Parameters{DatasetID: "12345", Edition: "6789", Version: "1"}
+	described := publishedDatasetDownloadPrivateURL
+	described.HasCSVW = true
+	described.ReleaseDate = "2026-03-04T09:30:00.000Z"
+	described.DatasetID, described.Edition, described.Version = "12345", "6789", "1"
+
+	serve := func(d Download) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv", http.NoBody)
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		d.S3Content = s3ContentWriterSuccessfullyWritesToResponse(mockCtrl, w, testPrivateCsvS3Path, testCsvContent)
+		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv", d.DoDatasetVersion("csv", "", ""))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("Given a dataset version with CSVW metadata then the licence and metadata are linked", t, func() {
+		w := serve(Download{
+			Downloader: downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, described),
+			LicenceURL: "https://example.com/licence",
+		})
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Values("Link"), ShouldResemble, []string{
+			`<https://example.com/licence>; rel="license"`,
+			`</downloads/datasets/12345/editions/6789/versions/1.csv-metadata.json>; rel="describedby"; type="application/csvm+json"`,
+		})
+		So(w.Header().Get(provenance.DatasetIDHeader), ShouldBeEmpty)
+	})
+
+	Convey("Given custom provenance headers are enabled then the release date and dataset version are set", t, func() {
+		w := serve(Download{
+			Downloader:        downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, described),
+			ProvenanceHeaders: true,
+		})
+
+		So(w.Header().Get(provenance.ReleaseDateHeader), ShouldEqual, "2026-03-04T09:30:00.000Z")
+		So(w.Header().Get(provenance.DatasetIDHeader), ShouldEqual, "12345")
+		So(w.Header().Get(provenance.DatasetEditionHeader), ShouldEqual, "6789")
+		So(w.Header().Get(provenance.DatasetVersionHeader), ShouldEqual, "1")
+	})
+}
+
+func TestDownloadEmbargo(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	params := downloads.Parameters{DatasetID: "12345", Edition: "6789", Version: "1"}
+	scheduled := publishedDatasetDownloadPrivateURL
+	scheduled.ReleaseDate = "2026-03-04T09:30:00.000Z"
+	release := time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC)
+
+	serve := func(d Download, w *httptest.ResponseRecorder, s3C S3Content, clock time.Time) {
+		req := httptest.NewRequest("GET", "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv", http.NoBody)
+		r := mux.NewRouter()
+
+		d.Downloader = downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, scheduled)
+		d.S3Content = s3C
+		d.Clock = func() time.Time { return clock }
+		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv", d.DoDatasetVersion("csv", "", ""))
+		r.ServeHTTP(w, req)
+	}
+
+	Convey("Given a published dataset version before its release date then it is not found until the release date", t, func() {
+		w := httptest.NewRecorder()
+		serve(Download{}, w, mocks.NewMockS3Content(mockCtrl), release.Add(-time.Hour))
+
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+		So(w.Header().Get("Cache-Control"), ShouldEqual, "max-age=3600")
+		So(w.Header().Get("Expires"), ShouldEqual, "Wed, 04 Mar 2026 09:30:00 GMT")
+	})
+
+	Convey("Given a published dataset version after its release date then the file is streamed", t, func() {
+		w := httptest.NewRecorder()
+		serve(Download{}, w, s3ContentWriterSuccessfullyWritesToResponse(mockCtrl, w, testPrivateCsvS3Path, testCsvContent), release)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("Given the publishing environment then the dataset version is streamed before its release date", t, func() {
+		w := httptest.NewRecorder()
+		serve(Download{IsPublishing: true}, w, s3ContentWriterSuccessfullyWritesToResponse(mockCtrl, w, testPrivateCsvS3Path, testCsvContent), release.Add(-time.Hour))
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+}
+
+func TestDownloadDisposition(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	params := downloads.Parameters{ImageID: "54321", Variant: "1280x720", Filename: "myImage.png"}
+
+	serve := func(d Download, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://localhost:28000/images/54321/1280x720/myImage.png"+query, http.NoBody)
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		d.Downloader = downloaderReturnsResult(mockCtrl, params, downloads.TypeImage, publishedImageDownloadPrivateURL)
+		d.S3Content = s3ContentWriterSuccessfullyWritesToResponse(mockCtrl, w, testPrivatePngPath, testImageContent)
+		r.HandleFunc("/images/{imageID}/{variant}/{filename}", d.DoImage("", ""))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("Given an image is requested inline then it is displayed inline", t, func() {
+		w := serve(Download{}, "?disposition=inline")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "inline; filename=\"my-image.png\"; filename*=UTF-8''my-image.png")
+	})
+
+	Convey("Given inline display is the default then an attachment can still be requested", t, func() {
+		w := serve(Download{InlineDisposition: true}, "?disposition=attachment")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=\"my-image.png\"; filename*=UTF-8''my-image.png")
+	})
+}
+
 func TestDownloadDoFailureScenarios(t *testing.T) {
 	t.Parallel()
 	mockCtrl := gomock.NewController(t)
@@ -535,6 +717,348 @@ func TestDownloadDoFailureScenarios(t *testing.T) {
 	})
 }
 
+func TestDownloadRedirectLatest(t *testing.T) {
+	t.Parallel()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	serve := func(d Download, target string, authorised bool) *httptest.ResponseRecorder {
+		next := func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}
+
+		r := mux.NewRouter()
+		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv", d.RedirectLatest(next, "", ""))
+
+		req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		if authorised {
+			req = req.WithContext(context.WithValue(req.Context(), request.CallerIdentityKey, "me"))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("Given the latest version in the web environment then it is redirected to the latest published version for a short time", t, func() {
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().LatestVersion(gomock.Any(), downloads.Parameters{DatasetID: "cpih01", Edition: "latest", Version: "latest"}, false).Return("time-series", "6", nil)
+
+		w := serve(Download{Downloader: dl, LatestMaxAge: time.Minute}, "/downloads/datasets/cpih01/editions/latest/versions/latest.csv?format=json", false)
+
+		So(w.Code, ShouldEqual, http.StatusFound)
+		So(w.Header().Get("Location"), ShouldEqual, "/downloads/datasets/cpih01/editions/time-series/versions/6.csv?format=json")
+		So(w.Header().Get("Cache-Control"), ShouldEqual, "max-age=60")
+	})
+
+	Convey("Given the latest version in the publishing environment then unpublished versions are included for authorised users", t, func() {
+		p := downloads.Parameters{DatasetID: "cpih01", Edition: "time-series", Version: "latest"}
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().LatestVersion(gomock.Any(), p, true).Return("time-series", "7", nil)
+		dl.EXPECT().LatestVersion(gomock.Any(), p, false).Return("time-series", "6", nil)
+
+		d := Download{Downloader: dl, IsPublishing: true, LatestIncludesUnpublished: true}
+
+		w := serve(d, "/downloads/datasets/cpih01/editions/time-series/versions/latest.csv", true)
+		So(w.Code, ShouldEqual, http.StatusFound)
+		So(w.Header().Get("Location"), ShouldEqual, "/downloads/datasets/cpih01/editions/time-series/versions/7.csv")
+		So(w.Header().Get("Cache-Control"), ShouldEqual, "no-cache")
+
+		w = serve(d, "/downloads/datasets/cpih01/editions/time-series/versions/latest.csv", false)
+		So(w.Header().Get("Location"), ShouldEqual, "/downloads/datasets/cpih01/editions/time-series/versions/6.csv")
+	})
+
+	Convey("Given a dataset without a latest version then it is not found", t, func() {
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().LatestVersion(gomock.Any(), gomock.Any(), false).Return("", "", downloads.ErrLatestVersionNotFound)
+
+		w := serve(Download{Downloader: dl}, "/downloads/datasets/cpih01/editions/latest/versions/latest.csv", false)
+
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("Given the dataset API does not find the dataset then it is not found", t, func() {
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().LatestVersion(gomock.Any(), gomock.Any(), false).Return("", "", testClientError{http.StatusNotFound})
+
+		w := serve(Download{Downloader: dl}, "/downloads/datasets/cpih01/editions/latest/versions/latest.csv", false)
+
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("Given an exact version then the request is handled as a dataset version download", t, func() {
+		w := serve(Download{Downloader: mocks.NewMockDownloader(mockCtrl)}, "/downloads/datasets/cpih01/editions/time-series/versions/6.csv", false)
+
+		So(w.Code, ShouldEqual, http.StatusTeapot)
+	})
+}
+
+func TestDownloadPreview(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	csv := "geography,value\nK02000001,1\nE92000001,2\n"
+	params := downloads.Parameters{DatasetID: "12345", Edition: "6789", Version: "1"}
+	target := "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv/preview"
+
+	servePreview := func(d Download, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, http.NoBody)
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv/preview", d.DoDatasetVersionPreview("", ""))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("Given a published dataset version with a private link then the first rows are returned as JSON", t, func() {
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		size := int64(len(csv))
+		s3C.EXPECT().
+			GetRange(gomock.Any(), testPrivateCsvS3Path, int64(0), int64(1024)).
+			Return(io.NopCloser(strings.NewReader(csv)), &size, nil)
+
+		d := Download{
+			Downloader:      downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, publishedDatasetDownloadPrivateURL),
+			S3Content:       s3C,
+			PreviewMaxRows:  10,
+			PreviewMaxBytes: 1024,
+		}
+
+		w := servePreview(d, target+"?rows=1")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(w.Body.String(), ShouldEqual, `{"header":["geography","value"],"rows":[["K02000001","1"]],"truncated":true,"size_in_bytes":40}`+"\n")
+	})
+
+	Convey("Given an unpublished dataset version and an unauthenticated user then the preview is not found", t, func() {
+		d := Download{
+			Downloader:      downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, unpublishedDatasetDownloadPrivateLink),
+			S3Content:       mocks.NewMockS3Content(mockCtrl),
+			PreviewMaxRows:  10,
+			PreviewMaxBytes: 1024,
+		}
+
+		w := servePreview(d, target)
+
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("Given an unpublished dataset version that must be watermarked then an authorised user cannot preview it", t, func() {
+		d := Download{
+			Downloader:          downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, unpublishedDatasetDownloadPrivateLink),
+			S3Content:           mocks.NewMockS3Content(mockCtrl),
+			IsPublishing:        true,
+			WatermarkPreRelease: true,
+			PreviewMaxRows:      10,
+			PreviewMaxBytes:     1024,
+		}
+
+		req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+		req = req.WithContext(context.WithValue(req.Context(), request.CallerIdentityKey, "me"))
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv/preview", d.DoDatasetVersionPreview("", ""))
+		r.ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("Given an invalid rows parameter then the request is rejected", t, func() {
+		d := Download{
+			Downloader:     mocks.NewMockDownloader(mockCtrl),
+			S3Content:      mocks.NewMockS3Content(mockCtrl),
+			PreviewMaxRows: 10,
+		}
+
+		w := servePreview(d, target+"?rows=0")
+
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	sum := "9c70933aff6b2a6d08c687a6cbb6b765c8bb6a9d5b6a7a0b6c9e3e0a6f2b5c1d"
+	params := downloads.Parameters{DatasetID: "12345", Edition: "6789", Version: "1"}
+	target := "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv.sha256"
+
+	serveChecksum := func(d Download, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, http.NoBody)
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv.sha256", d.DoDatasetVersionChecksum("csv", "", ""))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("Given a published dataset version with a private link then the checksum is returned in the format of sha256sum", t, func() {
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().Checksum(gomock.Any(), testPrivateCsvS3Path).Return(sum, nil)
+
+		d := Download{
+			Downloader: downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, publishedDatasetDownloadPrivateURL),
+			S3Content:  s3C,
+		}
+
+		w := serveChecksum(d, target)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+		So(w.Body.String(), ShouldEqual, sum+"  "+testPrivateCsvFilename+"\n")
+	})
+
+	Convey("Given an unpublished dataset version and an unauthenticated user then the checksum is not found", t, func() {
+		d := Download{
+			Downloader: downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, unpublishedDatasetDownloadPrivateLink),
+			S3Content:  mocks.NewMockS3Content(mockCtrl),
+		}
+
+		w := serveChecksum(d, target)
+
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("Given the checksum cannot be read then an internal server error is returned", t, func() {
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().Checksum(gomock.Any(), testPrivateCsvS3Path).Return("", errExample)
+
+		d := Download{
+			Downloader: downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, publishedDatasetDownloadPrivateURL),
+			S3Content:  s3C,
+		}
+
+		w := serveChecksum(d, target)
+
+		So(w.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+}
+
+func TestDownloadDatasetVersionConversion(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	params := downloads.Parameters{DatasetID: "12345", Edition: "6789", Version: "1"}
+	csvwS3Path := "/datasets/my-dataset.csv-metadata.json"
+
+	serveConversion := func(d Download, target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, http.NoBody)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv", d.DoDatasetVersion("csv", "", ""))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("Given a published dataset version and a request for NDJSON then the CSV is converted using the CSVW column types", t, func() {
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeDatasetVersion, "csv").Return(publishedDatasetDownloadPrivateURL, nil)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeDatasetVersion, "csvw").Return(downloads.Model{IsPublished: true, PrivateS3Path: csvwS3Path}, nil)
+
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().
+			GetRange(gomock.Any(), testPrivateCsvS3Path, int64(0), int64(-1)).
+			Return(io.NopCloser(strings.NewReader("geography,value\nK02000001,1\n")), nil, nil)
+		s3C.EXPECT().
+			GetRange(gomock.Any(), csvwS3Path, int64(0), int64(-1)).
+			Return(io.NopCloser(strings.NewReader(`{"tableSchema": {"columns": [{"titles": "value", "datatype": "integer"}]}}`)), nil, nil)
+
+		d := Download{Downloader: dl, S3Content: s3C}
+
+		w := serveConversion(d, "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv?format=ndjson", "")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=\"my-dataset.ndjson\"; filename*=UTF-8''my-dataset.ndjson")
+		So(w.Body.String(), ShouldEqual, `{"geography":"K02000001","value":1}`+"\n")
+	})
+
+	Convey("Given a public link to the download and a request for JSON then the private file is converted rather than redirecting", t, func() {
+		published := publishedDatasetDownloadPrivateURL
+		published.Public = testPublicDatasetDownload
+
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeDatasetVersion, "csv").Return(published, nil)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeDatasetVersion, "csvw").Return(downloads.Model{}, errExample)
+
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().
+			GetRange(gomock.Any(), testPrivateCsvS3Path, int64(0), int64(-1)).
+			Return(io.NopCloser(strings.NewReader("geography,value\nK02000001,1\n")), nil, nil)
+
+		d := Download{Downloader: dl, S3Content: s3C}
+
+		w := serveConversion(d, "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv", "application/json")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, `[{"geography":"K02000001","value":"1"}]`+"\n")
+	})
+
+	Convey("Given an unpublished dataset version and an unauthenticated user then the conversion is not found", t, func() {
+		d := Download{
+			Downloader: downloaderReturnsResult(mockCtrl, params, downloads.TypeDatasetVersion, unpublishedDatasetDownloadPrivateLink),
+			S3Content:  mocks.NewMockS3Content(mockCtrl),
+		}
+
+		w := serveConversion(d, "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv?format=parquet", "")
+
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("Given an unsupported format then the request is rejected", t, func() {
+		d := Download{Downloader: mocks.NewMockDownloader(mockCtrl), S3Content: mocks.NewMockS3Content(mockCtrl)}
+
+		w := serveConversion(d, "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv?format=xml", "")
+
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("Given a filter on a published dataset version then the selected columns of matching rows are streamed as CSV", t, func() {
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeDatasetVersion, "csv").Return(publishedDatasetDownloadPrivateURL, nil)
+
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().
+			GetRange(gomock.Any(), testPrivateCsvS3Path, int64(0), int64(-1)).
+			Return(io.NopCloser(strings.NewReader("geography,year,value\nK02000001,2024,1\nE92000001,2024,2\nW92000004,2024,3\n")), nil, nil)
+
+		d := Download{Downloader: dl, S3Content: s3C}
+
+		w := serveConversion(d, "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv?columns=value,geography&filter=geography=K02000001,W92000004", "")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv")
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=\"my-dataset-filtered.csv\"; filename*=UTF-8''my-dataset-filtered.csv")
+		So(w.Header().Get("Content-Length"), ShouldBeEmpty)
+		So(w.Body.String(), ShouldEqual, "value,geography\n1,K02000001\n3,W92000004\n")
+	})
+
+	Convey("Given a filter on a column that is not in the file then the request is rejected", t, func() {
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeDatasetVersion, "csv").Return(publishedDatasetDownloadPrivateURL, nil)
+
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().
+			GetRange(gomock.Any(), testPrivateCsvS3Path, int64(0), int64(-1)).
+			Return(io.NopCloser(strings.NewReader("geography,value\nK02000001,1\n")), nil, nil)
+
+		d := Download{Downloader: dl, S3Content: s3C}
+
+		w := serveConversion(d, "http://localhost:28000/downloads/datasets/12345/editions/6789/versions/1.csv?columns=time", "")
+
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+}
+
 func downloaderReturnsResult(c *gomock.Controller, p downloads.Parameters, ft downloads.FileType, result downloads.Model) *mocks.MockDownloader {
 	dl := mocks.NewMockDownloader(c)
 	dl.EXPECT().Get(gomock.Any(), p, ft, gomock.Any()).Return(result, nil)
@@ -550,7 +1074,7 @@ func downloaderReturningError(c *gomock.Controller, p downloads.Parameters, ft d
 func s3ContentNeverInvoked(c *gomock.Controller) *mocks.MockS3Content {
 	s3C := mocks.NewMockS3Content(c)
 	s3C.EXPECT().
-		StreamAndWrite(gomock.Any(), gomock.Any(), gomock.Any()).
+		StreamAndWrite(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Times(0).
 		Return(nil)
 	return s3C
@@ -559,9 +1083,10 @@ func s3ContentNeverInvoked(c *gomock.Controller) *mocks.MockS3Content {
 func s3ContentWriterSuccessfullyWritesToResponse(c *gomock.Controller, w io.Writer, expectedS3Path string, expectedBody []byte) *mocks.MockS3Content {
 	s3C := mocks.NewMockS3Content(c)
 	s3C.EXPECT().
-		StreamAndWrite(gomock.Any(), gomock.Eq(expectedS3Path), gomock.Eq(w)).
+		StreamAndWrite(gomock.Any(), gomock.Eq(expectedS3Path), gomock.Eq(w), gomock.Any()).
 		Return(nil).
-		Do(func(ctx context.Context, expectedS3Path string, w io.Writer) {
+		Do(func(ctx context.Context, expectedS3Path string, w io.Writer, setSize func(size int64)) {
+			setSize(int64(len(expectedBody)))
 			_, err := w.Write(expectedBody)
 			if err != nil {
 				panic(fmt.Sprintf("failed to write expected body to response writer: %v", err))
@@ -573,8 +1098,241 @@ func s3ContentWriterSuccessfullyWritesToResponse(c *gomock.Controller, w io.Writ
 func s3ContentReturnsAnError(c *gomock.Controller, err error) *mocks.MockS3Content {
 	s3C := mocks.NewMockS3Content(c)
 	s3C.EXPECT().
-		StreamAndWrite(gomock.Any(), gomock.Any(), gomock.Any()).
+		StreamAndWrite(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(err)
 
 	return s3C
 }
+
+func testPNG(width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	var b bytes.Buffer
+	png.Encode(&b, img) // nolint
+	return b.Bytes()
+}
+
+func TestDownloadImageTransform(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	params := downloads.Parameters{ImageID: "54321", Variant: "original", Filename: "myImage.png"}
+	sizes := []int{10, 20}
+	key := "derived/images/123/original/abc123/20x0-contain.webp"
+
+	serveImage := func(d Download, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, http.NoBody)
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+
+		r.HandleFunc("/images/{imageID}/{variant}/{filename}", d.DoImage("", ""))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	s3ContentReturns := func(b []byte) *mocks.MockS3Content {
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().
+			GetRange(gomock.Any(), testPrivatePngPath, int64(0), int64(-1)).
+			Return(taggedBody{io.NopCloser(bytes.NewReader(b))}, nil, nil)
+		return s3C
+	}
+
+	Convey("Given a published image and a width then the resized image is written to the response", t, func() {
+		d := Download{
+			Downloader:     downloaderReturnsResult(mockCtrl, params, downloads.TypeImage, publishedImageDownloadPrivateURL),
+			S3Content:      s3ContentReturns(testPNG(40, 20)),
+			ImageSizes:     sizes,
+			ImageMaxPixels: 1000,
+		}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=20")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "image/png")
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=\"my-image.png\"; filename*=UTF-8''my-image.png")
+		So(w.Header().Get("Content-Length"), ShouldEqual, fmt.Sprint(w.Body.Len()))
+		img, err := png.Decode(w.Body)
+		So(err, ShouldBeNil)
+		So(img.Bounds(), ShouldResemble, image.Rect(0, 0, 20, 10))
+	})
+
+	Convey("Given an image cache that does not have the image then the converted image is cached", t, func() {
+		cache := mocks.NewMockImageCache(mockCtrl)
+		cache.EXPECT().Get(gomock.Any(), key).Return(nil, nil, errExample)
+		cache.EXPECT().Put(gomock.Any(), key, "image/webp", gomock.Any()).Return(nil)
+
+		d := Download{
+			Downloader:     downloaderReturnsResult(mockCtrl, params, downloads.TypeImage, publishedImageDownloadPrivateURL),
+			S3Content:      s3ContentReturns(testPNG(40, 20)),
+			ImageSizes:     sizes,
+			ImageMaxPixels: 1000,
+			ImageCache:     cache,
+		}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=20&format=webp")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "image/webp")
+		So(w.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=\"my-image.webp\"; filename*=UTF-8''my-image.webp")
+		So(w.Body.String(), ShouldStartWith, "RIFF")
+	})
+
+	Convey("Given an image cache that has the image of the variant's version then the cached image is written without transforming the variant", t, func() {
+		size := int64(6)
+		cache := mocks.NewMockImageCache(mockCtrl)
+		cache.EXPECT().Get(gomock.Any(), key).Return(io.NopCloser(strings.NewReader("cached")), &size, nil)
+
+		d := Download{
+			Downloader: downloaderReturnsResult(mockCtrl, params, downloads.TypeImage, publishedImageDownloadPrivateURL),
+			S3Content:  s3ContentReturns(testImageContent),
+			ImageSizes: sizes,
+			ImageCache: cache,
+		}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=20&format=webp")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Length"), ShouldEqual, "6")
+		So(w.Body.String(), ShouldEqual, "cached")
+	})
+
+	Convey("Given a variant whose version is not known then the image is not cached", t, func() {
+		s3C := mocks.NewMockS3Content(mockCtrl)
+		s3C.EXPECT().
+			GetRange(gomock.Any(), testPrivatePngPath, int64(0), int64(-1)).
+			Return(io.NopCloser(bytes.NewReader(testPNG(40, 20))), nil, nil)
+
+		d := Download{
+			Downloader:     downloaderReturnsResult(mockCtrl, params, downloads.TypeImage, publishedImageDownloadPrivateURL),
+			S3Content:      s3C,
+			ImageSizes:     sizes,
+			ImageMaxPixels: 1000,
+			ImageCache:     mocks.NewMockImageCache(mockCtrl),
+		}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=20&format=webp")
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "image/webp")
+	})
+
+	Convey("Given a size that is not allowed then the request is rejected", t, func() {
+		d := Download{Downloader: mocks.NewMockDownloader(mockCtrl), S3Content: s3ContentNeverInvoked(mockCtrl), ImageSizes: sizes}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=15")
+
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("Given an unpublished image and an unauthenticated user then the image is not found", t, func() {
+		d := Download{
+			Downloader: downloaderReturnsResult(mockCtrl, params, downloads.TypeImage, unpublishedImageDownloadPrivateLink),
+			S3Content:  s3ContentNeverInvoked(mockCtrl),
+			ImageSizes: sizes,
+		}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=20")
+
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("Given a variant that is not an image then the image cannot be processed", t, func() {
+		d := Download{
+			Downloader:     downloaderReturnsResult(mockCtrl, params, downloads.TypeImage, publishedImageDownloadPrivateURL),
+			S3Content:      s3ContentReturns(testImageContent),
+			ImageSizes:     sizes,
+			ImageMaxPixels: 1000,
+		}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=20")
+
+		So(w.Code, ShouldEqual, http.StatusUnprocessableEntity)
+	})
+}
+
+func TestDownloadResolve(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	resolve := func(d Download, route, target string, fileType downloads.FileType, extension string) (downloads.Model, error) {
+		var m downloads.Model
+		var err error
+
+		r := mux.NewRouter()
+		r.HandleFunc(route, func(w http.ResponseWriter, req *http.Request) {
+			m, err = d.Resolve(fileType, extension, testServiceToken, testDownloadServiceToken)(req)
+		})
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, http.NoBody))
+		return m, err
+	}
+
+	Convey("Given a dataset version route then the download of the extension is resolved", t, func() {
+		params := downloads.Parameters{ServiceAuthToken: testServiceToken, DownloadServiceToken: testDownloadServiceToken, DatasetID: "cpih01", Edition: "time-series", Version: "6"}
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeDatasetVersion, "txt").Return(publishedDatasetDownloadPrivateURL, nil)
+
+		m, err := resolve(Download{Downloader: dl}, "/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.txt", "/downloads/datasets/cpih01/editions/time-series/versions/6.txt", downloads.TypeDatasetVersion, "txt")
+
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, publishedDatasetDownloadPrivateURL)
+	})
+
+	Convey("Given an image route then the variant in the route is resolved", t, func() {
+		params := downloads.Parameters{ServiceAuthToken: testServiceToken, DownloadServiceToken: testDownloadServiceToken, ImageID: "54321", Variant: "original", Filename: "myImage.png"}
+		dl := mocks.NewMockDownloader(mockCtrl)
+		dl.EXPECT().Get(gomock.Any(), params, downloads.TypeImage, "original").Return(downloads.Model{}, errExample)
+
+		_, err := resolve(Download{Downloader: dl}, "/images/{imageID}/{variant}/{filename}", "/images/54321/original/myImage.png", downloads.TypeImage, "")
+
+		So(err, ShouldEqual, errExample)
+	})
+}
+
+func TestDownloadImageWith(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	serveImage := func(d Download, target string) *httptest.ResponseRecorder {
+		variant := func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}
+
+		w := httptest.NewRecorder()
+		r := mux.NewRouter()
+		r.HandleFunc("/images/{imageID}/{variant}/{filename}", d.DoImageWith(variant, "", ""))
+		r.ServeHTTP(w, httptest.NewRequest("GET", target, http.NoBody))
+		return w
+	}
+
+	Convey("Given an image variant that is not resized or converted then it is handled by the variant handler", t, func() {
+		d := Download{Downloader: mocks.NewMockDownloader(mockCtrl), ImageSizes: []int{10, 20}}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png")
+
+		So(w.Code, ShouldEqual, http.StatusTeapot)
+	})
+
+	Convey("Given a size that is not allowed then the request is rejected by the image handler", t, func() {
+		d := Download{Downloader: mocks.NewMockDownloader(mockCtrl), ImageSizes: []int{10, 20}}
+
+		w := serveImage(d, "http://localhost:28000/images/54321/original/myImage.png?width=15")
+
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+}
+
+// taggedBody is the body of an image variant that reports the entity tag of the object, as bodies read from S3 do
+type taggedBody struct {
+	io.ReadCloser
+}
+
+func (taggedBody) Object() content.ObjectInfo {
+	return content.ObjectInfo{ETag: `"abc123"`}
+}
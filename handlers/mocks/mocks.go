@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/ONSdigital/dp-download-service/handlers (interfaces: Downloader,S3Content)
+// Source: github.com/ONSdigital/dp-download-service/handlers (interfaces: Downloader,S3Content,ImageCache)
 
 // Package mocks is a generated GoMock package.
 package mocks
@@ -51,6 +51,22 @@ func (mr *MockDownloaderMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockDownloader)(nil).Get), arg0, arg1, arg2, arg3)
 }
 
+// LatestVersion mocks base method.
+func (m *MockDownloader) LatestVersion(arg0 context.Context, arg1 downloads.Parameters, arg2 bool) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestVersion", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LatestVersion indicates an expected call of LatestVersion.
+func (mr *MockDownloaderMockRecorder) LatestVersion(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestVersion", reflect.TypeOf((*MockDownloader)(nil).LatestVersion), arg0, arg1, arg2)
+}
+
 // MockS3Content is a mock of S3Content interface.
 type MockS3Content struct {
 	ctrl     *gomock.Controller
@@ -74,16 +90,100 @@ func (m *MockS3Content) EXPECT() *MockS3ContentMockRecorder {
 	return m.recorder
 }
 
+// Checksum mocks base method.
+func (m *MockS3Content) Checksum(arg0 context.Context, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checksum", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Checksum indicates an expected call of Checksum.
+func (mr *MockS3ContentMockRecorder) Checksum(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checksum", reflect.TypeOf((*MockS3Content)(nil).Checksum), arg0, arg1)
+}
+
+// GetRange mocks base method.
+func (m *MockS3Content) GetRange(arg0 context.Context, arg1 string, arg2, arg3 int64) (io.ReadCloser, *int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRange", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(*int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetRange indicates an expected call of GetRange.
+func (mr *MockS3ContentMockRecorder) GetRange(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRange", reflect.TypeOf((*MockS3Content)(nil).GetRange), arg0, arg1, arg2, arg3)
+}
+
 // StreamAndWrite mocks base method.
-func (m *MockS3Content) StreamAndWrite(arg0 context.Context, arg1 string, arg2 io.Writer) error {
+func (m *MockS3Content) StreamAndWrite(arg0 context.Context, arg1 string, arg2 io.Writer, arg3 func(int64)) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StreamAndWrite", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "StreamAndWrite", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // StreamAndWrite indicates an expected call of StreamAndWrite.
-func (mr *MockS3ContentMockRecorder) StreamAndWrite(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockS3ContentMockRecorder) StreamAndWrite(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamAndWrite", reflect.TypeOf((*MockS3Content)(nil).StreamAndWrite), arg0, arg1, arg2, arg3)
+}
+
+// MockImageCache is a mock of ImageCache interface.
+type MockImageCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockImageCacheMockRecorder
+}
+
+// MockImageCacheMockRecorder is the mock recorder for MockImageCache.
+type MockImageCacheMockRecorder struct {
+	mock *MockImageCache
+}
+
+// NewMockImageCache creates a new mock instance.
+func NewMockImageCache(ctrl *gomock.Controller) *MockImageCache {
+	mock := &MockImageCache{ctrl: ctrl}
+	mock.recorder = &MockImageCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockImageCache) EXPECT() *MockImageCacheMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockImageCache) Get(arg0 context.Context, arg1 string) (io.ReadCloser, *int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(*int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockImageCacheMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockImageCache)(nil).Get), arg0, arg1)
+}
+
+// Put mocks base method.
+func (m *MockImageCache) Put(arg0 context.Context, arg1, arg2 string, arg3 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Put", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Put indicates an expected call of Put.
+func (mr *MockImageCacheMockRecorder) Put(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamAndWrite", reflect.TypeOf((*MockS3Content)(nil).StreamAndWrite), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockImageCache)(nil).Put), arg0, arg1, arg2, arg3)
 }
@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidOptions   = errors.New("invalid image options")
+	ErrUnsupportedImage = errors.New("unsupported image")
+	ErrImageTooLarge    = errors.New("image too large")
+)
+
+// Format is an image format that images can be converted to
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+)
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Filename replaces the extension of a filename with the extension of the format
+func (f Format) Filename(name string) string {
+	ext := string(f)
+	if f == FormatJPEG {
+		ext = "jpg"
+	}
+	return strings.TrimSuffix(name, path.Ext(name)) + "." + ext
+}
+
+// FormatOf returns the format of an image from the extension of its filename, or PNG if it is not recognised
+func FormatOf(filename string) Format {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".jpg", ".jpeg":
+		return FormatJPEG
+	case ".webp":
+		return FormatWebP
+	default:
+		return FormatPNG
+	}
+}
+
+// Fit is how an image is resized when both a width and height are given
+type Fit string
+
+const (
+	// FitContain scales the image to fit within the width and height, keeping its aspect ratio
+	FitContain Fit = "contain"
+	// FitCover scales the image to cover the width and height, keeping its aspect ratio and cropping the centre
+	FitCover Fit = "cover"
+	// FitFill stretches the image to the width and height
+	FitFill Fit = "fill"
+)
+
+// Options describe an image derived from another. A zero width or height is calculated from the aspect ratio of the
+// source, and if both are zero the image is only converted to another format.
+type Options struct {
+	Width  int
+	Height int
+	Fit    Fit
+	Format Format
+}
+
+// ParseOptions reads the width, height, fit and format query parameters of a request for the image filename,
+// returning nil if the image should be served as it is. Widths and heights must be one of the allowed sizes. The
+// format defaults to the format of the filename.
+func ParseOptions(query url.Values, filename string, sizes []int) (*Options, error) {
+	if !query.Has("width") && !query.Has("height") && !query.Has("fit") && !query.Has("format") {
+		return nil, nil
+	}
+
+	opts := &Options{Fit: FitContain, Format: FormatOf(filename)}
+
+	var err error
+	if opts.Width, err = parseSize(query, "width", sizes); err != nil {
+		return nil, err
+	}
+	if opts.Height, err = parseSize(query, "height", sizes); err != nil {
+		return nil, err
+	}
+
+	if query.Has("fit") {
+		switch fit := Fit(query.Get("fit")); fit {
+		case FitContain, FitCover, FitFill:
+			opts.Fit = fit
+		default:
+			return nil, fmt.Errorf("%w: fit must be one of contain, cover or fill", ErrInvalidOptions)
+		}
+		if opts.Width == 0 && opts.Height == 0 {
+			return nil, fmt.Errorf("%w: fit requires a width or height", ErrInvalidOptions)
+		}
+	}
+
+	if query.Has("format") {
+		switch format := strings.ToLower(query.Get("format")); format {
+		case "jpg", string(FormatJPEG):
+			opts.Format = FormatJPEG
+		case string(FormatPNG), string(FormatWebP):
+			opts.Format = Format(format)
+		default:
+			return nil, fmt.Errorf("%w: format must be one of webp, png or jpeg", ErrInvalidOptions)
+		}
+	}
+
+	return opts, nil
+}
+
+func parseSize(query url.Values, name string, sizes []int) (int, error) {
+	if !query.Has(name) {
+		return 0, nil
+	}
+
+	size, err := strconv.Atoi(query.Get(name))
+	if err != nil || !slices.Contains(sizes, size) {
+		return 0, fmt.Errorf("%w: %s must be one of %v", ErrInvalidOptions, name, sizes)
+	}
+
+	return size, nil
+}
+
+// Key returns the storage key of the image derived from the given version of the object with the source key. The
+// version, such as the entity tag of the object, means that images derived from a replaced object are not reused.
+func (o Options) Key(source, version string) string {
+	return fmt.Sprintf("derived/%s/%s/%dx%d-%s.%s", strings.TrimPrefix(source, "/"), strings.Trim(version, `"`), o.Width, o.Height, o.Fit, o.Format)
+}
@@ -0,0 +1,129 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// bounds returns the part of a source image of size sw by sh to use and the size to scale it to
+func (o Options) bounds(sw, sh int) (image.Rectangle, int, int) {
+	full := image.Rect(0, 0, sw, sh)
+	w, h := o.Width, o.Height
+
+	switch {
+	case w == 0 && h == 0:
+		return full, sw, sh
+	case w == 0:
+		return full, scaled(sw, h, sh), h
+	case h == 0:
+		return full, w, scaled(sh, w, sw)
+	}
+
+	switch o.Fit {
+	case FitFill:
+		return full, w, h
+	case FitCover:
+		// Crop the centre of the source to the aspect ratio of the output
+		cw, ch := sw, scaled(sw, h, w)
+		if ch > sh {
+			cw, ch = scaled(sh, w, h), sh
+		}
+		x, y := (sw-cw)/2, (sh-ch)/2
+		return image.Rect(x, y, x+cw, y+ch), w, h
+	default:
+		if sw*h > sh*w {
+			return full, w, scaled(sh, w, sw)
+		}
+		return full, scaled(sw, h, sh), h
+	}
+}
+
+// scaled returns size multiplied by num/den, rounded and at least 1
+func scaled(size, num, den int) int {
+	return max(int(math.Round(float64(size)*float64(num)/float64(den))), 1)
+}
+
+// resize scales the crop of src to w by h using a triangle filter, which is widened when shrinking so that every
+// source pixel contributes to the output. Colours are filtered with premultiplied alpha.
+func resize(src image.Image, crop image.Rectangle, w, h int) *image.RGBA {
+	in := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(in, in.Bounds(), src, src.Bounds().Min.Add(crop.Min), draw.Src)
+
+	if crop.Dx() == w && crop.Dy() == h {
+		return in
+	}
+
+	sw, sh := crop.Dx(), crop.Dy()
+
+	// Resize each row to the output width
+	columns := filterWeights(w, sw)
+	tmp := make([]float32, w*sh*4)
+	for y := 0; y < sh; y++ {
+		row := in.Pix[y*in.Stride:]
+		for x, c := range columns {
+			var px [4]float32
+			for i, weight := range c.values {
+				p := row[(c.start+i)*4:]
+				for k := range px {
+					px[k] += float32(p[k]) * weight
+				}
+			}
+			copy(tmp[(y*w+x)*4:], px[:])
+		}
+	}
+
+	// Then resize each column to the output height
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	rows := filterWeights(h, sh)
+	for y, r := range rows {
+		for x := 0; x < w; x++ {
+			var px [4]float32
+			for i, weight := range r.values {
+				p := tmp[((r.start+i)*w+x)*4:]
+				for k := range px {
+					px[k] += p[k] * weight
+				}
+			}
+			o := out.Pix[y*out.Stride+x*4:]
+			for k, v := range px {
+				o[k] = uint8(min(max(math.Round(float64(v)), 0), 255))
+			}
+		}
+	}
+
+	return out
+}
+
+// weights are the contributions of consecutive source pixels, starting at start, to an output pixel
+type weights struct {
+	start  int
+	values []float32
+}
+
+func filterWeights(dst, src int) []weights {
+	scale := float64(src) / float64(dst)
+	radius := math.Max(scale, 1)
+
+	out := make([]weights, dst)
+	for i := range out {
+		center := (float64(i) + 0.5) * scale
+		start := max(int(math.Floor(center-radius)), 0)
+		end := min(int(math.Ceil(center+radius)), src)
+
+		var sum float64
+		values := make([]float64, 0, end-start)
+		for j := start; j < end; j++ {
+			weight := math.Max(0, 1-math.Abs(float64(j)+0.5-center)/radius)
+			values = append(values, weight)
+			sum += weight
+		}
+
+		out[i].start = start
+		for _, v := range values {
+			out[i].values = append(out[i].values, float32(v/sum))
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,54 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// JPEGQuality is the quality of JPEG images written by Transform
+const JPEGQuality = 85
+
+// Transform decodes the JPEG, PNG or GIF image in r and writes it to w resized and converted as described by opts.
+// Images with more than maxPixels pixels are rejected before they are decoded.
+func Transform(w io.Writer, r io.Reader, opts Options, maxPixels int) error {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnsupportedImage, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return fmt.Errorf("%w: %dx%d is more than %d pixels", ErrImageTooLarge, config.Width, config.Height, maxPixels)
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnsupportedImage, err)
+	}
+
+	crop, width, height := opts.bounds(src.Bounds().Dx(), src.Bounds().Dy())
+	img := resize(src, crop, width, height)
+
+	switch opts.Format {
+	case FormatJPEG:
+		return jpeg.Encode(w, flatten(img), &jpeg.Options{Quality: JPEGQuality})
+	case FormatWebP:
+		return encodeWebP(w, img)
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// flatten draws an image onto a white background, as JPEG images cannot be transparent
+func flatten(img *image.RGBA) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+	return out
+}
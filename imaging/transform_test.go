@@ -0,0 +1,164 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSizes = []int{10, 20, 40}
+
+func TestParseOptions(t *testing.T) {
+	t.Run("no options", func(t *testing.T) {
+		opts, err := ParseOptions(url.Values{}, "logo.png", testSizes)
+		require.NoError(t, err)
+		assert.Nil(t, opts)
+	})
+
+	t.Run("defaults to containing the image in the format of the filename", func(t *testing.T) {
+		opts, err := ParseOptions(url.Values{"width": {"20"}}, "photo.JPG", testSizes)
+		require.NoError(t, err)
+		assert.Equal(t, &Options{Width: 20, Fit: FitContain, Format: FormatJPEG}, opts)
+	})
+
+	t.Run("every option", func(t *testing.T) {
+		query := url.Values{"width": {"20"}, "height": {"10"}, "fit": {"cover"}, "format": {"webp"}}
+		opts, err := ParseOptions(query, "logo.png", testSizes)
+		require.NoError(t, err)
+		assert.Equal(t, &Options{Width: 20, Height: 10, Fit: FitCover, Format: FormatWebP}, opts)
+	})
+
+	t.Run("only a format", func(t *testing.T) {
+		opts, err := ParseOptions(url.Values{"format": {"jpg"}}, "logo.png", testSizes)
+		require.NoError(t, err)
+		assert.Equal(t, &Options{Fit: FitContain, Format: FormatJPEG}, opts)
+	})
+
+	for name, query := range map[string]url.Values{
+		"size not allowed":   {"width": {"15"}},
+		"size not a number":  {"height": {"tall"}},
+		"unknown fit":        {"width": {"10"}, "fit": {"stretch"}},
+		"fit without a size": {"fit": {"cover"}},
+		"unsupported format": {"format": {"tiff"}},
+		"empty size":         {"width": {""}},
+		"negative size":      {"width": {"-10"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseOptions(query, "logo.png", testSizes)
+			assert.ErrorIs(t, err, ErrInvalidOptions)
+		})
+	}
+}
+
+func TestOptionsKey(t *testing.T) {
+	opts := Options{Width: 20, Fit: FitContain, Format: FormatWebP}
+	assert.Equal(t, "derived/images/123/original/abc123/20x0-contain.webp", opts.Key("images/123/original", `"abc123"`))
+}
+
+func TestFormatFilename(t *testing.T) {
+	assert.Equal(t, "logo.webp", FormatWebP.Filename("logo.png"))
+	assert.Equal(t, "logo.jpg", FormatJPEG.Filename("logo"))
+}
+
+func TestBounds(t *testing.T) {
+	tests := map[string]struct {
+		opts   Options
+		crop   image.Rectangle
+		width  int
+		height int
+	}{
+		"no size":           {opts: Options{}, crop: image.Rect(0, 0, 400, 200), width: 400, height: 200},
+		"width only":        {opts: Options{Width: 100}, crop: image.Rect(0, 0, 400, 200), width: 100, height: 50},
+		"height only":       {opts: Options{Height: 100}, crop: image.Rect(0, 0, 400, 200), width: 200, height: 100},
+		"contain wide":      {opts: Options{Width: 100, Height: 100, Fit: FitContain}, crop: image.Rect(0, 0, 400, 200), width: 100, height: 50},
+		"cover wide":        {opts: Options{Width: 100, Height: 100, Fit: FitCover}, crop: image.Rect(100, 0, 300, 200), width: 100, height: 100},
+		"cover tall output": {opts: Options{Width: 50, Height: 100, Fit: FitCover}, crop: image.Rect(150, 0, 250, 200), width: 50, height: 100},
+		"fill":              {opts: Options{Width: 100, Height: 100, Fit: FitFill}, crop: image.Rect(0, 0, 400, 200), width: 100, height: 100},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			crop, width, height := test.opts.bounds(400, 200)
+			assert.Equal(t, test.crop, crop)
+			assert.Equal(t, test.width, width)
+			assert.Equal(t, test.height, height)
+		})
+	}
+}
+
+func TestResizeKeepsSolidColours(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 37, 23))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:], []byte{10, 120, 250, 255})
+	}
+
+	for _, size := range [][2]int{{10, 7}, {37, 23}, {80, 50}} {
+		out := resize(src, src.Bounds(), size[0], size[1])
+		require.Equal(t, image.Rect(0, 0, size[0], size[1]), out.Bounds())
+		for i := 0; i < len(out.Pix); i += 4 {
+			require.Equal(t, []byte{10, 120, 250, 255}, out.Pix[i:i+4])
+		}
+	}
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 6), G: uint8(y * 12), B: 90, A: 255})
+		}
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, png.Encode(&b, img))
+	return b.Bytes()
+}
+
+func TestTransform(t *testing.T) {
+	src := testPNG(t, 40, 20)
+
+	t.Run("png", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, Transform(&b, bytes.NewReader(src), Options{Width: 20, Fit: FitContain, Format: FormatPNG}, 1000))
+
+		img, err := png.Decode(&b)
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+	})
+
+	t.Run("jpeg", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, Transform(&b, bytes.NewReader(src), Options{Width: 10, Height: 10, Fit: FitCover, Format: FormatJPEG}, 1000))
+
+		img, err := jpeg.Decode(&b)
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 10, 10), img.Bounds())
+	})
+
+	t.Run("webp", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, Transform(&b, bytes.NewReader(src), Options{Height: 10, Fit: FitContain, Format: FormatWebP}, 1000))
+
+		img, alpha := decodeWebP(t, b.Bytes())
+		assert.False(t, alpha)
+		assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+	})
+
+	t.Run("too many pixels", func(t *testing.T) {
+		err := Transform(&bytes.Buffer{}, bytes.NewReader(src), Options{Format: FormatPNG}, 799)
+		assert.ErrorIs(t, err, ErrImageTooLarge)
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		err := Transform(&bytes.Buffer{}, strings.NewReader("%PDF"), Options{Format: FormatPNG}, 1000)
+		assert.ErrorIs(t, err, ErrUnsupportedImage)
+	})
+}
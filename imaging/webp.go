@@ -0,0 +1,278 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"slices"
+)
+
+// WebP images are written in the lossless VP8L format, using a prefix code for each colour channel and none of the
+// optional transforms or backward references, which keeps the encoder small at the cost of some compression.
+
+const (
+	vp8lSignature   = 0x2f
+	vp8lMaxSize     = 1 << 14
+	vp8lMaxCodeBits = 15
+	vp8lMaxCLBits   = 7
+
+	// The green alphabet includes 24 length prefixes that are never used by this encoder
+	vp8lGreenSymbols    = 256 + 24
+	vp8lDistanceSymbols = 40
+)
+
+// vp8lCodeLengthOrder is the order the code lengths of the code length code are written in
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// bitWriter writes values least significant bit first, as VP8L requires
+type bitWriter struct {
+	buf  []byte
+	bits uint64
+	n    uint
+}
+
+func (b *bitWriter) write(v uint32, n uint) {
+	b.bits |= uint64(v) << b.n
+	b.n += n
+	for b.n >= 8 {
+		b.buf = append(b.buf, byte(b.bits))
+		b.bits >>= 8
+		b.n -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.n > 0 {
+		b.buf = append(b.buf, byte(b.bits))
+		b.bits, b.n = 0, 0
+	}
+	return b.buf
+}
+
+// prefixCode is a canonical prefix code. Codes with a single symbol are written with zero bits.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+	single  bool
+}
+
+func (b *bitWriter) writeSymbol(c *prefixCode, symbol int) {
+	if !c.single {
+		b.write(c.codes[symbol], uint(c.lengths[symbol]))
+	}
+}
+
+// encodeWebP writes img as a lossless WebP image
+func encodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > vp8lMaxSize || height > vp8lMaxSize {
+		return fmt.Errorf("%w: webp images can be at most %d pixels wide and high", ErrImageTooLarge, vp8lMaxSize)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	// Histograms of green, red, blue and alpha
+	var histograms [4][]int
+	histograms[0] = make([]int, vp8lGreenSymbols)
+	for i := 1; i < 4; i++ {
+		histograms[i] = make([]int, 256)
+	}
+	alpha := false
+	for p := 0; p < len(nrgba.Pix); p += 4 {
+		r, g, b, a := nrgba.Pix[p], nrgba.Pix[p+1], nrgba.Pix[p+2], nrgba.Pix[p+3]
+		histograms[0][g]++
+		histograms[1][r]++
+		histograms[2][b]++
+		histograms[3][a]++
+		alpha = alpha || a != 0xff
+	}
+
+	bw := &bitWriter{}
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // version
+	bw.write(0, 1) // no transforms
+	bw.write(0, 1) // no colour cache
+	bw.write(0, 1) // a single group of prefix codes for the whole image
+
+	var codes [4]*prefixCode
+	for i, histogram := range histograms {
+		codes[i] = bw.writePrefixCode(histogram)
+	}
+	bw.writePrefixCode(make([]int, vp8lDistanceSymbols))
+
+	for p := 0; p < len(nrgba.Pix); p += 4 {
+		bw.writeSymbol(codes[0], int(nrgba.Pix[p+1]))
+		bw.writeSymbol(codes[1], int(nrgba.Pix[p]))
+		bw.writeSymbol(codes[2], int(nrgba.Pix[p+2]))
+		bw.writeSymbol(codes[3], int(nrgba.Pix[p+3]))
+	}
+
+	data := append([]byte{vp8lSignature}, bw.bytes()...)
+	padding := len(data) % 2
+
+	header := make([]byte, 20)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+len(data)+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// writePrefixCode writes the prefix code for a histogram of symbols, using a simple code if there are only one or
+// two symbols and otherwise a normal code with lengths limited to vp8lMaxCodeBits
+func (b *bitWriter) writePrefixCode(histogram []int) *prefixCode {
+	var symbols []int
+	for s, count := range histogram {
+		if count > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) == 0 {
+		symbols = []int{0}
+	}
+
+	if len(symbols) <= 2 && symbols[len(symbols)-1] < 256 {
+		b.write(1, 1)
+		b.write(uint32(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			b.write(0, 1)
+			b.write(uint32(symbols[0]), 1)
+		} else {
+			b.write(1, 1)
+			b.write(uint32(symbols[0]), 8)
+		}
+		if len(symbols) == 2 {
+			b.write(uint32(symbols[1]), 8)
+		}
+
+		lengths := make([]int, len(histogram))
+		for _, s := range symbols {
+			lengths[s] = 1
+		}
+		return newPrefixCode(lengths)
+	}
+
+	lengths := huffmanLengths(histogram, vp8lMaxCodeBits)
+
+	clHistogram := make([]int, len(vp8lCodeLengthOrder))
+	for _, l := range lengths {
+		clHistogram[l]++
+	}
+	clLengths := huffmanLengths(clHistogram, vp8lMaxCLBits)
+	clCode := newPrefixCode(clLengths)
+
+	n := len(vp8lCodeLengthOrder)
+	for n > 4 && clLengths[vp8lCodeLengthOrder[n-1]] == 0 {
+		n--
+	}
+
+	b.write(0, 1)
+	b.write(uint32(n-4), 4)
+	for _, s := range vp8lCodeLengthOrder[:n] {
+		b.write(uint32(clLengths[s]), 3)
+	}
+	b.write(0, 1) // code lengths are written for every symbol
+	for _, l := range lengths {
+		b.writeSymbol(clCode, l)
+	}
+
+	return newPrefixCode(lengths)
+}
+
+// newPrefixCode assigns canonical codes to code lengths, reversing the bits of each code as it is written least
+// significant bit first
+func newPrefixCode(lengths []int) *prefixCode {
+	var counts [vp8lMaxCodeBits + 1]int
+	used := 0
+	for _, l := range lengths {
+		if l > 0 {
+			counts[l]++
+			used++
+		}
+	}
+
+	var next [vp8lMaxCodeBits + 1]uint32
+	var code uint32
+	for bits := 1; bits <= vp8lMaxCodeBits; bits++ {
+		code = (code + uint32(counts[bits-1])) << 1
+		next[bits] = code
+	}
+	c := &prefixCode{lengths: lengths, codes: make([]uint32, len(lengths)), single: used == 1}
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c.codes[s] = reverseBits(next[l], l)
+		next[l]++
+	}
+	return c
+}
+
+func reverseBits(code uint32, n int) uint32 {
+	var r uint32
+	for i := 0; i < n; i++ {
+		r = r<<1 | code&1
+		code >>= 1
+	}
+	return r
+}
+
+// huffmanLengths returns the code length of each symbol in a Huffman code for the histogram. If the longest code is
+// longer than maxBits the counts are flattened and the code rebuilt until it fits.
+func huffmanLengths(histogram []int, maxBits int) []int {
+	type node struct {
+		count   int
+		symbols []int
+	}
+
+	lengths := make([]int, len(histogram))
+	for shift := 0; ; shift++ {
+		var nodes []node
+		for s, count := range histogram {
+			if count > 0 {
+				nodes = append(nodes, node{count: max(count>>shift, 1), symbols: []int{s}})
+			}
+		}
+
+		clear(lengths)
+		if len(nodes) == 1 {
+			lengths[nodes[0].symbols[0]] = 1
+			return lengths
+		}
+
+		for len(nodes) > 1 {
+			slices.SortStableFunc(nodes, func(a, b node) int { return a.count - b.count })
+			a, b := nodes[0], nodes[1]
+			symbols := slices.Concat(a.symbols, b.symbols)
+			for _, s := range symbols {
+				lengths[s]++
+			}
+			nodes = append(nodes[2:], node{count: a.count + b.count, symbols: symbols})
+		}
+
+		if slices.Max(lengths) <= maxBits {
+			return lengths
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+// decodeWebP decodes a WebP image with golang.org/x/image/webp and reports whether its VP8L header says the alpha
+// channel is used
+func decodeWebP(t *testing.T, b []byte) (*image.NRGBA, bool) {
+	require.Equal(t, "WEBPVP8L", string(b[8:16]))
+	require.Equal(t, byte(vp8lSignature), b[20])
+	alpha := binary.LittleEndian.Uint32(b[21:])>>28&1 == 1
+
+	img, err := webp.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	nrgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return nrgba, alpha
+}
+
+func TestEncodeWebP(t *testing.T) {
+	gradient := image.NewNRGBA(image.Rect(0, 0, 67, 31))
+	for y := 0; y < 31; y++ {
+		for x := 0; x < 67; x++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 3), G: uint8(y * 8), B: uint8(x * y), A: uint8(255 - x)})
+		}
+	}
+
+	opaque := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+	for i := range opaque.Pix {
+		opaque.Pix[i] = 0xff
+	}
+
+	twoColours := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			twoColours.SetNRGBA(x, y, color.NRGBA{R: uint8(200 * (x % 2)), G: 1, B: 0, A: 255})
+		}
+	}
+
+	noise := image.NewNRGBA(image.Rect(0, 0, 40, 25))
+	random := rand.New(rand.NewPCG(1, 2))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(random.IntN(256))
+	}
+
+	tests := map[string]struct {
+		img   *image.NRGBA
+		alpha bool
+	}{
+		"many colours with alpha": {img: gradient, alpha: true},
+		"a single opaque colour":  {img: opaque},
+		"two colours":             {img: twoColours},
+		"random noise":            {img: noise, alpha: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, encodeWebP(&b, test.img))
+
+			decoded, alpha := decodeWebP(t, b.Bytes())
+			assert.Equal(t, test.alpha, alpha)
+			assert.Equal(t, test.img.Bounds(), decoded.Bounds())
+			assert.Equal(t, test.img.Pix, decoded.Pix)
+		})
+	}
+
+	t.Run("too large", func(t *testing.T) {
+		err := encodeWebP(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, vp8lMaxSize+1, 1)))
+		assert.ErrorIs(t, err, ErrImageTooLarge)
+	})
+}
+
+func TestHuffmanLengthsAreLimited(t *testing.T) {
+	// Fibonacci counts give the deepest possible Huffman tree
+	histogram := make([]int, 30)
+	histogram[0], histogram[1] = 1, 1
+	for i := 2; i < len(histogram); i++ {
+		histogram[i] = histogram[i-1] + histogram[i-2]
+	}
+
+	lengths := huffmanLengths(histogram, vp8lMaxCodeBits)
+
+	var kraft float64
+	for _, l := range lengths {
+		assert.LessOrEqual(t, l, vp8lMaxCodeBits)
+		kraft += math.Pow(2, -float64(l))
+	}
+	assert.Equal(t, 1.0, kraft)
+}
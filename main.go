@@ -7,6 +7,7 @@ import (
 	"syscall"
 
 	"github.com/ONSdigital/dp-download-service/config"
+	"github.com/ONSdigital/dp-download-service/retry"
 	"github.com/ONSdigital/dp-download-service/service"
 	"github.com/ONSdigital/dp-download-service/service/external"
 	"github.com/ONSdigital/log.go/v2/log"
@@ -42,7 +43,11 @@ func run(ctx context.Context) error {
 	}
 	log.Info(ctx, "config on startup", log.Data{"config": cfg})
 
-	svc, err := service.New(ctx, BuildTime, GitCommit, Version, cfg, &external.External{})
+	deps := &external.External{
+		Retry: retry.NewPolicy(cfg.RetryMaxAttempts, cfg.RetryInitialBackoff, cfg.RetryMaxBackoff),
+	}
+
+	svc, err := service.New(ctx, BuildTime, GitCommit, Version, cfg, deps)
 	if err != nil {
 		log.Fatal(ctx, "could not set up Download service", err)
 	}
@@ -0,0 +1,56 @@
+package provenance
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// Names of the custom headers describing where a file came from
+const (
+	TitleHeader          = "X-Title"
+	ReleaseDateHeader    = "X-Release-Date"
+	DatasetIDHeader      = "X-Dataset-Id"
+	DatasetEditionHeader = "X-Dataset-Edition"
+	DatasetVersionHeader = "X-Dataset-Version"
+)
+
+// CSVWMediaType is the media type of CSVW metadata describing a CSV file
+const CSVWMediaType = "application/csvm+json"
+
+// Headers describe the licence of a file, where to find metadata describing it and, optionally, where it came from.
+// Empty values are not written.
+type Headers struct {
+	LicenceURL  string
+	DescribedBy string
+
+	// Custom headers, only set when they are enabled
+	Title       string
+	ReleaseDate string
+	DatasetID   string
+	Edition     string
+	Version     string
+}
+
+// Set adds the headers to h. The licence and metadata are added as Link headers.
+func (p Headers) Set(h http.Header) {
+	if p.LicenceURL != "" {
+		h.Add("Link", fmt.Sprintf(`<%s>; rel="license"`, p.LicenceURL))
+	}
+	if p.DescribedBy != "" {
+		h.Add("Link", fmt.Sprintf(`<%s>; rel="describedby"; type="%s"`, p.DescribedBy, CSVWMediaType))
+	}
+
+	set := func(name, value string) {
+		if value != "" {
+			h.Set(name, value)
+		}
+	}
+
+	// Titles may not be ASCII, so are encoded as RFC 2047 encoded-words when needed
+	set(TitleHeader, mime.QEncoding.Encode("utf-8", p.Title))
+	set(ReleaseDateHeader, p.ReleaseDate)
+	set(DatasetIDHeader, p.DatasetID)
+	set(DatasetEditionHeader, p.Edition)
+	set(DatasetVersionHeader, p.Version)
+}
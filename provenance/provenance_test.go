@@ -0,0 +1,40 @@
+package provenance
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	t.Run("links the licence and metadata", func(t *testing.T) {
+		h := http.Header{}
+		Headers{LicenceURL: "https://example.com/ogl", DescribedBy: "/downloads/files/data/file.csv-metadata.json"}.Set(h)
+
+		assert.Equal(t, []string{
+			`<https://example.com/ogl>; rel="license"`,
+			`</downloads/files/data/file.csv-metadata.json>; rel="describedby"; type="application/csvm+json"`,
+		}, h.Values("Link"))
+		assert.Empty(t, h.Get(TitleHeader))
+	})
+
+	t.Run("sets the custom headers that have values", func(t *testing.T) {
+		h := http.Header{}
+		Headers{Title: "Consumer price inflation", DatasetID: "cpih01", Edition: "time-series", Version: "2"}.Set(h)
+
+		assert.Empty(t, h.Values("Link"))
+		assert.Equal(t, "Consumer price inflation", h.Get(TitleHeader))
+		assert.Equal(t, "cpih01", h.Get(DatasetIDHeader))
+		assert.Equal(t, "time-series", h.Get(DatasetEditionHeader))
+		assert.Equal(t, "2", h.Get(DatasetVersionHeader))
+		assert.NotContains(t, h, ReleaseDateHeader)
+	})
+
+	t.Run("encodes titles that are not ASCII", func(t *testing.T) {
+		h := http.Header{}
+		Headers{Title: "Chwyddiant prisiau defnyddwyr – Cymru"}.Set(h)
+
+		assert.Equal(t, "=?utf-8?q?Chwyddiant_prisiau_defnyddwyr_=E2=80=93_Cymru?=", h.Get(TitleHeader))
+	})
+}
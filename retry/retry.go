@@ -0,0 +1,65 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy determines how many times, and how often, a failed operation is attempted
+type Policy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// NewPolicy creates a new Policy. A maxAttempts of 1 or less disables retries.
+func NewPolicy(maxAttempts int, initialBackoff, maxBackoff time.Duration) Policy {
+	return Policy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     maxBackoff,
+	}
+}
+
+// Backoff returns how long to wait before the given retry, counting from 1. The wait is chosen at random up to an
+// exponentially growing limit ("full jitter"), so that clients retrying at the same time spread out their requests.
+func (p Policy) Backoff(retry int) time.Duration {
+	limit := p.InitialBackoff << min(retry-1, 30)
+	if limit <= 0 || (p.MaxBackoff > 0 && limit > p.MaxBackoff) {
+		limit = p.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return rand.N(limit) + 1
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable, the attempts are used up or ctx is done.
+// The last error from fn is returned.
+func (p Policy) Do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	err := fn()
+
+	for retry := 1; retry < p.MaxAttempts && err != nil && retryable(err); retry++ {
+		if waitErr := p.Wait(ctx, retry); waitErr != nil {
+			return err
+		}
+		err = fn()
+	}
+
+	return err
+}
+
+// Wait waits for the backoff before the given retry, returning early with an error if ctx is done
+func (p Policy) Wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(p.Backoff(retry))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
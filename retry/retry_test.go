@@ -0,0 +1,138 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var errTransient = errors.New("transient")
+
+func TestPolicy_Backoff(t *testing.T) {
+	Convey("should wait up to an exponentially growing limit capped at the maximum backoff", t, func() {
+		p := NewPolicy(5, 10*time.Millisecond, 50*time.Millisecond)
+
+		for i := 0; i < 100; i++ {
+			So(p.Backoff(1), ShouldBeBetweenOrEqual, time.Nanosecond, 10*time.Millisecond)
+			So(p.Backoff(2), ShouldBeBetweenOrEqual, time.Nanosecond, 20*time.Millisecond)
+			So(p.Backoff(10), ShouldBeBetweenOrEqual, time.Nanosecond, 50*time.Millisecond)
+		}
+	})
+
+	Convey("should not wait without a backoff", t, func() {
+		So(NewPolicy(3, 0, 0).Backoff(1), ShouldEqual, 0)
+	})
+}
+
+func TestPolicy_Do(t *testing.T) {
+	ctx := context.Background()
+	p := NewPolicy(3, time.Millisecond, time.Millisecond)
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+
+	Convey("should retry retryable errors until the call succeeds", t, func() {
+		calls := 0
+		err := p.Do(ctx, retryable, func() error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		})
+
+		So(err, ShouldBeNil)
+		So(calls, ShouldEqual, 3)
+	})
+
+	Convey("should return the last error once the attempts are used up", t, func() {
+		calls := 0
+		err := p.Do(ctx, retryable, func() error {
+			calls++
+			return errTransient
+		})
+
+		So(err, ShouldEqual, errTransient)
+		So(calls, ShouldEqual, 3)
+	})
+
+	Convey("should not retry errors that are not retryable", t, func() {
+		calls := 0
+		permanent := errors.New("permanent")
+		err := p.Do(ctx, retryable, func() error {
+			calls++
+			return permanent
+		})
+
+		So(err, ShouldEqual, permanent)
+		So(calls, ShouldEqual, 1)
+	})
+
+	Convey("should stop retrying when the context is done", t, func() {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		calls := 0
+		err := NewPolicy(3, time.Hour, time.Hour).Do(cancelled, retryable, func() error {
+			calls++
+			return errTransient
+		})
+
+		So(err, ShouldEqual, errTransient)
+		So(calls, ShouldEqual, 1)
+	})
+}
+
+func TestTransport(t *testing.T) {
+	Convey("Given an upstream API that fails twice before succeeding", t, func() {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok")) // nolint
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: NewTransport(http.DefaultTransport, NewPolicy(3, time.Millisecond, time.Millisecond))}
+
+		Convey("GET requests are retried", func() {
+			resp, err := client.Get(server.URL)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(calls, ShouldEqual, 3)
+		})
+
+		Convey("POST requests are sent once", func() {
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(calls, ShouldEqual, 1)
+		})
+	})
+
+	Convey("The last response is returned once the attempts are used up", t, func() {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: NewTransport(http.DefaultTransport, NewPolicy(2, time.Millisecond, time.Millisecond))}
+
+		resp, err := client.Get(server.URL)
+
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusTooManyRequests)
+		So(calls, ShouldEqual, 2)
+	})
+}
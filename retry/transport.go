@@ -0,0 +1,62 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var errRetryableStatus = errors.New("retryable response status")
+
+// Transport is an http.RoundTripper that retries idempotent requests (GET and HEAD) that fail with a connection
+// error, a 5xx response or a 429 response. Other requests, such as POSTs creating file events, are sent once.
+type Transport struct {
+	Base   http.RoundTripper
+	Policy Policy
+}
+
+// NewTransport creates a new Transport retrying requests sent with base according to policy
+func NewTransport(base http.RoundTripper, policy Policy) *Transport {
+	return &Transport{
+		Base:   base,
+		Policy: policy,
+	}
+}
+
+// RoundTrip sends the request, retrying idempotent requests. The last response is returned once the attempts are
+// used up, so that callers can handle the failure as if the request had been sent once.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.Base.RoundTrip(req)
+	}
+
+	var resp *http.Response
+
+	// Connection errors and retryable statuses are the only failures of a round trip, so all errors are retried
+	err := t.Policy.Do(req.Context(), func(error) bool { return true }, func() error {
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+
+		var err error
+		resp, err = t.Base.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			return fmt.Errorf("%w: %d", errRetryableStatus, resp.StatusCode)
+		}
+		return nil
+	})
+
+	if errors.Is(err, errRetryableStatus) {
+		return resp, nil
+	}
+	return resp, err
+}
+
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 4096)) // nolint
+	body.Close()                                    // nolint
+}
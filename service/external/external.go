@@ -2,6 +2,7 @@ package external
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -15,12 +16,15 @@ import (
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
 	"github.com/ONSdigital/dp-api-clients-go/v2/filter"
+	"github.com/ONSdigital/dp-api-clients-go/v2/health"
 	"github.com/ONSdigital/dp-api-clients-go/v2/image"
 	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
 
 	"github.com/ONSdigital/dp-download-service/config"
 	"github.com/ONSdigital/dp-download-service/content"
 	"github.com/ONSdigital/dp-download-service/downloads"
+	"github.com/ONSdigital/dp-download-service/files"
+	"github.com/ONSdigital/dp-download-service/retry"
 	"github.com/ONSdigital/dp-download-service/service"
 
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
@@ -28,20 +32,35 @@ import (
 )
 
 // External implements the service.Dependencies interface for actual external services.
-type External struct{}
+// Retry determines how S3 reads and idempotent upstream API requests are retried.
+type External struct {
+	Retry retry.Policy
+}
 
 var _ service.Dependencies = &External{}
 
-func (*External) DatasetClient(datasetAPIURL string) downloads.DatasetClient {
-	return dataset.NewAPIClient(datasetAPIURL)
+// keyIDCacheSize is the maximum number of SSE-C key IDs cached for each bucket
+const keyIDCacheSize = 10000
+
+func (e *External) DatasetClient(datasetAPIURL string) downloads.DatasetClient {
+	return dataset.NewWithHealthClient(e.healthClient("dataset-api", datasetAPIURL))
+}
+
+func (e *External) FilesClient(filesAPIURL string) downloads.FilesClient {
+	return &filesClient{filesAPISDK.NewWithHealthClient(e.healthClient("dp-files-api", filesAPIURL))}
 }
 
-func (*External) FilesClient(filesAPIURL string) downloads.FilesClient {
-	return filesAPISDK.New(filesAPIURL)
+func (e *External) FilterClient(filterAPIURL string) downloads.FilterClient {
+	return filter.NewWithHealthClient(e.healthClient("filter-api", filterAPIURL))
 }
 
-func (*External) FilterClient(filterAPIURL string) downloads.FilterClient {
-	return filter.New(filterAPIURL)
+// healthClient creates an API client that retries GET and HEAD requests with the retry policy. The dp-net retries
+// are disabled, as they would also repeat requests that are not idempotent.
+func (e *External) healthClient(service, url string) *health.Client {
+	clienter := dphttp.NewClientWithTransport(retry.NewTransport(dphttp.DefaultTransport, e.Retry))
+	clienter.SetMaxRetries(0)
+
+	return health.NewClientWithClienter(service, url, clienter)
 }
 
 func (*External) AuthMiddleware(ctx context.Context, cfg *config.Config) (auth.Middleware, error) {
@@ -52,35 +71,133 @@ func (*External) AuthMiddleware(ctx context.Context, cfg *config.Config) (auth.M
 	return authMiddleware, nil
 }
 
-func (*External) ImageClient(imageAPIURL string) downloads.ImageClient {
-	return image.NewAPIClient(imageAPIURL)
+func (e *External) ImageClient(imageAPIURL string) downloads.ImageClient {
+	return image.NewWithHealthClient(e.healthClient("image-api", imageAPIURL))
 }
 
-// S3Client obtains a new S3 client, or a local storage client if a non-empty LocalObjectStore is provided
-func (*External) S3Client(ctx context.Context, cfg *config.Config) (content.S3Client, error) {
-	if cfg.LocalObjectStore != "" {
-		awsCfg, err := awsConfig.LoadDefaultConfig(ctx,
-			awsConfig.WithRegion(cfg.AwsRegion),
-			awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.MinioAccessKey, cfg.MinioSecretKey, "")),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("could not create aws config: %w", err)
+// S3Client obtains a new S3 client, or a local storage client if a non-empty LocalObjectStore is provided.
+// If a SecondaryBucketName is configured, reads fail over to that bucket according to S3FailoverPolicy.
+// Objects matching an envelope encryption rule are decrypted with keys from the EnvelopeKeysFile.
+func (e *External) S3Client(ctx context.Context, cfg *config.Config) (content.S3Client, error) {
+	rules, err := content.ParseEncryptionRules(cfg.S3EncryptionMode, cfg.S3EncryptionRules)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse s3 encryption rules: %w", err)
+	}
+
+	s3c, err := e.newReplicatedS3Client(ctx, cfg, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if !usesEnvelopeEncryption(rules) {
+		return s3c, nil
+	}
+
+	if cfg.EnvelopeKeysFile == "" {
+		return nil, errors.New("an envelope keys file is required for envelope encryption")
+	}
+
+	keys, err := content.NewFileKeyProvider(cfg.EnvelopeKeysFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load envelope keys: %w", err)
+	}
+
+	return content.NewEnvelopeS3Client(s3c, keys, rules), nil
+}
+
+func usesEnvelopeEncryption(rules []content.EncryptionRule) bool {
+	for _, r := range rules {
+		if r.Mode == content.EncryptionEnvelope {
+			return true
 		}
+	}
+	return false
+}
 
-		client := s3client.NewClientWithConfig(cfg.BucketName, awsCfg, func(o *s3.Options) {
+func (e *External) newReplicatedS3Client(ctx context.Context, cfg *config.Config, rules []content.EncryptionRule) (content.S3Client, error) {
+	primary, err := e.newS3Client(ctx, cfg, cfg.BucketName, cfg.AwsRegion, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SecondaryBucketName == "" {
+		return primary, nil
+	}
+
+	region := cfg.SecondaryAwsRegion
+	if region == "" {
+		region = cfg.AwsRegion
+	}
+
+	secondary, err := e.newS3Client(ctx, cfg, cfg.SecondaryBucketName, region, rules)
+	if err != nil {
+		return nil, fmt.Errorf("could not create secondary s3 client: %w", err)
+	}
+
+	return content.NewFailoverS3Client(primary, secondary, content.FailoverPolicy(cfg.S3FailoverPolicy), cfg.S3PrimaryTimeout), nil
+}
+
+func (e *External) newS3Client(ctx context.Context, cfg *config.Config, bucketName, region string, rules []content.EncryptionRule) (content.S3Client, error) {
+	awsCfg, s3Opts, err := loadAWSConfig(ctx, cfg, region)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := s3.NewFromConfig(awsCfg, s3Opts...)
+	client := content.NewRangedS3Client(s3client.NewClientWithConfig(bucketName, awsCfg, s3Opts...), objects, bucketName)
+
+	if !usesCustomerKeys(rules) {
+		return content.NewRetryingS3Client(client, e.Retry), nil
+	}
+
+	encrypted, err := content.NewEncryptedS3Client(client, objects, bucketName, cfg.SecretKey, rules)
+	if err != nil {
+		return nil, err
+	}
+	keyIDs := files.FetchEncryptionKeyID(e.healthClient("dp-files-api", cfg.FilesAPIURL).Client, cfg.FilesAPIURL, cfg.ServiceAuthToken)
+	encrypted.KeyIDs = content.CacheKeyIDs(keyIDs, cfg.KeyIDCacheTTL, keyIDCacheSize)
+
+	return content.NewRetryingS3Client(encrypted, e.Retry), nil
+}
+
+// loadAWSConfig loads the AWS config for a region, using the local object store instead of S3 if one is configured
+func loadAWSConfig(ctx context.Context, cfg *config.Config, region string) (aws.Config, []func(*s3.Options), error) {
+	awsOpts := []func(*awsConfig.LoadOptions) error{awsConfig.WithRegion(region)}
+	var s3Opts []func(*s3.Options)
+
+	if cfg.LocalObjectStore != "" {
+		awsOpts = append(awsOpts, awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.MinioAccessKey, cfg.MinioSecretKey, "")))
+		s3Opts = append(s3Opts, func(o *s3.Options) {
 			o.BaseEndpoint = aws.String(cfg.LocalObjectStore)
 			o.UsePathStyle = true
 		})
+	}
 
-		return client, nil
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx, awsOpts...)
+	if err != nil {
+		return aws.Config{}, nil, fmt.Errorf("could not create aws config: %w", err)
 	}
 
-	client, err := s3client.NewClient(ctx, cfg.AwsRegion, cfg.BucketName)
+	return awsCfg, s3Opts, nil
+}
+
+// ImageCache obtains a cache for resized and converted images in the ImageCacheBucketName bucket
+func (*External) ImageCache(ctx context.Context, cfg *config.Config) (content.Cache, error) {
+	awsCfg, s3Opts, err := loadAWSConfig(ctx, cfg, cfg.AwsRegion)
 	if err != nil {
-		return nil, fmt.Errorf("could not create s3 client: %w", err)
+		return nil, err
 	}
 
-	return client, nil
+	return content.NewS3Cache(s3.NewFromConfig(awsCfg, s3Opts...), cfg.ImageCacheBucketName), nil
+}
+
+func usesCustomerKeys(rules []content.EncryptionRule) bool {
+	for _, r := range rules {
+		if r.Mode != content.EncryptionNone && r.Mode != content.EncryptionEnvelope {
+			return true
+		}
+	}
+	return false
 }
 
 func (*External) HealthCheck(cfg *config.Config, buildTime, gitCommit, version string) (service.HealthChecker, error) {
@@ -0,0 +1,81 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	filesAPI "github.com/ONSdigital/dp-files-api/api"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// filesClient adds the listing of the files in a collection or bundle, which the files API SDK does not provide yet
+type filesClient struct {
+	*filesAPISDK.Client
+}
+
+// GetFiles returns the metadata of every file in a collection or bundle, reading each page of the listing in turn.
+// Only one of collectionID and bundleID should be given.
+func (c *filesClient) GetFiles(ctx context.Context, collectionID, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+	query := url.Values{}
+	if collectionID != "" {
+		query.Set("collection_id", collectionID)
+	}
+	if bundleID != "" {
+		query.Set("bundle_id", bundleID)
+	}
+
+	var items []filesAPIModels.StoredRegisteredMetaData
+	for {
+		query.Set("offset", strconv.Itoa(len(items)))
+
+		page, err := c.getFilesPage(ctx, query, headers)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+
+		// A page without items would never reach the total, so it is taken as the end of the listing
+		if page.Count == 0 || len(page.Items) == 0 || page.Offset+page.Count >= page.TotalCount {
+			return items, nil
+		}
+	}
+}
+
+func (c *filesClient) getFilesPage(ctx context.Context, query url.Values, headers filesAPISDK.Headers) (*filesAPI.FilesCollection, error) {
+	req, err := http.NewRequest(http.MethodGet, c.URL()+"/files?"+query.Encode(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	headers.Add(req)
+
+	resp, err := c.Health().Client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Error(ctx, "error closing http response body", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		var jsonErrors filesAPI.JSONErrors
+		if err := json.NewDecoder(resp.Body).Decode(&jsonErrors); err != nil {
+			return nil, &filesAPISDK.APIError{StatusCode: resp.StatusCode}
+		}
+		return nil, &filesAPISDK.APIError{StatusCode: resp.StatusCode, Errors: &jsonErrors}
+	}
+
+	var collection filesAPI.FilesCollection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
@@ -0,0 +1,69 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	filesAPI "github.com/ONSdigital/dp-files-api/api"
+	filesAPIModels "github.com/ONSdigital/dp-files-api/files"
+	filesAPISDK "github.com/ONSdigital/dp-files-api/sdk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// pagedFilesAPI is a stand-in for the files API that lists the files of a collection a page at a time
+func pagedFilesAPI(paths []string, pageSize int, offsets *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*offsets = append(*offsets, r.URL.Query().Get("offset"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+		end := min(offset+pageSize, len(paths))
+		collection := filesAPI.FilesCollection{
+			Count:      int64(end - offset),
+			Limit:      int64(pageSize),
+			Offset:     int64(offset),
+			TotalCount: int64(len(paths)),
+			Items:      []filesAPIModels.StoredRegisteredMetaData{},
+		}
+		for _, p := range paths[offset:end] {
+			collection.Items = append(collection.Items, filesAPIModels.StoredRegisteredMetaData{Path: p})
+		}
+
+		json.NewEncoder(w).Encode(collection) // nolint
+	}
+}
+
+func TestGetFiles(t *testing.T) {
+	ctx := context.Background()
+	paths := []string{"data/1.csv", "data/2.csv", "data/3.csv", "data/4.csv", "data/5.csv"}
+
+	Convey("Given a collection listed over several pages then every page is read", t, func() {
+		var offsets []string
+		server := httptest.NewServer(pagedFilesAPI(paths, 2, &offsets))
+		defer server.Close()
+
+		listed, err := (&External{}).FilesClient(server.URL).GetFiles(ctx, "collection", "", filesAPISDK.Headers{})
+
+		So(err, ShouldBeNil)
+		So(offsets, ShouldResemble, []string{"0", "2", "4"})
+		So(listed, ShouldHaveLength, len(paths))
+		for i, m := range listed {
+			So(m.Path, ShouldEqual, paths[i])
+		}
+	})
+
+	Convey("Given a collection listed on a single page then only one page is read", t, func() {
+		var offsets []string
+		server := httptest.NewServer(pagedFilesAPI(paths, 10, &offsets))
+		defer server.Close()
+
+		listed, err := (&External{}).FilesClient(server.URL).GetFiles(ctx, "collection", "", filesAPISDK.Headers{})
+
+		So(err, ShouldBeNil)
+		So(offsets, ShouldResemble, []string{"0"})
+		So(listed, ShouldHaveLength, len(paths))
+	})
+}
@@ -28,6 +28,12 @@ var _ downloads.DatasetClient = &DatasetClientMock{}
 //			CheckerFunc: func(ctx context.Context, check *healthcheck.CheckState) error {
 //				panic("mock out the Checker method")
 //			},
+//			GetDatasetCurrentAndNextFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.Dataset, error) {
+//				panic("mock out the GetDatasetCurrentAndNext method")
+//			},
+//			GetFullEditionsDetailsFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) ([]dataset.EditionsDetails, error) {
+//				panic("mock out the GetFullEditionsDetails method")
+//			},
 //			GetInstanceFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
 //				panic("mock out the GetInstance method")
 //			},
@@ -44,6 +50,12 @@ type DatasetClientMock struct {
 	// CheckerFunc mocks the Checker method.
 	CheckerFunc func(ctx context.Context, check *healthcheck.CheckState) error
 
+	// GetDatasetCurrentAndNextFunc mocks the GetDatasetCurrentAndNext method.
+	GetDatasetCurrentAndNextFunc func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.Dataset, error)
+
+	// GetFullEditionsDetailsFunc mocks the GetFullEditionsDetails method.
+	GetFullEditionsDetailsFunc func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) ([]dataset.EditionsDetails, error)
+
 	// GetInstanceFunc mocks the GetInstance method.
 	GetInstanceFunc func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error)
 
@@ -59,6 +71,32 @@ type DatasetClientMock struct {
 			// Check is the check argument value.
 			Check *healthcheck.CheckState
 		}
+		// GetDatasetCurrentAndNext holds details about calls to the GetDatasetCurrentAndNext method.
+		GetDatasetCurrentAndNext []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserAuthToken is the userAuthToken argument value.
+			UserAuthToken string
+			// ServiceAuthToken is the serviceAuthToken argument value.
+			ServiceAuthToken string
+			// CollectionID is the collectionID argument value.
+			CollectionID string
+			// DatasetID is the datasetID argument value.
+			DatasetID string
+		}
+		// GetFullEditionsDetails holds details about calls to the GetFullEditionsDetails method.
+		GetFullEditionsDetails []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserAuthToken is the userAuthToken argument value.
+			UserAuthToken string
+			// ServiceAuthToken is the serviceAuthToken argument value.
+			ServiceAuthToken string
+			// CollectionID is the collectionID argument value.
+			CollectionID string
+			// DatasetID is the datasetID argument value.
+			DatasetID string
+		}
 		// GetInstance holds details about calls to the GetInstance method.
 		GetInstance []struct {
 			// Ctx is the ctx argument value.
@@ -94,9 +132,11 @@ type DatasetClientMock struct {
 			Version string
 		}
 	}
-	lockChecker     sync.RWMutex
-	lockGetInstance sync.RWMutex
-	lockGetVersion  sync.RWMutex
+	lockChecker                  sync.RWMutex
+	lockGetDatasetCurrentAndNext sync.RWMutex
+	lockGetFullEditionsDetails   sync.RWMutex
+	lockGetInstance              sync.RWMutex
+	lockGetVersion               sync.RWMutex
 }
 
 // Checker calls CheckerFunc.
@@ -135,6 +175,102 @@ func (mock *DatasetClientMock) CheckerCalls() []struct {
 	return calls
 }
 
+// GetDatasetCurrentAndNext calls GetDatasetCurrentAndNextFunc.
+func (mock *DatasetClientMock) GetDatasetCurrentAndNext(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.Dataset, error) {
+	if mock.GetDatasetCurrentAndNextFunc == nil {
+		panic("DatasetClientMock.GetDatasetCurrentAndNextFunc: method is nil but DatasetClient.GetDatasetCurrentAndNext was just called")
+	}
+	callInfo := struct {
+		Ctx              context.Context
+		UserAuthToken    string
+		ServiceAuthToken string
+		CollectionID     string
+		DatasetID        string
+	}{
+		Ctx:              ctx,
+		UserAuthToken:    userAuthToken,
+		ServiceAuthToken: serviceAuthToken,
+		CollectionID:     collectionID,
+		DatasetID:        datasetID,
+	}
+	mock.lockGetDatasetCurrentAndNext.Lock()
+	mock.calls.GetDatasetCurrentAndNext = append(mock.calls.GetDatasetCurrentAndNext, callInfo)
+	mock.lockGetDatasetCurrentAndNext.Unlock()
+	return mock.GetDatasetCurrentAndNextFunc(ctx, userAuthToken, serviceAuthToken, collectionID, datasetID)
+}
+
+// GetDatasetCurrentAndNextCalls gets all the calls that were made to GetDatasetCurrentAndNext.
+// Check the length with:
+//
+//	len(mockedDatasetClient.GetDatasetCurrentAndNextCalls())
+func (mock *DatasetClientMock) GetDatasetCurrentAndNextCalls() []struct {
+	Ctx              context.Context
+	UserAuthToken    string
+	ServiceAuthToken string
+	CollectionID     string
+	DatasetID        string
+} {
+	var calls []struct {
+		Ctx              context.Context
+		UserAuthToken    string
+		ServiceAuthToken string
+		CollectionID     string
+		DatasetID        string
+	}
+	mock.lockGetDatasetCurrentAndNext.RLock()
+	calls = mock.calls.GetDatasetCurrentAndNext
+	mock.lockGetDatasetCurrentAndNext.RUnlock()
+	return calls
+}
+
+// GetFullEditionsDetails calls GetFullEditionsDetailsFunc.
+func (mock *DatasetClientMock) GetFullEditionsDetails(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) ([]dataset.EditionsDetails, error) {
+	if mock.GetFullEditionsDetailsFunc == nil {
+		panic("DatasetClientMock.GetFullEditionsDetailsFunc: method is nil but DatasetClient.GetFullEditionsDetails was just called")
+	}
+	callInfo := struct {
+		Ctx              context.Context
+		UserAuthToken    string
+		ServiceAuthToken string
+		CollectionID     string
+		DatasetID        string
+	}{
+		Ctx:              ctx,
+		UserAuthToken:    userAuthToken,
+		ServiceAuthToken: serviceAuthToken,
+		CollectionID:     collectionID,
+		DatasetID:        datasetID,
+	}
+	mock.lockGetFullEditionsDetails.Lock()
+	mock.calls.GetFullEditionsDetails = append(mock.calls.GetFullEditionsDetails, callInfo)
+	mock.lockGetFullEditionsDetails.Unlock()
+	return mock.GetFullEditionsDetailsFunc(ctx, userAuthToken, serviceAuthToken, collectionID, datasetID)
+}
+
+// GetFullEditionsDetailsCalls gets all the calls that were made to GetFullEditionsDetails.
+// Check the length with:
+//
+//	len(mockedDatasetClient.GetFullEditionsDetailsCalls())
+func (mock *DatasetClientMock) GetFullEditionsDetailsCalls() []struct {
+	Ctx              context.Context
+	UserAuthToken    string
+	ServiceAuthToken string
+	CollectionID     string
+	DatasetID        string
+} {
+	var calls []struct {
+		Ctx              context.Context
+		UserAuthToken    string
+		ServiceAuthToken string
+		CollectionID     string
+		DatasetID        string
+	}
+	mock.lockGetFullEditionsDetails.RLock()
+	calls = mock.calls.GetFullEditionsDetails
+	mock.lockGetFullEditionsDetails.RUnlock()
+	return calls
+}
+
 // GetInstance calls GetInstanceFunc.
 func (mock *DatasetClientMock) GetInstance(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
 	if mock.GetInstanceFunc == nil {
@@ -266,6 +402,9 @@ var _ downloads.FilesClient = &FilesClientMock{}
 //			GetFileFunc: func(ctx context.Context, filePath string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error) {
 //				panic("mock out the GetFile method")
 //			},
+//			GetFilesFunc: func(ctx context.Context, collectionID string, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+//				panic("mock out the GetFiles method")
+//			},
 //		}
 //
 //		// use mockedFilesClient in code that requires downloads.FilesClient
@@ -282,6 +421,9 @@ type FilesClientMock struct {
 	// GetFileFunc mocks the GetFile method.
 	GetFileFunc func(ctx context.Context, filePath string, headers filesAPISDK.Headers) (*filesAPIModels.StoredRegisteredMetaData, error)
 
+	// GetFilesFunc mocks the GetFiles method.
+	GetFilesFunc func(ctx context.Context, collectionID string, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Checker holds details about calls to the Checker method.
@@ -309,10 +451,22 @@ type FilesClientMock struct {
 			// Headers is the headers argument value.
 			Headers filesAPISDK.Headers
 		}
+		// GetFiles holds details about calls to the GetFiles method.
+		GetFiles []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// CollectionID is the collectionID argument value.
+			CollectionID string
+			// BundleID is the bundleID argument value.
+			BundleID string
+			// Headers is the headers argument value.
+			Headers filesAPISDK.Headers
+		}
 	}
 	lockChecker         sync.RWMutex
 	lockCreateFileEvent sync.RWMutex
 	lockGetFile         sync.RWMutex
+	lockGetFiles        sync.RWMutex
 }
 
 // Checker calls CheckerFunc.
@@ -431,6 +585,50 @@ func (mock *FilesClientMock) GetFileCalls() []struct {
 	return calls
 }
 
+// GetFiles calls GetFilesFunc.
+func (mock *FilesClientMock) GetFiles(ctx context.Context, collectionID string, bundleID string, headers filesAPISDK.Headers) ([]filesAPIModels.StoredRegisteredMetaData, error) {
+	if mock.GetFilesFunc == nil {
+		panic("FilesClientMock.GetFilesFunc: method is nil but FilesClient.GetFiles was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		CollectionID string
+		BundleID     string
+		Headers      filesAPISDK.Headers
+	}{
+		Ctx:          ctx,
+		CollectionID: collectionID,
+		BundleID:     bundleID,
+		Headers:      headers,
+	}
+	mock.lockGetFiles.Lock()
+	mock.calls.GetFiles = append(mock.calls.GetFiles, callInfo)
+	mock.lockGetFiles.Unlock()
+	return mock.GetFilesFunc(ctx, collectionID, bundleID, headers)
+}
+
+// GetFilesCalls gets all the calls that were made to GetFiles.
+// Check the length with:
+//
+//	len(mockedFilesClient.GetFilesCalls())
+func (mock *FilesClientMock) GetFilesCalls() []struct {
+	Ctx          context.Context
+	CollectionID string
+	BundleID     string
+	Headers      filesAPISDK.Headers
+} {
+	var calls []struct {
+		Ctx          context.Context
+		CollectionID string
+		BundleID     string
+		Headers      filesAPISDK.Headers
+	}
+	mock.lockGetFiles.RLock()
+	calls = mock.calls.GetFiles
+	mock.lockGetFiles.RUnlock()
+	return calls
+}
+
 // Ensure, that FilterClientMock does implement downloads.FilterClient.
 // If this is not the case, regenerate this file with moq.
 var _ downloads.FilterClient = &FilterClientMock{}
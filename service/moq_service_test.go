@@ -43,6 +43,9 @@ var _ service.Dependencies = &DependenciesMock{}
 //			HealthCheckFunc: func(configMoqParam *config.Config, s1 string, s2 string, s3 string) (service.HealthChecker, error) {
 //				panic("mock out the HealthCheck method")
 //			},
+//			ImageCacheFunc: func(contextMoqParam context.Context, configMoqParam *config.Config) (content.Cache, error) {
+//				panic("mock out the ImageCache method")
+//			},
 //			ImageClientFunc: func(s string) downloads.ImageClient {
 //				panic("mock out the ImageClient method")
 //			},
@@ -74,6 +77,9 @@ type DependenciesMock struct {
 	// HealthCheckFunc mocks the HealthCheck method.
 	HealthCheckFunc func(configMoqParam *config.Config, s1 string, s2 string, s3 string) (service.HealthChecker, error)
 
+	// ImageCacheFunc mocks the ImageCache method.
+	ImageCacheFunc func(contextMoqParam context.Context, configMoqParam *config.Config) (content.Cache, error)
+
 	// ImageClientFunc mocks the ImageClient method.
 	ImageClientFunc func(s string) downloads.ImageClient
 
@@ -122,6 +128,13 @@ type DependenciesMock struct {
 			// S3 is the s3 argument value.
 			S3 string
 		}
+		// ImageCache holds details about calls to the ImageCache method.
+		ImageCache []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// ConfigMoqParam is the configMoqParam argument value.
+			ConfigMoqParam *config.Config
+		}
 		// ImageClient holds details about calls to the ImageClient method.
 		ImageClient []struct {
 			// S is the s argument value.
@@ -141,6 +154,7 @@ type DependenciesMock struct {
 	lockFilterClient   sync.RWMutex
 	lockHTTPServer     sync.RWMutex
 	lockHealthCheck    sync.RWMutex
+	lockImageCache     sync.RWMutex
 	lockImageClient    sync.RWMutex
 	lockS3Client       sync.RWMutex
 }
@@ -357,6 +371,42 @@ func (mock *DependenciesMock) HealthCheckCalls() []struct {
 	return calls
 }
 
+// ImageCache calls ImageCacheFunc.
+func (mock *DependenciesMock) ImageCache(contextMoqParam context.Context, configMoqParam *config.Config) (content.Cache, error) {
+	if mock.ImageCacheFunc == nil {
+		panic("DependenciesMock.ImageCacheFunc: method is nil but Dependencies.ImageCache was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		ConfigMoqParam  *config.Config
+	}{
+		ContextMoqParam: contextMoqParam,
+		ConfigMoqParam:  configMoqParam,
+	}
+	mock.lockImageCache.Lock()
+	mock.calls.ImageCache = append(mock.calls.ImageCache, callInfo)
+	mock.lockImageCache.Unlock()
+	return mock.ImageCacheFunc(contextMoqParam, configMoqParam)
+}
+
+// ImageCacheCalls gets all the calls that were made to ImageCache.
+// Check the length with:
+//
+//	len(mockedDependencies.ImageCacheCalls())
+func (mock *DependenciesMock) ImageCacheCalls() []struct {
+	ContextMoqParam context.Context
+	ConfigMoqParam  *config.Config
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		ConfigMoqParam  *config.Config
+	}
+	mock.lockImageCache.RLock()
+	calls = mock.calls.ImageCache
+	mock.lockImageCache.RUnlock()
+	return calls
+}
+
 // ImageClient calls ImageClientFunc.
 func (mock *DependenciesMock) ImageClient(s string) downloads.ImageClient {
 	if mock.ImageClientFunc == nil {
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"expvar"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/ONSdigital/dp-download-service/handlers"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	dphandlers "github.com/ONSdigital/dp-net/v3/handlers"
+	dphttp "github.com/ONSdigital/dp-net/v3/http"
 	"github.com/ONSdigital/log.go/v2/log"
 	gorillahandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -53,6 +56,7 @@ type Dependencies interface {
 	FilterClient(string) downloads.FilterClient
 	ImageClient(string) downloads.ImageClient
 	S3Client(context.Context, *config.Config) (content.S3Client, error)
+	ImageCache(context.Context, *config.Config) (content.Cache, error)
 	HealthCheck(*config.Config, string, string, string) (HealthChecker, error)
 	HTTPServer(*config.Config, http.Handler) HTTPServer
 	AuthMiddleware(context.Context, *config.Config) (authorisation.Middleware, error)
@@ -126,19 +130,64 @@ func New(ctx context.Context, buildTime, gitCommit, version string, cfg *config.
 		FilterCli:  svc.filterClient,
 		DatasetCli: svc.datasetClient,
 		ImageCli:   svc.imageClient,
+		Variants: map[string][]string{
+			"xls":  cfg.XLSVariants,
+			"xlsx": cfg.XLSXVariants,
+		},
 	}
 	s3c := content.NewStreamWriter(s3)
 
+	// Resized images and computed checksums are cached in the same bucket
+	var cache content.Cache
+	if cfg.ImageCacheBucketName != "" {
+		if cache, err = deps.ImageCache(ctx, cfg); err != nil {
+			log.Error(ctx, "could not create the image cache", err)
+			return nil, err
+		}
+		s3c.Cache = cache
+	}
+
 	d := handlers.Download{
-		Downloader:   downloader,
-		S3Content:    s3c,
-		IsPublishing: cfg.IsPublishing,
+		Downloader:                downloader,
+		S3Content:                 s3c,
+		IsPublishing:              cfg.IsPublishing,
+		PreviewMaxRows:            cfg.PreviewMaxRows,
+		PreviewMaxBytes:           cfg.PreviewMaxBytes,
+		RowGroupBytes:             cfg.ParquetRowGroupBytes,
+		ImageSizes:                cfg.ImageSizes,
+		ImageMaxPixels:            cfg.ImageMaxPixels,
+		LicenceURL:                cfg.DefaultLicenceURL,
+		ProvenanceHeaders:         cfg.ProvenanceHeaders,
+		InlineDisposition:         cfg.InlineDisposition,
+		LatestMaxAge:              cfg.LatestMaxAge,
+		LatestIncludesUnpublished: cfg.LatestIncludesUnpublished,
+		WatermarkPreRelease:       cfg.WatermarkPreRelease,
+	}
+	if cache != nil {
+		d.ImageCache = cache
 	}
 
+	// Probing for precompressed variants costs a request to the bucket for each accepted encoding that is missing
+	var downloadVariant files.FileVariantDownloader
+	if cfg.ServePrecompressed {
+		downloadVariant = files.DownloadFileVariant(ctx, svc.s3Client)
+	}
+
+	// The files API does not return when a file is released, so the release date of its dataset version is used
+	fetchRelease := files.FetchReleaseDate(svc.datasetClient, cfg.ServiceAuthToken, cfg.DownloadServiceToken)
+	fetchWithdrawal := files.FetchWithdrawal(dphttp.NewClient(), cfg.FilesAPIURL)
+
+	downloadMovedFile := files.DownloadMovedFile(ctx, dphttp.NewClient(), func(filePath string) string { return api.RedirectLocation(cfg, filePath) })
+
 	downloadHandlerWithAuth := api.CreateDownloadHandlerWithAuth(
 		files.FetchMetadata(svc.filesClient),
 		files.DownloadFile(ctx, svc.s3Client),
+		files.DownloadFileRange(ctx, svc.s3Client),
+		downloadVariant,
+		downloadMovedFile,
 		files.CreateFileEvent(svc.filesClient),
+		fetchRelease,
+		time.Now,
 		svc.authMiddleware,
 		cfg,
 		svc.permissionsChecker,
@@ -147,49 +196,217 @@ func New(ctx context.Context, buildTime, gitCommit, version string, cfg *config.
 	downloadHandlerNoAuth := api.CreateDownloadHandlerNoAuth(
 		files.FetchMetadata(svc.filesClient),
 		files.DownloadFile(ctx, svc.s3Client),
+		files.DownloadFileRange(ctx, svc.s3Client),
+		downloadVariant,
+		downloadMovedFile,
+		fetchWithdrawal,
+		fetchRelease,
+		time.Now,
+		cfg,
+	)
+
+	bundleHandler := api.CreateBundleHandler(
+		files.FetchMetadata(svc.filesClient),
+		files.DownloadFile(ctx, svc.s3Client),
+		downloadMovedFile,
+		files.CreateFileEvent(svc.filesClient),
+		fetchRelease,
+		time.Now,
+		svc.authMiddleware,
 		cfg,
+		svc.permissionsChecker,
+	)
+
+	previewHandler := api.CreatePreviewHandler(
+		files.FetchMetadata(svc.filesClient),
+		files.DownloadFileRange(ctx, svc.s3Client),
+		downloadMovedFile,
+		files.CreateFileEvent(svc.filesClient),
+		fetchWithdrawal,
+		fetchRelease,
+		time.Now,
+		svc.authMiddleware,
+		cfg,
+		svc.permissionsChecker,
+	)
+
+	checksumHandler := api.CreateChecksumHandler(
+		files.FetchMetadata(svc.filesClient),
+		files.FetchChecksum(svc.s3Client, cache, downloadMovedFile),
+		fetchWithdrawal,
+		fetchRelease,
+		time.Now,
+		svc.authMiddleware,
+		cfg,
+		svc.permissionsChecker,
 	)
 
+	metadataHandler := api.CreateMetadataHandler(
+		files.FetchMetadata(svc.filesClient),
+		fetchWithdrawal,
+		fetchRelease,
+		time.Now,
+		svc.authMiddleware,
+		cfg,
+		svc.permissionsChecker,
+	)
+
+	archiveHandler := func(owner string) http.HandlerFunc {
+		return api.CreateArchiveHandler(
+			owner,
+			files.ListFiles(svc.filesClient),
+			files.DownloadFile(ctx, svc.s3Client),
+			downloadMovedFile,
+			files.CreateFileEvent(svc.filesClient),
+			svc.authMiddleware,
+			cfg,
+			svc.permissionsChecker,
+		)
+	}
+
+	// Legacy routes are switched over to the download handlers of files registered with the files API one at a time.
+	// Legacy downloads keep their private copy once they have a public link, so moved files are read from the bucket.
+	legacyFileHandler := func(fetchMetadata files.MetadataFetcher) http.HandlerFunc {
+		if cfg.IsPublishing {
+			return api.CreateDownloadHandlerWithAuth(
+				fetchMetadata,
+				files.DownloadFile(ctx, svc.s3Client),
+				nil,
+				nil,
+				files.DownloadFile(ctx, svc.s3Client),
+				files.CreateFileEvent(svc.filesClient),
+				fetchRelease,
+				time.Now,
+				svc.authMiddleware,
+				cfg,
+				svc.permissionsChecker,
+			)
+		}
+		return api.CreateDownloadHandlerNoAuth(
+			fetchMetadata,
+			files.DownloadFile(ctx, svc.s3Client),
+			nil,
+			nil,
+			files.DownloadFile(ctx, svc.s3Client),
+			fetchWithdrawal,
+			fetchRelease,
+			time.Now,
+			cfg,
+		)
+	}
+
+	legacyDownload := func(route string, fileType downloads.FileType, extension string, legacy http.HandlerFunc) http.HandlerFunc {
+		if cfg.UsesFilesPipeline(route) {
+			return api.CreateLegacyDownloadHandler(d.Resolve(fileType, extension, cfg.ServiceAuthToken, cfg.DownloadServiceToken), legacyFileHandler, cfg)
+		}
+		if cfg.IsPublishing {
+			return svc.authMiddleware.Require("static-files:read", legacy)
+		}
+		return legacy
+	}
+
+	// The latest edition and version aliases of dataset version routes are redirected to the version they resolve to.
+	// In publishing the redirect is behind the permission check, so that only authorised users can find out which
+	// version is the latest; other requests go straight to h, which checks permissions itself.
+	latest := func(h http.HandlerFunc) http.HandlerFunc {
+		redirect := d.RedirectLatest(h, cfg.ServiceAuthToken, cfg.DownloadServiceToken)
+		if !cfg.IsPublishing {
+			return redirect
+		}
+
+		redirect = svc.authMiddleware.Require("static-files:read", redirect)
+		return func(w http.ResponseWriter, req *http.Request) {
+			vars := mux.Vars(req)
+			if vars["edition"] == downloads.Latest || vars["version"] == downloads.Latest {
+				redirect(w, req)
+				return
+			}
+			h(w, req)
+		}
+	}
+
+	// Resized and converted images are always handled by the legacy image handler
+	imageHandler := d.DoImage(cfg.ServiceAuthToken, cfg.DownloadServiceToken)
+	if cfg.UsesFilesPipeline("images") {
+		imageVariantHandler := api.CreateLegacyDownloadHandler(d.Resolve(downloads.TypeImage, "", cfg.ServiceAuthToken, cfg.DownloadServiceToken), legacyFileHandler, cfg)
+		imageHandler = d.DoImageWith(imageVariantHandler, cfg.ServiceAuthToken, cfg.DownloadServiceToken)
+	}
+
 	// The 'Do' functions eventually get to the S3 bucket, which is all of them except the V1 downloader
 	// And tie routes to download handler methods.
 	router := mux.NewRouter()
 
 	if cfg.IsPublishing {
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersion("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv-metadata.json").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersion("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.txt").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersion("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xls").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersion("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xlsx").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersion("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.csv").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoFilterOutput("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.xls").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoFilterOutput("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.xlsx").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoFilterOutput("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.txt").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoFilterOutput("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.csv-metadata.json").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoFilterOutput("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
-		router.Path("/images/{imageID}/{variant}/{filename}").HandlerFunc(svc.authMiddleware.Require("static-files:read", d.DoImage(cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv").HandlerFunc(latest(legacyDownload("datasets.csv", downloads.TypeDatasetVersion, "csv", d.DoDatasetVersion("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv/preview").HandlerFunc(latest(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersionPreview(cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv-metadata.json").HandlerFunc(latest(legacyDownload("datasets.csv-metadata.json", downloads.TypeDatasetVersion, "csvw", d.DoDatasetVersion("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.txt").HandlerFunc(latest(legacyDownload("datasets.txt", downloads.TypeDatasetVersion, "txt", d.DoDatasetVersion("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xls").HandlerFunc(latest(legacyDownload("datasets.xls", downloads.TypeDatasetVersion, "xls", d.DoDatasetVersion("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xlsx").HandlerFunc(latest(legacyDownload("datasets.xlsx", downloads.TypeDatasetVersion, "xlsx", d.DoDatasetVersion("xlsx", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv.sha256").HandlerFunc(latest(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersionChecksum("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv-metadata.json.sha256").HandlerFunc(latest(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersionChecksum("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.txt.sha256").HandlerFunc(latest(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersionChecksum("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xls.sha256").HandlerFunc(latest(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersionChecksum("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xlsx.sha256").HandlerFunc(latest(svc.authMiddleware.Require("static-files:read", d.DoDatasetVersionChecksum("xlsx", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.csv").HandlerFunc(legacyDownload("filter-outputs.csv", downloads.TypeFilterOutput, "csv", d.DoFilterOutput("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.xls").HandlerFunc(legacyDownload("filter-outputs.xls", downloads.TypeFilterOutput, "xls", d.DoFilterOutput("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.xlsx").HandlerFunc(legacyDownload("filter-outputs.xlsx", downloads.TypeFilterOutput, "xlsx", d.DoFilterOutput("xlsx", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.txt").HandlerFunc(legacyDownload("filter-outputs.txt", downloads.TypeFilterOutput, "txt", d.DoFilterOutput("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.csv-metadata.json").HandlerFunc(legacyDownload("filter-outputs.csv-metadata.json", downloads.TypeFilterOutput, "csvw", d.DoFilterOutput("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/instances/{instanceID}.csv").HandlerFunc(legacyDownload("instances.csv", downloads.TypeInstance, "csv", d.DoInstance("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/instances/{instanceID}.csv-metadata.json").HandlerFunc(legacyDownload("instances.csv-metadata.json", downloads.TypeInstance, "csvw", d.DoInstance("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/instances/{instanceID}.txt").HandlerFunc(legacyDownload("instances.txt", downloads.TypeInstance, "txt", d.DoInstance("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/instances/{instanceID}.xls").HandlerFunc(legacyDownload("instances.xls", downloads.TypeInstance, "xls", d.DoInstance("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/instances/{instanceID}.xlsx").HandlerFunc(legacyDownload("instances.xlsx", downloads.TypeInstance, "xlsx", d.DoInstance("xlsx", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/images/{imageID}/{variant}/{filename}").HandlerFunc(svc.authMiddleware.Require("static-files:read", imageHandler)).Methods(http.MethodGet)
 		router.Path("/downloads-new/{path:.*}").HandlerFunc(downloadHandlerWithAuth).Methods(http.MethodGet)
-		router.Path("/downloads/files/{path:.*}").HandlerFunc(downloadHandlerWithAuth).Methods(http.MethodGet)
+		router.Path("/downloads/preview/{path:.*}").HandlerFunc(previewHandler).Methods(http.MethodGet)
+		router.Path("/downloads/checksums/{path:.*}").HandlerFunc(checksumHandler).Methods(http.MethodGet)
+		router.Path("/downloads/files/{path:.*}").Queries("meta", "").HandlerFunc(metadataHandler).Methods(http.MethodGet)
+		router.Path("/downloads/files/{path:.*}").HandlerFunc(api.WithChecksumSuffix(files.FetchMetadata(svc.filesClient), checksumHandler, downloadHandlerWithAuth, cfg)).Methods(http.MethodGet)
+		router.Path("/downloads/bundles").HandlerFunc(bundleHandler).Methods(http.MethodPost)
+		router.Path("/downloads/collections/{id}").HandlerFunc(archiveHandler(api.ArchiveCollection)).Methods(http.MethodGet)
+		router.Path("/downloads/bundles/{id}").HandlerFunc(archiveHandler(api.ArchiveBundle)).Methods(http.MethodGet)
 	} else {
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv").HandlerFunc(d.DoDatasetVersion("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv-metadata.json").HandlerFunc(d.DoDatasetVersion("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.txt").HandlerFunc(d.DoDatasetVersion("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xls").HandlerFunc(d.DoDatasetVersion("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xlsx").HandlerFunc(d.DoDatasetVersion("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.csv").HandlerFunc(d.DoFilterOutput("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.xls").HandlerFunc(d.DoFilterOutput("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.xlsx").HandlerFunc(d.DoFilterOutput("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.txt").HandlerFunc(d.DoFilterOutput("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/downloads/filter-outputs/{filterOutputID}.csv-metadata.json").HandlerFunc(d.DoFilterOutput("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
-		router.Path("/images/{imageID}/{variant}/{filename}").HandlerFunc(d.DoImage(cfg.ServiceAuthToken, cfg.DownloadServiceToken)).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv").HandlerFunc(latest(legacyDownload("datasets.csv", downloads.TypeDatasetVersion, "csv", d.DoDatasetVersion("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv/preview").HandlerFunc(latest(d.DoDatasetVersionPreview(cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv-metadata.json").HandlerFunc(latest(legacyDownload("datasets.csv-metadata.json", downloads.TypeDatasetVersion, "csvw", d.DoDatasetVersion("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.txt").HandlerFunc(latest(legacyDownload("datasets.txt", downloads.TypeDatasetVersion, "txt", d.DoDatasetVersion("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xls").HandlerFunc(latest(legacyDownload("datasets.xls", downloads.TypeDatasetVersion, "xls", d.DoDatasetVersion("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xlsx").HandlerFunc(latest(legacyDownload("datasets.xlsx", downloads.TypeDatasetVersion, "xlsx", d.DoDatasetVersion("xlsx", cfg.ServiceAuthToken, cfg.DownloadServiceToken)))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv.sha256").HandlerFunc(latest(d.DoDatasetVersionChecksum("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.csv-metadata.json.sha256").HandlerFunc(latest(d.DoDatasetVersionChecksum("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.txt.sha256").HandlerFunc(latest(d.DoDatasetVersionChecksum("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xls.sha256").HandlerFunc(latest(d.DoDatasetVersionChecksum("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/datasets/{datasetID}/editions/{edition}/versions/{version}.xlsx.sha256").HandlerFunc(latest(d.DoDatasetVersionChecksum("xlsx", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.csv").HandlerFunc(legacyDownload("filter-outputs.csv", downloads.TypeFilterOutput, "csv", d.DoFilterOutput("csv", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.xls").HandlerFunc(legacyDownload("filter-outputs.xls", downloads.TypeFilterOutput, "xls", d.DoFilterOutput("xls", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.xlsx").HandlerFunc(legacyDownload("filter-outputs.xlsx", downloads.TypeFilterOutput, "xlsx", d.DoFilterOutput("xlsx", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.txt").HandlerFunc(legacyDownload("filter-outputs.txt", downloads.TypeFilterOutput, "txt", d.DoFilterOutput("txt", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/downloads/filter-outputs/{filterOutputID}.csv-metadata.json").HandlerFunc(legacyDownload("filter-outputs.csv-metadata.json", downloads.TypeFilterOutput, "csvw", d.DoFilterOutput("csvw", cfg.ServiceAuthToken, cfg.DownloadServiceToken))).Methods(http.MethodGet)
+		router.Path("/images/{imageID}/{variant}/{filename}").HandlerFunc(imageHandler).Methods(http.MethodGet)
 		router.Path("/downloads-new/{path:.*}").HandlerFunc(downloadHandlerNoAuth).Methods(http.MethodGet)
-		router.Path("/downloads/files/{path:.*}").HandlerFunc(downloadHandlerNoAuth).Methods(http.MethodGet)
+		router.Path("/downloads/preview/{path:.*}").HandlerFunc(previewHandler).Methods(http.MethodGet)
+		router.Path("/downloads/checksums/{path:.*}").HandlerFunc(checksumHandler).Methods(http.MethodGet)
+		router.Path("/downloads/files/{path:.*}").Queries("meta", "").HandlerFunc(metadataHandler).Methods(http.MethodGet)
+		router.Path("/downloads/files/{path:.*}").HandlerFunc(api.WithChecksumSuffix(files.FetchMetadata(svc.filesClient), checksumHandler, downloadHandlerNoAuth, cfg)).Methods(http.MethodGet)
+		router.Path("/downloads/bundles").HandlerFunc(bundleHandler).Methods(http.MethodPost)
 	}
 
 	router.HandleFunc("/health", hc.Handler)
+	// The expvar handler exposes the command line and memory statistics as well as the S3 counters, so it is only
+	// served to authorised users in the publishing environment. Web mode can serve the S3 counters on their own.
+	if cfg.IsPublishing {
+		router.Handle("/debug/vars", svc.authMiddleware.Require("static-files:read", expvar.Handler().ServeHTTP)).Methods(http.MethodGet)
+	} else if cfg.ServeS3Metrics {
+		router.HandleFunc("/debug/vars", s3Metrics).Methods(http.MethodGet)
+	}
 	svc.router = router
 
 	// Create new middleware chain with whitelisted handler for /health endpoint
 	middlewareChain := alice.New(middleware.Whitelist(middleware.HealthcheckFilter(hc.Handler)))
 	middlewareChain = middlewareChain.Append(api.Limiter(cfg.MaxConcurrentHandlers))
+	middlewareChain = middlewareChain.Append(api.Compress(cfg.CompressionLevel))
 
 	// For non-whitelisted endpoints, do corsHandler
 	if !cfg.IsPublishing {
@@ -207,6 +424,13 @@ func New(ctx context.Context, buildTime, gitCommit, version string, cfg *config.
 	return svc, nil
 }
 
+// s3Metrics serves the S3 counters in the same format as the expvar handler, without the command line and memory
+// statistics
+func s3Metrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n%q: %s\n}\n", "s3", expvar.Get("s3"))
+}
+
 func (svc *Download) registerCheckers(ctx context.Context) error {
 	var hasErrors bool
 	hc := svc.healthCheck
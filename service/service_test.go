@@ -3,9 +3,11 @@ package service_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -77,6 +79,7 @@ func TestNew(t *testing.T) {
 		}
 
 		mockedHttpServer := &HTTPServerMock{}
+		var router http.Handler
 
 		authmock := &authMock.MiddlewareMock{
 			RequireFunc: func(permission string, handlerFunc http.HandlerFunc) http.HandlerFunc {
@@ -107,6 +110,7 @@ func TestNew(t *testing.T) {
 				return mockedHealthChecker, nil
 			},
 			HTTPServerFunc: func(configMoqParam *config.Config, handler http.Handler) service.HTTPServer {
+				router = handler
 				return mockedHttpServer
 			},
 		}
@@ -127,6 +131,43 @@ func TestNew(t *testing.T) {
 			})
 		})
 
+		Convey("When a user without permission requests the latest version of a dataset in publishing", func() {
+			authmock.RequireFunc = func(permission string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusForbidden)
+				}
+			}
+			_, err := service.New(ctx, buildTime, gitCommit, version, cfg, mockedDependencies)
+			So(err, ShouldBeNil)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/downloads/datasets/cpih01/editions/latest/versions/latest.csv", http.NoBody))
+
+			Convey("The request is refused before the latest version is looked up", func() {
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+				So(rec.Header().Get("Location"), ShouldBeEmpty)
+				So(mockedDatasetClient.GetFullEditionsDetailsCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the S3 counters are requested in web mode", func() {
+			cfg.IsPublishing = false
+			cfg.ServeS3Metrics = true
+			_, err := service.New(ctx, buildTime, gitCommit, version, cfg, mockedDependencies)
+			So(err, ShouldBeNil)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", http.NoBody))
+
+			Convey("Only the S3 counters are served", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				var vars map[string]any
+				So(json.Unmarshal(rec.Body.Bytes(), &vars), ShouldBeNil)
+				So(vars, ShouldContainKey, "s3")
+				So(vars, ShouldNotContainKey, "cmdline")
+			})
+		})
+
 		// Ensure New fails when any of the client setups fail
 		Convey("When S3 setup fails", func() {
 			mockedDependencies.S3ClientFunc = func(ctx context.Context, cfg *config.Config) (content.S3Client, error) {
@@ -0,0 +1,202 @@
+package tabular
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// recordWriter writes CSV records in another format
+type recordWriter interface {
+	Write(record []string) error
+	Close() error
+}
+
+// Convert streams a CSV file from r to w in the given format, one record at a time. Columns described by the schema
+// are converted to their type and other columns are strings. Parquet output is written in row groups of about
+// rowGroupBytes of encoded data, which bounds the memory used for each conversion.
+//
+// Once the header has been read output may already have been written, so an error in a later record leaves the
+// output incomplete.
+func Convert(w io.Writer, r io.Reader, format Format, schema Schema, rowGroupBytes int64) error {
+	if format == FormatCSV {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+	}
+	if len(header) > 0 {
+		header = append([]string{}, header...)
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	rw, err := newRecordWriter(w, format, columnNames(header), schema.Types(header), rowGroupBytes)
+	if err != nil {
+		return err
+	}
+
+	for row := 1; len(header) > 0; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+		}
+		if len(record) > len(header) {
+			return fmt.Errorf("%w: row %d has %d fields but the header has %d", ErrInvalidCSV, row, len(record), len(header))
+		}
+		if err = rw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return rw.Close()
+}
+
+func newRecordWriter(w io.Writer, format Format, names []string, types []ColumnType, rowGroupBytes int64) (recordWriter, error) {
+	switch format {
+	case FormatJSON, FormatNDJSON:
+		return newJSONWriter(w, names, types, format == FormatNDJSON), nil
+	case FormatParquet:
+		return newParquetWriter(w, names, types, rowGroupBytes)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// columnNames returns unique names for the columns in a header, naming blank columns by their position and adding a
+// suffix to repeated names
+func columnNames(header []string) []string {
+	names := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		unique := name
+		for n := 2; seen[unique]; n++ {
+			unique = fmt.Sprintf("%s_%d", name, n)
+		}
+		seen[unique] = true
+		names[i] = unique
+	}
+	return names
+}
+
+// jsonWriter writes records as objects keyed by column name, either in a single array or one per line. Values that
+// cannot be parsed as the column type are written as strings and empty values in typed columns are null.
+type jsonWriter struct {
+	w      *bufio.Writer
+	keys   [][]byte
+	types  []ColumnType
+	ndjson bool
+	rows   int
+}
+
+func newJSONWriter(w io.Writer, names []string, types []ColumnType, ndjson bool) *jsonWriter {
+	jw := &jsonWriter{w: bufio.NewWriter(w), types: types, ndjson: ndjson}
+	for _, name := range names {
+		key, _ := json.Marshal(name)
+		jw.keys = append(jw.keys, append(key, ':'))
+	}
+	return jw
+}
+
+func (jw *jsonWriter) Write(record []string) error {
+	switch {
+	case jw.ndjson:
+	case jw.rows == 0:
+		jw.w.WriteByte('[') // nolint
+	default:
+		jw.w.WriteByte(',') // nolint
+	}
+	jw.rows++
+
+	jw.w.WriteByte('{') // nolint
+	for i, key := range jw.keys {
+		if i > 0 {
+			jw.w.WriteByte(',') // nolint
+		}
+		jw.w.Write(key) // nolint
+		if i < len(record) {
+			jw.writeValue(record[i], jw.types[i])
+		} else {
+			jw.w.WriteString("null") // nolint
+		}
+	}
+	jw.w.WriteByte('}') // nolint
+
+	if jw.ndjson {
+		return jw.w.WriteByte('\n')
+	}
+	return nil
+}
+
+func (jw *jsonWriter) Close() error {
+	if !jw.ndjson {
+		if jw.rows == 0 {
+			jw.w.WriteByte('[') // nolint
+		}
+		jw.w.WriteString("]\n") // nolint
+	}
+	return jw.w.Flush()
+}
+
+func (jw *jsonWriter) writeValue(value string, typ ColumnType) {
+	var b []byte
+	switch {
+	case typ != TypeString && value == "":
+		b = []byte("null")
+	case typ == TypeInteger:
+		if v, ok := parseInteger(value); ok {
+			b = strconv.AppendInt(nil, v, 10)
+		}
+	case typ == TypeNumber:
+		if v, ok := parseNumber(value); ok && !math.IsNaN(v) && !math.IsInf(v, 0) {
+			b = strconv.AppendFloat(nil, v, 'g', -1, 64)
+		}
+	case typ == TypeBoolean:
+		if v, ok := parseBoolean(value); ok {
+			b = strconv.AppendBool(nil, v)
+		}
+	}
+
+	if b == nil {
+		b, _ = json.Marshal(value)
+	}
+	jw.w.Write(b) // nolint
+}
+
+func parseInteger(value string) (int64, bool) {
+	v, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return v, err == nil
+}
+
+func parseNumber(value string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return v, err == nil
+}
+
+func parseBoolean(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1":
+		return true, true
+	case "false", "0":
+		return false, true
+	default:
+		return false, false
+	}
+}
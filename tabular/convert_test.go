@@ -0,0 +1,120 @@
+package tabular
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const convertCSV = "\ufeffgeography,year,value,provisional,notes\n" +
+	"K02000001,2024,1.5,true,\"quoted, text\"\n" +
+	"E92000001,2023,x,false,\n" +
+	"W92000004,,2\n"
+
+var convertSchema = Schema{"year": TypeInteger, "value": TypeNumber, "provisional": TypeBoolean}
+
+func TestConvertJSON(t *testing.T) {
+	var b bytes.Buffer
+
+	err := Convert(&b, strings.NewReader(convertCSV), FormatJSON, convertSchema, 0)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"geography": "K02000001", "year": 2024, "value": 1.5, "provisional": true, "notes": "quoted, text"},
+		{"geography": "E92000001", "year": 2023, "value": "x", "provisional": false, "notes": ""},
+		{"geography": "W92000004", "year": null, "value": 2, "provisional": null, "notes": null}
+	]`, b.String())
+}
+
+func TestConvertNDJSON(t *testing.T) {
+	var b bytes.Buffer
+
+	err := Convert(&b, strings.NewReader(convertCSV), FormatNDJSON, nil, 0)
+
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"geography": "K02000001", "year": "2024", "value": "1.5", "provisional": "true", "notes": "quoted, text"}`, lines[0])
+}
+
+func TestConvertEmptyFile(t *testing.T) {
+	var b bytes.Buffer
+
+	err := Convert(&b, strings.NewReader("a,b\n"), FormatJSON, nil, 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", b.String())
+}
+
+func TestConvertInvalidCSV(t *testing.T) {
+	var b bytes.Buffer
+
+	err := Convert(&b, strings.NewReader("a,b\n1,2,3\n"), FormatNDJSON, nil, 0)
+
+	assert.ErrorIs(t, err, ErrInvalidCSV)
+}
+
+func TestColumnNames(t *testing.T) {
+	assert.Equal(t, []string{"a", "column_2", "a_2", "a_3"}, columnNames([]string{"a", "", "a", "a"}))
+}
+
+func TestParseCSVW(t *testing.T) {
+	metadata := `{
+		"@context": "http://www.w3.org/ns/csvw",
+		"url": "data.csv",
+		"tableSchema": {
+			"columns": [
+				{"titles": "Geography", "name": "geography", "datatype": "string"},
+				{"titles": ["Year", "Time"], "datatype": "integer"},
+				{"titles": {"en": "Value"}, "datatype": {"base": "decimal"}},
+				{"titles": "Provisional", "datatype": "xsd:boolean"},
+				{"name": "uri", "datatype": "integer", "virtual": true}
+			]
+		}
+	}`
+
+	schema, err := ParseCSVW(strings.NewReader(metadata))
+
+	require.NoError(t, err)
+	assert.Equal(t, []ColumnType{TypeString, TypeString, TypeInteger, TypeInteger, TypeNumber, TypeBoolean, TypeString},
+		schema.Types([]string{"Geography", "geography", "Year", "Time", "Value", "Provisional", "uri"}))
+
+	_, err = ParseCSVW(strings.NewReader("not json"))
+	assert.Error(t, err)
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := map[string]struct {
+		param, accept string
+		expected      Format
+	}{
+		"no preference":     {expected: FormatCSV},
+		"format parameter":  {param: "Parquet", accept: "application/json", expected: FormatParquet},
+		"accept json":       {accept: "application/json", expected: FormatJSON},
+		"accept ndjson":     {accept: "text/csv;q=0.5, application/x-ndjson", expected: FormatNDJSON},
+		"prefers csv":       {accept: "application/json;q=0.5, text/csv", expected: FormatCSV},
+		"browser wildcards": {accept: "text/html,application/xhtml+xml,*/*;q=0.8", expected: FormatCSV},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			format, err := NegotiateFormat(tc.param, tc.accept)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, format)
+		})
+	}
+
+	_, err := NegotiateFormat("xml", "")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestFormatFilename(t *testing.T) {
+	assert.Equal(t, "data.json", FormatJSON.Filename("data.csv"))
+	assert.Equal(t, "data.parquet", FormatParquet.Filename("data.CSV"))
+	assert.Equal(t, "data.txt.ndjson", FormatNDJSON.Filename("data.txt"))
+	assert.Equal(t, "application/vnd.apache.parquet", FormatParquet.ContentType())
+}
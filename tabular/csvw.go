@@ -0,0 +1,142 @@
+package tabular
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ColumnType is the type values in a column are converted to
+type ColumnType int
+
+const (
+	TypeString ColumnType = iota
+	TypeInteger
+	TypeNumber
+	TypeBoolean
+)
+
+// maxCSVWSize is the largest CSVW metadata document that is read for column types
+const maxCSVWSize = 10 << 20
+
+// csvwDatatypes maps the CSVW (XML Schema) datatypes to column types. Datatypes that are not listed are strings.
+var csvwDatatypes = map[string]ColumnType{
+	"integer":            TypeInteger,
+	"int":                TypeInteger,
+	"long":               TypeInteger,
+	"short":              TypeInteger,
+	"byte":               TypeInteger,
+	"nonNegativeInteger": TypeInteger,
+	"nonPositiveInteger": TypeInteger,
+	"positiveInteger":    TypeInteger,
+	"negativeInteger":    TypeInteger,
+	"unsignedInt":        TypeInteger,
+	"unsignedShort":      TypeInteger,
+	"unsignedByte":       TypeInteger,
+	"number":             TypeNumber,
+	"decimal":            TypeNumber,
+	"double":             TypeNumber,
+	"float":              TypeNumber,
+	"boolean":            TypeBoolean,
+}
+
+// Schema holds the type of each column described by CSVW metadata, keyed by both title and name
+type Schema map[string]ColumnType
+
+// Types returns the type of each column in a header. Columns the schema does not describe are strings.
+func (s Schema) Types(header []string) []ColumnType {
+	types := make([]ColumnType, len(header))
+	for i, name := range header {
+		types[i] = s[name]
+	}
+	return types
+}
+
+type csvwMetadata struct {
+	TableSchema *csvwTableSchema `json:"tableSchema"`
+	Tables      []csvwMetadata   `json:"tables"`
+}
+
+type csvwTableSchema struct {
+	Columns []csvwColumn `json:"columns"`
+}
+
+type csvwColumn struct {
+	Name     string          `json:"name"`
+	Titles   json.RawMessage `json:"titles"`
+	Datatype json.RawMessage `json:"datatype"`
+	Virtual  bool            `json:"virtual"`
+}
+
+// ParseCSVW reads the column types from a CSVW metadata document. If the document describes a group of tables only
+// the first table is used.
+func ParseCSVW(r io.Reader) (Schema, error) {
+	var metadata csvwMetadata
+	if err := json.NewDecoder(io.LimitReader(r, maxCSVWSize)).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse CSVW metadata: %w", err)
+	}
+
+	if metadata.TableSchema == nil && len(metadata.Tables) > 0 {
+		metadata = metadata.Tables[0]
+	}
+
+	schema := Schema{}
+	if metadata.TableSchema == nil {
+		return schema, nil
+	}
+
+	for _, c := range metadata.TableSchema.Columns {
+		if c.Virtual {
+			continue
+		}
+
+		t := columnType(c.Datatype)
+		if c.Name != "" {
+			schema[c.Name] = t
+		}
+		for _, title := range titles(c.Titles) {
+			schema[title] = t
+		}
+	}
+
+	return schema, nil
+}
+
+// columnType reads a datatype, which is either the name of a datatype or an object with a base datatype
+func columnType(datatype json.RawMessage) ColumnType {
+	var name string
+	if err := json.Unmarshal(datatype, &name); err != nil {
+		var derived struct {
+			Base string `json:"base"`
+		}
+		if err = json.Unmarshal(datatype, &derived); err != nil {
+			return TypeString
+		}
+		name = derived.Base
+	}
+
+	name = strings.TrimPrefix(name, "xsd:")
+	return csvwDatatypes[name]
+}
+
+// titles reads column titles, which are either a single string, an array of strings or a map of language to titles
+func titles(raw json.RawMessage) []string {
+	var title string
+	if err := json.Unmarshal(raw, &title); err == nil {
+		return []string{title}
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+
+	var languages map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &languages); err == nil {
+		for _, v := range languages {
+			list = append(list, titles(v)...)
+		}
+	}
+	return list
+}
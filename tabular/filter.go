@@ -0,0 +1,216 @@
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrUnknownColumn = errors.New("unknown column")
+)
+
+// Filter selects columns and rows from a CSV file
+type Filter struct {
+	// Columns are the names of the columns to keep, in order. If empty every column is kept.
+	Columns []string
+	// Rows are predicates that every row in the output must match
+	Rows []RowFilter
+}
+
+// RowFilter matches rows whose value in a column is one of a set of values
+type RowFilter struct {
+	Column string
+	Values []string
+}
+
+// ParseFilter reads a filter from the query parameters of a request, returning nil if no filter was requested.
+//
+// The columns parameter lists the columns to keep and the filter parameter, which can be repeated, is a column name
+// and one or more values separated by "=", e.g. filter=geography=K02000001,E92000001. Rows match if the column is equal
+// to any of the values and must match every filter. Lists are parsed as CSV, so names and values containing commas
+// can be quoted.
+func ParseFilter(query url.Values) (*Filter, error) {
+	filter := &Filter{}
+
+	for _, param := range query["columns"] {
+		columns, err := parseList(param)
+		if err != nil || slices.Contains(columns, "") {
+			return nil, fmt.Errorf("%w: columns must be a list of column names", ErrInvalidFilter)
+		}
+		filter.Columns = append(filter.Columns, columns...)
+	}
+
+	for _, param := range query["filter"] {
+		column, list, ok := strings.Cut(param, "=")
+		if !ok || column == "" {
+			return nil, fmt.Errorf("%w: %q must be a column name and values separated by '='", ErrInvalidFilter, param)
+		}
+		values, err := parseList(list)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q has an invalid list of values", ErrInvalidFilter, param)
+		}
+		filter.Rows = append(filter.Rows, RowFilter{Column: column, Values: values})
+	}
+
+	if len(filter.Columns) == 0 && len(filter.Rows) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+func parseList(list string) ([]string, error) {
+	if list == "" {
+		return []string{""}, nil
+	}
+	r := csv.NewReader(strings.NewReader(list))
+	r.LazyQuotes = true
+	return r.Read()
+}
+
+// FilteredFilename adds a suffix to a filename to show that the file has been filtered
+func FilteredFilename(name string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-filtered" + ext
+}
+
+// Reader returns a reader of the rows and columns of the CSV file in r that the filter selects. The header is read
+// straight away, so an error is returned before anything is read if it names a column that is not in the file.
+func (f *Filter) Reader(r io.Reader) (io.Reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	positions := map[string]int{}
+	for i := len(header) - 1; i >= 0; i-- {
+		positions[header[i]] = i
+	}
+	position := func(column string) (int, error) {
+		i, ok := positions[column]
+		if !ok {
+			return 0, fmt.Errorf("%w: %q", ErrUnknownColumn, column)
+		}
+		return i, nil
+	}
+
+	fr := &filteredReader{cr: cr}
+	fr.cw = csv.NewWriter(&fr.buf)
+
+	seen := map[string]bool{}
+	for _, column := range f.Columns {
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		i, err := position(column)
+		if err != nil {
+			return nil, err
+		}
+		fr.columns = append(fr.columns, i)
+	}
+	if len(f.Columns) == 0 {
+		for i := range header {
+			fr.columns = append(fr.columns, i)
+		}
+	}
+
+	for _, rf := range f.Rows {
+		i, err := position(rf.Column)
+		if err != nil {
+			return nil, err
+		}
+		values := map[string]bool{}
+		for _, v := range rf.Values {
+			values[v] = true
+		}
+		fr.predicates = append(fr.predicates, predicate{column: i, values: values})
+	}
+
+	if len(header) > 0 {
+		fr.write(header)
+	} else {
+		fr.err = io.EOF
+	}
+
+	return fr, nil
+}
+
+type predicate struct {
+	column int
+	values map[string]bool
+}
+
+// filteredReader reads the CSV file a record at a time, writing the selected columns of matching rows to a buffer
+// that is drained by Read
+type filteredReader struct {
+	cr         *csv.Reader
+	cw         *csv.Writer
+	buf        bytes.Buffer
+	columns    []int
+	predicates []predicate
+	selected   []string
+	err        error
+}
+
+func (fr *filteredReader) Read(p []byte) (int, error) {
+	for fr.buf.Len() == 0 {
+		if fr.err != nil {
+			return 0, fr.err
+		}
+
+		record, err := fr.cr.Read()
+		switch {
+		case err == io.EOF:
+			fr.err = io.EOF
+		case err != nil:
+			fr.err = fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+		case fr.matches(record):
+			fr.write(record)
+		}
+	}
+
+	return fr.buf.Read(p)
+}
+
+func (fr *filteredReader) matches(record []string) bool {
+	for _, p := range fr.predicates {
+		if !p.values[field(record, p.column)] {
+			return false
+		}
+	}
+	return true
+}
+
+func (fr *filteredReader) write(record []string) {
+	fr.selected = fr.selected[:0]
+	for _, i := range fr.columns {
+		fr.selected = append(fr.selected, field(record, i))
+	}
+	if err := fr.cw.Write(fr.selected); err != nil {
+		fr.err = err
+		return
+	}
+	fr.cw.Flush()
+}
+
+func field(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package tabular
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const filterCSV = "\ufeffgeography,year,value,notes\n" +
+	"K02000001,2024,1,\"quoted, text\"\n" +
+	"E92000001,2023,2,\n" +
+	"W92000004,2024,3\n"
+
+func TestParseFilter(t *testing.T) {
+	t.Run("no filter", func(t *testing.T) {
+		filter, err := ParseFilter(url.Values{"format": {"json"}})
+		require.NoError(t, err)
+		assert.Nil(t, filter)
+	})
+
+	t.Run("columns and row filters", func(t *testing.T) {
+		query, err := url.ParseQuery(`columns=geography,"time, period"&filter=geography=K02000001,E92000001&filter=year=2024`)
+		require.NoError(t, err)
+
+		filter, err := ParseFilter(query)
+		require.NoError(t, err)
+		assert.Equal(t, &Filter{
+			Columns: []string{"geography", "time, period"},
+			Rows: []RowFilter{
+				{Column: "geography", Values: []string{"K02000001", "E92000001"}},
+				{Column: "year", Values: []string{"2024"}},
+			},
+		}, filter)
+	})
+
+	t.Run("a filter on an empty value", func(t *testing.T) {
+		filter, err := ParseFilter(url.Values{"filter": {"notes="}})
+		require.NoError(t, err)
+		assert.Equal(t, []RowFilter{{Column: "notes", Values: []string{""}}}, filter.Rows)
+	})
+
+	for name, query := range map[string]url.Values{
+		"empty columns":         {"columns": {""}},
+		"filter without values": {"filter": {"geography"}},
+		"filter without column": {"filter": {"=K02000001"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseFilter(query)
+			assert.ErrorIs(t, err, ErrInvalidFilter)
+		})
+	}
+}
+
+func TestFilterReader(t *testing.T) {
+	tests := map[string]struct {
+		filter   Filter
+		expected string
+	}{
+		"selects and reorders columns": {
+			filter:   Filter{Columns: []string{"value", "geography", "value"}},
+			expected: "value,geography\n1,K02000001\n2,E92000001\n3,W92000004\n",
+		},
+		"filters rows by equality": {
+			filter:   Filter{Rows: []RowFilter{{Column: "year", Values: []string{"2024"}}}},
+			expected: "geography,year,value,notes\nK02000001,2024,1,\"quoted, text\"\nW92000004,2024,3,\n",
+		},
+		"filters rows by every predicate": {
+			filter: Filter{
+				Columns: []string{"geography"},
+				Rows: []RowFilter{
+					{Column: "geography", Values: []string{"K02000001", "E92000001"}},
+					{Column: "year", Values: []string{"2023"}},
+				},
+			},
+			expected: "geography\nE92000001\n",
+		},
+		"treats missing fields as empty": {
+			filter:   Filter{Columns: []string{"geography"}, Rows: []RowFilter{{Column: "notes", Values: []string{""}}}},
+			expected: "geography\nE92000001\nW92000004\n",
+		},
+		"writes only the header when no rows match": {
+			filter:   Filter{Columns: []string{"geography"}, Rows: []RowFilter{{Column: "year", Values: []string{"1999"}}}},
+			expected: "geography\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := test.filter.Reader(strings.NewReader(filterCSV))
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+		})
+	}
+
+	t.Run("unknown column", func(t *testing.T) {
+		filter := Filter{Rows: []RowFilter{{Column: "sex", Values: []string{"male"}}}}
+
+		_, err := filter.Reader(strings.NewReader(filterCSV))
+		assert.ErrorIs(t, err, ErrUnknownColumn)
+	})
+
+	t.Run("invalid CSV after the header", func(t *testing.T) {
+		filter := Filter{Columns: []string{"a"}}
+
+		r, err := filter.Reader(strings.NewReader("a,b\n\"unterminated\n"))
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r)
+		assert.ErrorIs(t, err, ErrInvalidCSV)
+	})
+}
+
+func TestFilteredFilename(t *testing.T) {
+	assert.Equal(t, "data-filtered.csv", FilteredFilename("data.csv"))
+	assert.Equal(t, "data-filtered", FilteredFilename("data"))
+}
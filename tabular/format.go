@@ -0,0 +1,88 @@
+package tabular
+
+import (
+	"errors"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Format is an output format that a CSV file can be converted to
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSON    Format = "json"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+var ErrUnsupportedFormat = errors.New("format must be one of csv, json, ndjson or parquet")
+
+var contentTypes = map[Format]string{
+	FormatCSV:     "text/csv",
+	FormatJSON:    "application/json",
+	FormatNDJSON:  "application/x-ndjson",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// mediaTypes are the media types accepted in an Accept header for each format
+var mediaTypes = map[string]Format{
+	"text/csv":                       FormatCSV,
+	"application/json":               FormatJSON,
+	"application/x-ndjson":           FormatNDJSON,
+	"application/ndjson":             FormatNDJSON,
+	"application/vnd.apache.parquet": FormatParquet,
+	"application/x-parquet":          FormatParquet,
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// Filename replaces the extension of a CSV filename with the extension of the format
+func (f Format) Filename(name string) string {
+	if strings.EqualFold(path.Ext(name), ".csv") {
+		name = name[:len(name)-len(".csv")]
+	}
+	return name + "." + string(f)
+}
+
+// NegotiateFormat returns the format requested by the format query parameter or, if there is none, the supported
+// format the Accept header prefers. Wildcards in the Accept header are ignored, so CSV is returned unless the client
+// explicitly asks for another format.
+func NegotiateFormat(formatParam, accept string) (Format, error) {
+	if formatParam != "" {
+		f := Format(strings.ToLower(formatParam))
+		if _, ok := contentTypes[f]; !ok {
+			return "", ErrUnsupportedFormat
+		}
+		return f, nil
+	}
+
+	format, quality := FormatCSV, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		f, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > quality {
+			format, quality = f, q
+		}
+	}
+
+	return format, nil
+}
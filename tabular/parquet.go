@@ -0,0 +1,121 @@
+package tabular
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// DefaultRowGroupBytes is the amount of column data buffered for each Parquet row group when no limit is set
+const DefaultRowGroupBytes = 8 << 20
+
+var parquetTypes = map[ColumnType]parquet.Node{
+	TypeString:  parquet.String(),
+	TypeInteger: parquet.Leaf(parquet.Int64Type),
+	TypeNumber:  parquet.Leaf(parquet.DoubleType),
+	TypeBoolean: parquet.Leaf(parquet.BooleanType),
+}
+
+// parquetWriter writes records to a zstd compressed Parquet file. Every column is optional; missing values and values
+// that cannot be parsed as the column type are written as nulls. Rows are buffered until the size of their values
+// reaches rowGroupBytes, when they are written out as a row group.
+type parquetWriter struct {
+	writer        *parquet.Writer
+	types         []ColumnType
+	row           parquet.Row
+	rowGroupBytes int64
+	buffered      int64
+}
+
+func newParquetWriter(w io.Writer, names []string, types []ColumnType, rowGroupBytes int64) (*parquetWriter, error) {
+	if rowGroupBytes <= 0 {
+		rowGroupBytes = DefaultRowGroupBytes
+	}
+
+	group := columnGroup{Group: parquet.Group{}, names: names}
+	for i, name := range names {
+		group.Group[name] = parquet.Optional(parquetTypes[types[i]])
+	}
+
+	config, err := parquet.NewWriterConfig(parquet.NewSchema("schema", group), parquet.Compression(&parquet.Zstd))
+	if err != nil {
+		return nil, err
+	}
+
+	return &parquetWriter{
+		writer:        parquet.NewWriter(w, config),
+		types:         types,
+		rowGroupBytes: rowGroupBytes,
+	}, nil
+}
+
+func (pw *parquetWriter) Write(record []string) error {
+	pw.row = pw.row[:0]
+	for i, typ := range pw.types {
+		v := parquet.NullValue()
+		if i < len(record) {
+			v = parquetValue(typ, record[i])
+			pw.buffered += int64(len(record[i]))
+		}
+		if v.IsNull() {
+			pw.row = append(pw.row, v.Level(0, 0, i))
+		} else {
+			pw.row = append(pw.row, v.Level(0, 1, i))
+		}
+	}
+
+	if _, err := pw.writer.WriteRows([]parquet.Row{pw.row}); err != nil {
+		return err
+	}
+
+	if pw.buffered >= pw.rowGroupBytes {
+		pw.buffered = 0
+		return pw.writer.Flush()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.writer.Close()
+}
+
+// parquetValue parses a value as the column type, returning a null value if it cannot be parsed
+func parquetValue(typ ColumnType, value string) parquet.Value {
+	switch typ {
+	case TypeInteger:
+		if v, ok := parseInteger(value); ok {
+			return parquet.Int64Value(v)
+		}
+	case TypeNumber:
+		if v, ok := parseNumber(value); ok {
+			return parquet.DoubleValue(v)
+		}
+	case TypeBoolean:
+		if v, ok := parseBoolean(value); ok {
+			return parquet.BooleanValue(v)
+		}
+	default:
+		return parquet.ByteArrayValue([]byte(value))
+	}
+	return parquet.NullValue()
+}
+
+// columnGroup is the Parquet schema of the columns, which keeps them in the order of the CSV header rather than the
+// alphabetical order of a parquet.Group
+type columnGroup struct {
+	parquet.Group
+	names []string
+}
+
+func (g columnGroup) Fields() []parquet.Field {
+	fields := g.Group.Fields()
+	byName := make(map[string]parquet.Field, len(fields))
+	for _, f := range fields {
+		byName[f.Name()] = f
+	}
+
+	for i, name := range g.names {
+		fields[i] = byName[name]
+	}
+	return fields
+}
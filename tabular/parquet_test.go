@@ -0,0 +1,105 @@
+package tabular
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readParquet opens a written Parquet file with the parquet-go reader
+func readParquet(t *testing.T, b []byte) *parquet.File {
+	f, err := parquet.OpenFile(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	return f
+}
+
+// readParquetColumns returns the values of each column in every row group, with nil for nulls
+func readParquetColumns(t *testing.T, f *parquet.File) [][]any {
+	columns := make([][]any, len(f.Schema().Fields()))
+
+	r := parquet.NewReader(f)
+	defer r.Close()
+
+	rows := make([]parquet.Row, f.NumRows())
+	n, err := r.ReadRows(rows)
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+	require.Equal(t, len(rows), n)
+
+	for _, row := range rows {
+		for _, v := range row {
+			var value any
+			switch {
+			case v.IsNull():
+			case v.Kind() == parquet.ByteArray:
+				value = string(v.ByteArray())
+			case v.Kind() == parquet.Int64:
+				value = v.Int64()
+			case v.Kind() == parquet.Double:
+				value = v.Double()
+			case v.Kind() == parquet.Boolean:
+				value = v.Boolean()
+			}
+			columns[v.Column()] = append(columns[v.Column()], value)
+		}
+	}
+	return columns
+}
+
+func TestConvertParquet(t *testing.T) {
+	for name, rowGroupBytes := range map[string]int64{"single row group": 0, "row group per row": 1} {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			err := Convert(&b, strings.NewReader(convertCSV), FormatParquet, convertSchema, rowGroupBytes)
+			require.NoError(t, err)
+
+			f := readParquet(t, b.Bytes())
+			assert.Equal(t, int64(3), f.NumRows())
+			if rowGroupBytes == 1 {
+				assert.Len(t, f.RowGroups(), 3)
+			} else {
+				assert.Len(t, f.RowGroups(), 1)
+			}
+			for _, rg := range f.Metadata().RowGroups {
+				for _, c := range rg.Columns {
+					assert.Equal(t, format.Zstd, c.MetaData.Codec)
+				}
+			}
+
+			var names []string
+			for _, field := range f.Schema().Fields() {
+				assert.True(t, field.Optional())
+				names = append(names, field.Name())
+			}
+			assert.Equal(t, []string{"geography", "year", "value", "provisional", "notes"}, names)
+			assert.NotNil(t, f.Schema().Fields()[0].Type().LogicalType().String)
+
+			columns := readParquetColumns(t, f)
+			assert.Equal(t, []any{"K02000001", "E92000001", "W92000004"}, columns[0])
+			assert.Equal(t, []any{int64(2024), int64(2023), nil}, columns[1])
+			assert.Equal(t, []any{1.5, nil, 2.0}, columns[2])
+			assert.Equal(t, []any{true, false, nil}, columns[3])
+			assert.Equal(t, []any{"quoted, text", "", nil}, columns[4])
+		})
+	}
+}
+
+func TestConvertParquetEmptyFile(t *testing.T) {
+	var b bytes.Buffer
+
+	err := Convert(&b, strings.NewReader("a,b\n"), FormatParquet, nil, 0)
+	require.NoError(t, err)
+
+	f := readParquet(t, b.Bytes())
+	assert.Equal(t, int64(0), f.NumRows())
+	assert.Empty(t, f.RowGroups())
+	assert.Len(t, f.Schema().Fields(), 2)
+}
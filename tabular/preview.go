@@ -0,0 +1,98 @@
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultPreviewRows is the number of rows in a preview when none are requested
+const DefaultPreviewRows = 10
+
+var (
+	ErrInvalidCSV       = errors.New("file is not valid CSV")
+	ErrHeaderTooLarge   = errors.New("header row is larger than the preview limit")
+	ErrInvalidRowsParam = errors.New("rows must be a positive integer")
+)
+
+// Preview is the header and first rows of a CSV file
+type Preview struct {
+	Header      []string   `json:"header"`
+	Rows        [][]string `json:"rows"`
+	Truncated   bool       `json:"truncated"`
+	SizeInBytes int64      `json:"size_in_bytes"`
+}
+
+// ParseRows returns the number of rows requested by the rows query parameter, capped at maxRows
+func ParseRows(param string, maxRows int) (int, error) {
+	if param == "" {
+		return min(DefaultPreviewRows, maxRows), nil
+	}
+
+	rows, err := strconv.Atoi(param)
+	if err != nil || rows < 1 {
+		return 0, ErrInvalidRowsParam
+	}
+
+	return min(rows, maxRows), nil
+}
+
+// ReadPreview parses the header and up to rows records from the start of a CSV file of the given size, reading no
+// more than maxBytes from r. A record cut off by the byte limit is left out of the preview. Truncated is set if the
+// file has more rows than the preview.
+func ReadPreview(r io.Reader, size int64, rows int, maxBytes int64) (*Preview, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, maxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// If the limit stopped the read part way through the file, only whole lines are parsed
+	cut := int64(len(buf)) == maxBytes && size > maxBytes
+	if cut {
+		buf = buf[:bytes.LastIndexByte(buf, '\n')+1]
+	}
+
+	cr := csv.NewReader(bytes.NewReader(buf))
+	cr.FieldsPerRecord = -1
+
+	preview := &Preview{Rows: [][]string{}, SizeInBytes: size}
+
+	header, err := cr.Read()
+	switch {
+	case err != nil && cut:
+		return nil, ErrHeaderTooLarge
+	case err == io.EOF:
+		return preview, nil
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	preview.Header = header
+
+	for len(preview.Rows) < rows {
+		record, err := cr.Read()
+		if err == io.EOF {
+			preview.Truncated = cut
+			return preview, nil
+		}
+		if err != nil {
+			// A quoted field containing a line break may have been cut off by the limit
+			if cut {
+				preview.Truncated = true
+				return preview, nil
+			}
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+		}
+		preview.Rows = append(preview.Rows, record)
+	}
+
+	if _, err = cr.Read(); err != io.EOF || cut {
+		preview.Truncated = true
+	}
+
+	return preview, nil
+}
@@ -0,0 +1,101 @@
+package tabular
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCSV = "\ufeffgeography,\"time period\",value\n" +
+	"K02000001,2024,\"1,234\"\n" +
+	"E92000001,2024,\"multi\nline\"\n" +
+	"W92000004,2024,56\n"
+
+func TestReadPreview(t *testing.T) {
+	size := int64(len(testCSV))
+
+	t.Run("parses the header and rows with quoting", func(t *testing.T) {
+		p, err := ReadPreview(strings.NewReader(testCSV), size, 10, 1024)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"geography", "time period", "value"}, p.Header)
+		assert.Equal(t, [][]string{
+			{"K02000001", "2024", "1,234"},
+			{"E92000001", "2024", "multi\nline"},
+			{"W92000004", "2024", "56"},
+		}, p.Rows)
+		assert.False(t, p.Truncated)
+		assert.Equal(t, size, p.SizeInBytes)
+	})
+
+	t.Run("stops at the requested number of rows", func(t *testing.T) {
+		p, err := ReadPreview(strings.NewReader(testCSV), size, 2, 1024)
+
+		require.NoError(t, err)
+		assert.Len(t, p.Rows, 2)
+		assert.True(t, p.Truncated)
+	})
+
+	t.Run("leaves out a row cut off by the byte limit", func(t *testing.T) {
+		limit := int64(strings.Index(testCSV, "W92") + 4)
+
+		p, err := ReadPreview(strings.NewReader(testCSV), size, 10, limit)
+
+		require.NoError(t, err)
+		assert.Len(t, p.Rows, 2)
+		assert.True(t, p.Truncated)
+	})
+
+	t.Run("leaves out a quoted line break cut off by the byte limit", func(t *testing.T) {
+		limit := int64(strings.Index(testCSV, "line"))
+
+		p, err := ReadPreview(strings.NewReader(testCSV), size, 10, limit)
+
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"K02000001", "2024", "1,234"}}, p.Rows)
+		assert.True(t, p.Truncated)
+	})
+
+	t.Run("rejects a header larger than the byte limit", func(t *testing.T) {
+		_, err := ReadPreview(strings.NewReader(testCSV), size, 10, 10)
+
+		assert.ErrorIs(t, err, ErrHeaderTooLarge)
+	})
+
+	t.Run("rejects invalid CSV", func(t *testing.T) {
+		invalid := "a,b\n\"unterminated,1\n"
+
+		_, err := ReadPreview(strings.NewReader(invalid), int64(len(invalid)), 10, 1024)
+
+		assert.ErrorIs(t, err, ErrInvalidCSV)
+	})
+
+	t.Run("returns an empty preview of an empty file", func(t *testing.T) {
+		p, err := ReadPreview(strings.NewReader(""), 0, 10, 1024)
+
+		require.NoError(t, err)
+		assert.Nil(t, p.Header)
+		assert.Empty(t, p.Rows)
+	})
+}
+
+func TestParseRows(t *testing.T) {
+	rows, err := ParseRows("", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultPreviewRows, rows)
+
+	rows, err = ParseRows("50", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, rows)
+
+	rows, err = ParseRows("500", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, rows)
+
+	for _, invalid := range []string{"0", "-1", "ten"} {
+		_, err = ParseRows(invalid, 100)
+		assert.ErrorIs(t, err, ErrInvalidRowsParam, invalid)
+	}
+}
@@ -0,0 +1,96 @@
+package watermark
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notice marks watermarked files as not yet released
+const Notice = "PRE-RELEASE – NOT FOR PUBLICATION"
+
+// IDHeader is the response header identifying the watermark of a download
+const IDHeader = "X-Watermark-Id"
+
+// Media types of the files that can be watermarked
+const (
+	CSVMediaType  = "text/csv"
+	XLSXMediaType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Mark identifies who a pre-release copy of a file was given to, and when, so that leaked copies can be traced
+type Mark struct {
+	ID     string
+	UserID string
+	Time   time.Time
+}
+
+// New returns a watermark with a new ID for a download by the user at time t
+func New(userID string, t time.Time) Mark {
+	return Mark{ID: uuid.NewString(), UserID: userID, Time: t.UTC()}
+}
+
+// Supported reports whether files of the content type can be watermarked
+func Supported(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == CSVMediaType || mediaType == XLSXMediaType)
+}
+
+// Write writes the file read from r to w with the watermark added. The content type must be supported.
+func (m Mark) Write(w io.Writer, r io.Reader, contentType string) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case CSVMediaType:
+		_, err := io.Copy(m.CSVWriter(w), r)
+		return err
+	case XLSXMediaType:
+		return m.XLSX(w, r)
+	default:
+		return fmt.Errorf("cannot watermark files of type %q", contentType)
+	}
+}
+
+// Comment is the text of the watermark
+func (m Mark) Comment() string {
+	return fmt.Sprintf("%s. Downloaded by %s at %s. Watermark %s", Notice, m.UserID, m.Time.Format(time.RFC3339), m.ID)
+}
+
+// CSVWriter returns a writer that adds the watermark as a comment row, which CSVW readers skip by default, before the
+// first row of the CSV written to it. The comment follows the byte order mark of files that have one.
+func (m Mark) CSVWriter(w io.Writer) io.Writer {
+	return &csvWriter{w: w, comment: "# " + m.Comment() + "\n"}
+}
+
+type csvWriter struct {
+	w       io.Writer
+	comment string
+	written bool
+}
+
+func (c *csvWriter) Write(p []byte) (int, error) {
+	if c.written {
+		return c.w.Write(p)
+	}
+	c.written = true
+
+	bom := 0
+	if bytes.HasPrefix(p, utf8BOM) {
+		bom = len(utf8BOM)
+	}
+
+	if _, err := c.w.Write(p[:bom]); err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(c.w, c.comment); err != nil {
+		return 0, err
+	}
+
+	n, err := c.w.Write(p[bom:])
+	return n + bom, err
+}
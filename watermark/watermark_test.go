@@ -0,0 +1,152 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMark = Mark{ID: "3f8b6c1e", UserID: "previewer@ons.gov.uk", Time: time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC)}
+
+const testComment = "# PRE-RELEASE – NOT FOR PUBLICATION. Downloaded by previewer@ons.gov.uk at 2026-03-04T09:30:00Z. Watermark 3f8b6c1e\n"
+
+func TestNew(t *testing.T) {
+	m := New("previewer@ons.gov.uk", time.Date(2026, 3, 4, 10, 30, 0, 0, time.FixedZone("BST", 3600)))
+
+	assert.NotEmpty(t, m.ID)
+	assert.NotEqual(t, m.ID, New("previewer@ons.gov.uk", time.Now()).ID)
+	assert.Equal(t, time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC), m.Time)
+}
+
+func TestSupported(t *testing.T) {
+	assert.True(t, Supported("text/csv"))
+	assert.True(t, Supported("text/csv; charset=utf-8"))
+	assert.True(t, Supported(XLSXMediaType))
+	assert.False(t, Supported("application/pdf"))
+	assert.False(t, Supported(""))
+}
+
+func TestCSV(t *testing.T) {
+	t.Run("adds a comment row", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, testMark.Write(&b, strings.NewReader("a,b\n1,2\n"), "text/csv"))
+
+		assert.Equal(t, testComment+"a,b\n1,2\n", b.String())
+	})
+
+	t.Run("adds the comment after the byte order mark", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, testMark.Write(&b, strings.NewReader("\xef\xbb\xbfa,b\n"), "text/csv"))
+
+		assert.Equal(t, "\xef\xbb\xbf"+testComment+"a,b\n", b.String())
+	})
+
+	t.Run("adds the comment once when written in parts", func(t *testing.T) {
+		var b bytes.Buffer
+		w := testMark.CSVWriter(&b)
+		n, err := w.Write([]byte("a,b\n"))
+		require.NoError(t, err)
+		assert.Equal(t, 4, n)
+		_, err = w.Write([]byte("1,2\n"))
+		require.NoError(t, err)
+
+		assert.Equal(t, testComment+"a,b\n1,2\n", b.String())
+	})
+}
+
+func TestXLSX(t *testing.T) {
+	workbook := func(t *testing.T, parts map[string]string) []byte {
+		var b bytes.Buffer
+		zw := zip.NewWriter(&b)
+		for _, name := range []string{contentTypesPart, relationshipsPart, customPropertiesPart, "xl/workbook.xml"} {
+			if content, ok := parts[name]; ok {
+				w, err := zw.Create(name)
+				require.NoError(t, err)
+				_, err = io.WriteString(w, content)
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, zw.Close())
+		return b.Bytes()
+	}
+
+	readParts := func(t *testing.T, b []byte) map[string]string {
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		require.NoError(t, err)
+
+		parts := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			rc.Close() // nolint
+			parts[f.Name] = string(content)
+		}
+		return parts
+	}
+
+	parts := map[string]string{
+		contentTypesPart:  `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="xml" ContentType="application/xml"/></Types>`,
+		relationshipsPart: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`,
+		"xl/workbook.xml": `<workbook/>`,
+	}
+
+	t.Run("adds custom document properties", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, testMark.Write(&b, bytes.NewReader(workbook(t, parts)), XLSXMediaType))
+
+		out := readParts(t, b.Bytes())
+		assert.Equal(t, "<workbook/>", out["xl/workbook.xml"])
+		assert.Contains(t, out[contentTypesPart], `<Override PartName="/docProps/custom.xml" ContentType="application/vnd.openxmlformats-officedocument.custom-properties+xml"/></Types>`)
+		assert.Contains(t, out[relationshipsPart], `Target="docProps/custom.xml"/></Relationships>`)
+		assert.Contains(t, out[customPropertiesPart], `pid="2" name="Classification"><vt:lpwstr>PRE-RELEASE – NOT FOR PUBLICATION</vt:lpwstr>`)
+		assert.Contains(t, out[customPropertiesPart], `pid="3" name="Watermark ID"><vt:lpwstr>3f8b6c1e</vt:lpwstr>`)
+		assert.Contains(t, out[customPropertiesPart], `pid="4" name="Downloaded By"><vt:lpwstr>previewer@ons.gov.uk</vt:lpwstr>`)
+		assert.Contains(t, out[customPropertiesPart], `pid="5" name="Downloaded At"><vt:filetime>2026-03-04T09:30:00Z</vt:filetime>`)
+	})
+
+	t.Run("keeps existing custom document properties", func(t *testing.T) {
+		withProperties := map[string]string{
+			customPropertiesPart: `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"><property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="Source"><vt:lpwstr>ONS</vt:lpwstr></property></Properties>`,
+			contentTypesPart:     strings.Replace(parts[contentTypesPart], "</Types>", `<Override PartName="/docProps/custom.xml" ContentType="application/vnd.openxmlformats-officedocument.custom-properties+xml"/></Types>`, 1),
+			relationshipsPart:    strings.Replace(parts[relationshipsPart], "</Relationships>", `<Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/custom-properties" Target="docProps/custom.xml"/></Relationships>`, 1),
+		}
+
+		var b bytes.Buffer
+		require.NoError(t, testMark.XLSX(&b, bytes.NewReader(workbook(t, withProperties))))
+
+		out := readParts(t, b.Bytes())
+		assert.Equal(t, 1, strings.Count(out[contentTypesPart], "/docProps/custom.xml"))
+		assert.Equal(t, 1, strings.Count(out[relationshipsPart], "custom-properties"))
+		assert.Contains(t, out[customPropertiesPart], `pid="2" name="Source"><vt:lpwstr>ONS</vt:lpwstr>`)
+		assert.Contains(t, out[customPropertiesPart], `pid="3" name="Classification">`)
+		assert.Contains(t, out[customPropertiesPart], `pid="6" name="Downloaded At">`)
+	})
+
+	t.Run("rejects files that are not workbooks", func(t *testing.T) {
+		err := testMark.XLSX(io.Discard, strings.NewReader("a,b\n"))
+
+		assert.ErrorIs(t, err, ErrInvalidXLSX)
+	})
+
+	t.Run("spools the workbook to a temporary file that is removed afterwards", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("TMPDIR", dir)
+
+		require.NoError(t, testMark.XLSX(io.Discard, iotest.OneByteReader(bytes.NewReader(workbook(t, parts)))))
+		require.ErrorIs(t, testMark.XLSX(io.Discard, strings.NewReader("a,b\n")), ErrInvalidXLSX)
+
+		spooled, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, spooled)
+	})
+}
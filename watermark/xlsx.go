@@ -0,0 +1,160 @@
+package watermark
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidXLSX is returned for files that are not xlsx workbooks
+var ErrInvalidXLSX = errors.New("file is not a valid xlsx workbook")
+
+// Parts of a workbook that are changed to add custom document properties
+const (
+	contentTypesPart     = "[Content_Types].xml"
+	relationshipsPart    = "_rels/.rels"
+	customPropertiesPart = "docProps/custom.xml"
+)
+
+const (
+	customPropertiesContentType = "application/vnd.openxmlformats-officedocument.custom-properties+xml"
+	customPropertiesRelType     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/custom-properties"
+	customPropertiesNamespace   = "http://schemas.openxmlformats.org/officeDocument/2006/custom-properties"
+	docPropsVTypesNamespace     = "http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"
+
+	// customPropertyFormatID is the format ID Office gives user-defined custom properties
+	customPropertyFormatID = "{D5CDD505-2E9C-101B-9397-08002B2CF9AE}"
+)
+
+var pidPattern = regexp.MustCompile(`pid="(\d+)"`)
+
+// XLSX writes the workbook read from r to w with the watermark added as custom document properties, which are shown
+// in the properties of the file in Excel. The zip directory is at the end of the workbook, so it is spooled to a
+// temporary file rather than held in memory, and the file is removed once the watermarked copy is written.
+func (m Mark) XLSX(w io.Writer, r io.Reader) error {
+	spool, err := os.CreateTemp("", "watermark-*.xlsx")
+	if err != nil {
+		return fmt.Errorf("failed to spool workbook: %w", err)
+	}
+	defer os.Remove(spool.Name()) // nolint
+	defer spool.Close()           // nolint
+
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(spool, size)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidXLSX, err)
+	}
+
+	zw := zip.NewWriter(w)
+	hasCustomProperties := false
+	for _, f := range zr.File {
+		switch f.Name {
+		case contentTypesPart:
+			err = rewritePart(zw, f, addContentTypeOverride)
+		case relationshipsPart:
+			err = rewritePart(zw, f, addCustomPropertiesRelationship)
+		case customPropertiesPart:
+			hasCustomProperties = true
+			err = rewritePart(zw, f, m.addProperties)
+		default:
+			err = zw.Copy(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !hasCustomProperties {
+		part, err := zw.Create(customPropertiesPart)
+		if err != nil {
+			return err
+		}
+		properties := xml.Header + `<Properties xmlns="` + customPropertiesNamespace + `" xmlns:vt="` + docPropsVTypesNamespace + `"></Properties>`
+		if _, err = io.WriteString(part, m.addProperties(properties)); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// rewritePart writes a copy of the XML part f changed by edit
+func rewritePart(zw *zip.Writer, f *zip.File, edit func(string) string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidXLSX, err)
+	}
+	defer rc.Close() // nolint
+
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidXLSX, err)
+	}
+
+	part, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(part, edit(string(b)))
+	return err
+}
+
+func addContentTypeOverride(contentTypes string) string {
+	if strings.Contains(contentTypes, `PartName="/`+customPropertiesPart+`"`) {
+		return contentTypes
+	}
+	override := `<Override PartName="/` + customPropertiesPart + `" ContentType="` + customPropertiesContentType + `"/>`
+	return insertBefore(contentTypes, "</Types>", override)
+}
+
+func addCustomPropertiesRelationship(relationships string) string {
+	if strings.Contains(relationships, customPropertiesRelType) {
+		return relationships
+	}
+	relationship := `<Relationship Id="rIdWatermark" Type="` + customPropertiesRelType + `" Target="` + customPropertiesPart + `"/>`
+	return insertBefore(relationships, "</Relationships>", relationship)
+}
+
+// addProperties adds the watermark to custom properties, numbering them after any properties already there
+func (m Mark) addProperties(properties string) string {
+	pid := 1 // property IDs start at 2
+	for _, match := range pidPattern.FindAllStringSubmatch(properties, -1) {
+		if n, err := strconv.Atoi(match[1]); err == nil && n > pid {
+			pid = n
+		}
+	}
+
+	var b strings.Builder
+	add := func(name, valueType, value string) {
+		pid++
+		fmt.Fprintf(&b, `<property fmtid="%s" pid="%d" name="%s"><vt:%s>`, customPropertyFormatID, pid, name, valueType)
+		xml.EscapeText(&b, []byte(value)) // nolint
+		fmt.Fprintf(&b, `</vt:%s></property>`, valueType)
+	}
+	add("Classification", "lpwstr", Notice)
+	add("Watermark ID", "lpwstr", m.ID)
+	add("Downloaded By", "lpwstr", m.UserID)
+	add("Downloaded At", "filetime", m.Time.Format(time.RFC3339))
+
+	return insertBefore(properties, "</Properties>", b.String())
+}
+
+// insertBefore inserts s before the last occurrence of end in doc, or at the end of doc if end is not found
+func insertBefore(doc, end, s string) string {
+	i := strings.LastIndex(doc, end)
+	if i < 0 {
+		return doc + s
+	}
+	return doc[:i] + s + doc[i:]
+}